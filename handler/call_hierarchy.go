@@ -0,0 +1,396 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"github.com/serulian/compiler/compilercommon"
+	"github.com/serulian/compiler/graphs/typegraph"
+	"github.com/serulian/compiler/grok"
+	"github.com/serulian/compiler/sourceshape"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+// memberDeclarationTypes defines the types of parse nodes which declare a member of a type or module.
+var memberDeclarationTypes = map[sourceshape.NodeType]bool{
+	sourceshape.NodeTypeFunction:    true,
+	sourceshape.NodeTypeConstructor: true,
+	sourceshape.NodeTypeProperty:    true,
+	sourceshape.NodeTypeOperator:    true,
+	sourceshape.NodeTypeField:       true,
+	sourceshape.NodeTypeVariable:    true,
+}
+
+// prepareCallHierarchy returns the call hierarchy item for the callable member found by the given range
+// information, which was looked up in the document with the given URI. Returns false if the range does not
+// refer to a function, constructor or operator.
+func (dt *documentTracker) prepareCallHierarchy(uri string, target grok.RangeInformation) (protocol.CallHierarchyItem, bool, error) {
+	if target.Kind != grok.NamedReference || len(target.SourceRanges) == 0 {
+		return protocol.CallHierarchyItem{}, false, nil
+	}
+
+	member, isMember := target.NamedReference.Member()
+	if !isMember {
+		// A lookup on a declaration refers to its definition in source, so find the member declared there.
+		handle, err := dt.hierarchyHandle(uri, target.SourceRanges[0].Source())
+		if err != nil {
+			return protocol.CallHierarchyItem{}, false, err
+		}
+
+		startRune, err := target.SourceRanges[0].Start().RunePosition()
+		if err != nil {
+			return protocol.CallHierarchyItem{}, false, err
+		}
+
+		member, isMember = memberDeclaredAt(handle, target.SourceRanges[0].Source(), startRune)
+		if !isMember {
+			return protocol.CallHierarchyItem{}, false, nil
+		}
+	}
+
+	if !isCallableMember(member) {
+		return protocol.CallHierarchyItem{}, false, nil
+	}
+
+	return dt.callHierarchyItem(uri, member)
+}
+
+// incomingCalls returns the calls made to the member represented by the given call hierarchy item, grouped
+// by the member making them.
+func (dt *documentTracker) incomingCalls(item protocol.CallHierarchyItem, cancelationHandle *CancelationHandle) ([]protocol.CallHierarchyIncomingCall, error) {
+	uri, handle, source, err := dt.hierarchyItemHandle(item.URI, item.Data)
+	if err != nil {
+		return []protocol.CallHierarchyIncomingCall{}, err
+	}
+
+	column, err := dt.compilerColumn(source, item.SelectionRange.Start)
+	if err != nil {
+		return []protocol.CallHierarchyIncomingCall{}, err
+	}
+
+	target, err := handle.LookupPosition(source, item.SelectionRange.Start.Line, column)
+	if err != nil || target.Kind != grok.NamedReference {
+		return []protocol.CallHierarchyIncomingCall{}, err
+	}
+
+	references, err := dt.findReferences(uri, target, cancelationHandle)
+	if err != nil {
+		return []protocol.CallHierarchyIncomingCall{}, err
+	}
+
+	parsed := map[compilercommon.InputSource]*parseNode{}
+	callsByCaller := map[string]*protocol.CallHierarchyIncomingCall{}
+	callerKeys := []string{}
+	for _, reference := range references {
+		if cancelationHandle.WasCanceled() {
+			return []protocol.CallHierarchyIncomingCall{}, cancelationHandle.Error()
+		}
+
+		if reference.isDeclaration {
+			continue
+		}
+
+		referenceSource := reference.sourceRange.Source()
+		rootNode, found := parsed[referenceSource]
+		if !found {
+			sourceRootNode, _, err := dt.sourceParseTree(referenceSource)
+			if err != nil {
+				continue
+			}
+
+			rootNode = sourceRootNode
+			parsed[referenceSource] = rootNode
+		}
+
+		startRune, startErr := reference.sourceRange.Start().RunePosition()
+		endRune, endErr := reference.sourceRange.End().RunePosition()
+		if startErr != nil || endErr != nil {
+			continue
+		}
+
+		// Only references which are the callee of a call expression are calls.
+		enclosing := rootNode.enclosingNodes(startRune)
+		if !isCalleeAt(enclosing, endRune) {
+			continue
+		}
+
+		declarationNode, found := innermostMemberDeclaration(enclosing)
+		if !found {
+			continue
+		}
+
+		declarationStart, _, _ := declarationNode.runeRange()
+		caller, isMember := memberDeclaredAt(handle, referenceSource, declarationStart)
+		if !isMember {
+			continue
+		}
+
+		callerKey, ok := memberKey(caller)
+		if !ok {
+			continue
+		}
+
+		fromRange, err := dt.convertRange(reference.sourceRange)
+		if err != nil {
+			continue
+		}
+
+		if existing, found := callsByCaller[callerKey]; found {
+			existing.FromRanges = append(existing.FromRanges, fromRange)
+			continue
+		}
+
+		callerItem, ok, err := dt.callHierarchyItem(uri, caller)
+		if err != nil || !ok {
+			continue
+		}
+
+		callsByCaller[callerKey] = &protocol.CallHierarchyIncomingCall{
+			From:       callerItem,
+			FromRanges: []protocol.Range{fromRange},
+		}
+		callerKeys = append(callerKeys, callerKey)
+	}
+
+	incomingCalls := make([]protocol.CallHierarchyIncomingCall, 0, len(callerKeys))
+	for _, callerKey := range callerKeys {
+		incomingCalls = append(incomingCalls, *callsByCaller[callerKey])
+	}
+
+	return incomingCalls, nil
+}
+
+// outgoingCalls returns the calls made by the member represented by the given call hierarchy item, grouped
+// by the member being called.
+func (dt *documentTracker) outgoingCalls(item protocol.CallHierarchyItem, cancelationHandle *CancelationHandle) ([]protocol.CallHierarchyOutgoingCall, error) {
+	uri, handle, source, err := dt.hierarchyItemHandle(item.URI, item.Data)
+	if err != nil {
+		return []protocol.CallHierarchyOutgoingCall{}, err
+	}
+
+	rootNode, contents, err := dt.sourceParseTree(source)
+	if err != nil {
+		return []protocol.CallHierarchyOutgoingCall{}, err
+	}
+
+	offset, err := offsetForPosition(string(contents), item.SelectionRange.Start, dt.positionEncoding)
+	if err != nil {
+		return []protocol.CallHierarchyOutgoingCall{}, err
+	}
+
+	declarationNode, found := innermostMemberDeclaration(rootNode.enclosingNodes(offset))
+	if !found {
+		return []protocol.CallHierarchyOutgoingCall{}, nil
+	}
+
+	mapper := compilercommon.CreateSourcePositionMapper(contents)
+	callsByCallee := map[string]*protocol.CallHierarchyOutgoingCall{}
+	calleeKeys := []string{}
+	declarationNode.walk(func(node *parseNode) bool {
+		if cancelationHandle.WasCanceled() {
+			return false
+		}
+
+		if node.nodeType != sourceshape.NodeFunctionCallExpression {
+			return true
+		}
+
+		childExpr, hasChildExpr := node.tryGetChild(sourceshape.NodeFunctionCallExpressionChildExpr)
+		if !hasChildExpr {
+			return true
+		}
+
+		_, calleeEnd, ok := childExpr.runeRange()
+		if !ok {
+			return true
+		}
+
+		lineNumber, colPosition, err := mapper.RunePositionToLineAndCol(calleeEnd)
+		if err != nil {
+			return true
+		}
+
+		rangeInfo, err := handle.LookupPosition(source, lineNumber, colPosition)
+		if err != nil || rangeInfo.Kind != grok.NamedReference {
+			return true
+		}
+
+		callee, isMember := rangeInfo.NamedReference.Member()
+		if !isMember || !isCallableMember(callee) {
+			return true
+		}
+
+		calleeKey, ok := memberKey(callee)
+		if !ok {
+			return true
+		}
+
+		// The call is reported at the name of the callee.
+		nameStart := calleeEnd - len(callee.Name()) + 1
+		if nameStart < 0 || string(contents[nameStart:calleeEnd+1]) != callee.Name() {
+			nameStart, _, _ = childExpr.runeRange()
+		}
+
+		fromRange, err := dt.convertRange(source.RangeForRunePositions(nameStart, calleeEnd, dt))
+		if err != nil {
+			return true
+		}
+
+		if existing, found := callsByCallee[calleeKey]; found {
+			existing.FromRanges = append(existing.FromRanges, fromRange)
+			return true
+		}
+
+		calleeItem, ok, err := dt.callHierarchyItem(uri, callee)
+		if err != nil || !ok {
+			return true
+		}
+
+		callsByCallee[calleeKey] = &protocol.CallHierarchyOutgoingCall{
+			To:         calleeItem,
+			FromRanges: []protocol.Range{fromRange},
+		}
+		calleeKeys = append(calleeKeys, calleeKey)
+		return true
+	})
+
+	if cancelationHandle.WasCanceled() {
+		return []protocol.CallHierarchyOutgoingCall{}, cancelationHandle.Error()
+	}
+
+	outgoingCalls := make([]protocol.CallHierarchyOutgoingCall, 0, len(calleeKeys))
+	for _, calleeKey := range calleeKeys {
+		outgoingCalls = append(outgoingCalls, *callsByCallee[calleeKey])
+	}
+
+	return outgoingCalls, nil
+}
+
+// callHierarchyItem returns the call hierarchy item for the given member, recording the URI of the
+// document from which the hierarchy was requested. Returns false if the member has no source.
+func (dt *documentTracker) callHierarchyItem(uri string, member typegraph.TGMember) (protocol.CallHierarchyItem, bool, error) {
+	sourceRanges := member.SourceRanges()
+	if len(sourceRanges) == 0 {
+		return protocol.CallHierarchyItem{}, false, nil
+	}
+
+	source := sourceRanges[0].Source()
+	contents, err := dt.LoadSourceFile(string(source))
+	if err != nil {
+		return protocol.CallHierarchyItem{}, false, err
+	}
+
+	documentSymbol, ok := dt.declarationDocumentSymbol(member.Name(), sourceRanges, source, contents)
+	if !ok {
+		return protocol.CallHierarchyItem{}, false, nil
+	}
+
+	itemURI, ok := dt.sourceToURI(source)
+	if !ok {
+		return protocol.CallHierarchyItem{}, false, nil
+	}
+
+	var detail = ""
+	if containingType, hasContainingType := member.ParentType(); hasContainingType {
+		detail = containingType.Name()
+	}
+
+	return protocol.CallHierarchyItem{
+		Name:           member.Name(),
+		Kind:           symbolKindForMember(member),
+		Detail:         detail,
+		URI:            itemURI,
+		Range:          documentSymbol.Range,
+		SelectionRange: documentSymbol.SelectionRange,
+		Data: map[string]interface{}{
+			"uri": uri,
+		},
+	}, true, nil
+}
+
+// hierarchyItemHandle returns the URI of the document from which the hierarchy of the call or type hierarchy
+// item with the given URI and data was requested, as well as the Grok handle and source to use for the item.
+func (dt *documentTracker) hierarchyItemHandle(itemURI protocol.DocumentURI, data interface{}) (string, grok.Handle, compilercommon.InputSource, error) {
+	path, err := dt.uriToPath(itemURI.String())
+	if err != nil {
+		return "", grok.Handle{}, "", err
+	}
+
+	uri := itemURI.String()
+	if dataMap, ok := data.(map[string]interface{}); ok {
+		if requestURI, ok := dataMap["uri"].(string); ok {
+			uri = requestURI
+		}
+	}
+
+	source := compilercommon.InputSource(path)
+	handle, err := dt.hierarchyHandle(uri, source)
+	return uri, handle, source, err
+}
+
+// hierarchyHandle returns the Grok handle to use for the call or type hierarchy of an entity defined in the
+// given source, requested from the document with the given URI. The Grok of the document's workspace folder is
+// used, if it contains the source, as the entity can be referenced from anywhere in the folder.
+func (dt *documentTracker) hierarchyHandle(uri string, source compilercommon.InputSource) (grok.Handle, error) {
+	if handle, found := dt.workspaceHandle(uri, source); found {
+		return handle, nil
+	}
+
+	return dt.getGrokHandle(uri, grok.HandleMustBeFresh)
+}
+
+// memberKey returns a key uniquely identifying the given member, based on its declaration.
+func memberKey(member typegraph.TGMember) (string, bool) {
+	sourceRanges := member.SourceRanges()
+	if len(sourceRanges) == 0 {
+		return "", false
+	}
+
+	return sourceRangeKey(sourceRanges[0])
+}
+
+// isCallableMember returns true if the given member can be called, as a function, constructor or operator.
+func isCallableMember(member typegraph.TGMember) bool {
+	switch symbolKindForMember(member) {
+	case protocol.SymbolFunction, protocol.SymbolConstructor, protocol.SymbolOperator:
+		return true
+
+	default:
+		return false
+	}
+}
+
+// isCalleeAt returns true if any of the given enclosing nodes is a call expression whose callee ends at the
+// given rune position.
+func isCalleeAt(enclosing []*parseNode, endRune int) bool {
+	for _, node := range enclosing {
+		if node.nodeType != sourceshape.NodeFunctionCallExpression {
+			continue
+		}
+
+		childExpr, hasChildExpr := node.tryGetChild(sourceshape.NodeFunctionCallExpressionChildExpr)
+		if !hasChildExpr {
+			continue
+		}
+
+		if _, calleeEnd, ok := childExpr.runeRange(); ok && calleeEnd == endRune {
+			return true
+		}
+	}
+
+	return false
+}
+
+// innermostMemberDeclaration returns the innermost of the given enclosing nodes which declares a member,
+// if any.
+func innermostMemberDeclaration(enclosing []*parseNode) (*parseNode, bool) {
+	for index := len(enclosing) - 1; index >= 0; index-- {
+		if memberDeclarationTypes[enclosing[index].nodeType] {
+			return enclosing[index], true
+		}
+	}
+
+	return nil, false
+}
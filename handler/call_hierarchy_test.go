@@ -0,0 +1,161 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/serulian/compiler/compilercommon"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+const callHierarchySource = `function Callee() {}
+
+function Helper() int {
+	return 1
+}
+
+function Caller() {
+	Callee()
+	Callee()
+	Helper()
+}
+
+function Other() {
+	Callee()
+	var f = Callee
+}
+`
+
+type isCalleeAtTest struct {
+	name string
+
+	// text is the reference looked up in callHierarchySource.
+	text       string
+	occurrence int
+	expected   bool
+}
+
+var isCalleeAtTests = []isCalleeAtTest{
+	{"declaration", "Callee", 0, false},
+	{"call", "Callee", 1, true},
+	{"function reference", "Callee", 4, false},
+	{"call with result", "Helper", 1, true},
+}
+
+func TestIsCalleeAt(t *testing.T) {
+	source := compilercommon.InputSource("/main.seru")
+	rootNode := parseSource(source, callHierarchySource)
+
+	for _, test := range isCalleeAtTests {
+		start := nthIndex(callHierarchySource, test.text, test.occurrence)
+		enclosing := rootNode.enclosingNodes(start)
+		if called := isCalleeAt(enclosing, start+len(test.text)-1); called != test.expected {
+			t.Errorf("%s: expected isCalleeAt to return %v, found %v", test.name, test.expected, called)
+		}
+	}
+}
+
+func TestInnermostMemberDeclaration(t *testing.T) {
+	source := compilercommon.InputSource("/main.seru")
+	rootNode := parseSource(source, callHierarchySource)
+
+	declarationNode, found := innermostMemberDeclaration(rootNode.enclosingNodes(nthIndex(callHierarchySource, "Helper", 1)))
+	if !found {
+		t.Fatalf("Expected a member declaration to enclose the call to Helper")
+	}
+
+	start, _, _ := declarationNode.runeRange()
+	if expected := strings.Index(callHierarchySource, "function Caller"); start != expected {
+		t.Errorf("Expected the declaration of Caller at %v, found %v", expected, start)
+	}
+
+	if _, found := innermostMemberDeclaration(rootNode.enclosingNodes(len(callHierarchySource))); found {
+		t.Errorf("Expected no member declaration at the end of the source")
+	}
+}
+
+// prepareCallHierarchy prepares the call hierarchy at the given occurrence of the given text in main.seru.
+func (ts *testServer) prepareCallHierarchy(text string, occurrence int) []protocol.CallHierarchyItem {
+	items := []protocol.CallHierarchyItem{}
+	ts.call(protocol.PrepareCallHierarchyRequest, protocol.CallHierarchyPrepareParams{
+		TextDocumentPositionParams: ts.position("main.seru", text, occurrence, 0),
+	}, &items)
+	return items
+}
+
+// describeCalls describes the given calls as the name of the item calling or being called, followed by the
+// described ranges of the calls, sorted.
+func (ts *testServer) describeCalls(item protocol.CallHierarchyItem, fromRanges []protocol.Range, callerURI protocol.DocumentURI) string {
+	described := []string{}
+	for _, fromRange := range fromRanges {
+		described = append(described, ts.describeLocation(callerURI, fromRange))
+	}
+
+	sort.Strings(described)
+	return item.Name + " " + strings.Join(described, " ")
+}
+
+func TestCallHierarchy(t *testing.T) {
+	ts := newTestServer(t, map[string]string{"main.seru": callHierarchySource})
+	defer ts.close()
+	ts.open("main.seru")
+
+	items := ts.prepareCallHierarchy("Callee", 0)
+	if len(items) != 1 || items[0].Name != "Callee" || items[0].Kind != protocol.SymbolFunction {
+		t.Fatalf("Expected a call hierarchy item for Callee, found %v", items)
+	}
+
+	if described := ts.describeLocation(items[0].URI, items[0].SelectionRange); described != "main.seru:0:9" {
+		t.Errorf("Expected the item to select the name of Callee, found %s", described)
+	}
+
+	incomingCalls := []protocol.CallHierarchyIncomingCall{}
+	ts.call(protocol.CallHierarchyIncomingCallsRequest, protocol.CallHierarchyIncomingCallsParams{Item: items[0]}, &incomingCalls)
+
+	incoming := []string{}
+	for _, call := range incomingCalls {
+		incoming = append(incoming, ts.describeCalls(call.From, call.FromRanges, call.From.URI))
+	}
+	sort.Strings(incoming)
+
+	// The reference to Callee which does not call it is not reported.
+	expectedIncoming := []string{"Caller main.seru:7:1 main.seru:8:1", "Other main.seru:13:1"}
+	if !reflect.DeepEqual(incoming, expectedIncoming) {
+		t.Errorf("Expected incoming calls %v, found %v", expectedIncoming, incoming)
+	}
+
+	callerItems := ts.prepareCallHierarchy("Caller", 0)
+	if len(callerItems) != 1 {
+		t.Fatalf("Expected a call hierarchy item for Caller, found %v", callerItems)
+	}
+
+	outgoingCalls := []protocol.CallHierarchyOutgoingCall{}
+	ts.call(protocol.CallHierarchyOutgoingCallsRequest, protocol.CallHierarchyOutgoingCallsParams{Item: callerItems[0]}, &outgoingCalls)
+
+	outgoing := []string{}
+	for _, call := range outgoingCalls {
+		outgoing = append(outgoing, ts.describeCalls(call.To, call.FromRanges, callerItems[0].URI))
+	}
+
+	expectedOutgoing := []string{"Callee main.seru:7:1 main.seru:8:1", "Helper main.seru:9:1"}
+	if !reflect.DeepEqual(outgoing, expectedOutgoing) {
+		t.Errorf("Expected outgoing calls %v, found %v", expectedOutgoing, outgoing)
+	}
+}
+
+func TestPrepareCallHierarchyNotCallable(t *testing.T) {
+	ts := newTestServer(t, map[string]string{"main.seru": callHierarchySource})
+	defer ts.close()
+	ts.open("main.seru")
+
+	if items := ts.prepareCallHierarchy("f = Callee", 0); len(items) != 0 {
+		t.Errorf("Expected no call hierarchy item for a variable, found %v", items)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+// supportsDocumentChanges returns true if the client supports versioned document changes in workspace edits.
+func (h *SerulianLangServerHandler) supportsDocumentChanges() bool {
+	workspace := h.clientCapabilities.Workspace
+	if workspace == nil || workspace.WorkspaceEdit == nil || workspace.WorkspaceEdit.DocumentChanges == nil {
+		return false
+	}
+
+	return *workspace.WorkspaceEdit.DocumentChanges
+}
+
+// supportsPrepareRename returns true if the client supports the prepare rename request.
+func (h *SerulianLangServerHandler) supportsPrepareRename() bool {
+	textDocument := h.clientCapabilities.TextDocument
+	if textDocument == nil || textDocument.Rename == nil || textDocument.Rename.PrepareSupport == nil {
+		return false
+	}
+
+	return *textDocument.Rename.PrepareSupport
+}
+
+// supportsHierarchicalDocumentSymbols returns true if the client supports hierarchical document symbols.
+func (h *SerulianLangServerHandler) supportsHierarchicalDocumentSymbols() bool {
+	textDocument := h.clientCapabilities.TextDocument
+	if textDocument == nil || textDocument.DocumentSymbol == nil || textDocument.DocumentSymbol.HierarchicalDocumentSymbolSupport == nil {
+		return false
+	}
+
+	return *textDocument.DocumentSymbol.HierarchicalDocumentSymbolSupport
+}
+
+// supportsWatchedFilesRegistration returns true if the client supports dynamic registration of file watchers.
+func (h *SerulianLangServerHandler) supportsWatchedFilesRegistration() bool {
+	workspace := h.clientCapabilities.Workspace
+	if workspace == nil || workspace.DidChangeWatchedFiles == nil || workspace.DidChangeWatchedFiles.DynamicRegistration == nil {
+		return false
+	}
+
+	return *workspace.DidChangeWatchedFiles.DynamicRegistration
+}
+
+// supportsConfiguration returns true if the client supports the configuration request.
+func (h *SerulianLangServerHandler) supportsConfiguration() bool {
+	workspace := h.clientCapabilities.Workspace
+	if workspace == nil || workspace.Configuration == nil {
+		return false
+	}
+
+	return *workspace.Configuration
+}
+
+// supportsConfigurationChangeRegistration returns true if the client supports dynamic registration for
+// configuration changes.
+func (h *SerulianLangServerHandler) supportsConfigurationChangeRegistration() bool {
+	workspace := h.clientCapabilities.Workspace
+	if workspace == nil || workspace.DidChangeConfiguration == nil || workspace.DidChangeConfiguration.DynamicRegistration == nil {
+		return false
+	}
+
+	return *workspace.DidChangeConfiguration.DynamicRegistration
+}
@@ -0,0 +1,86 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+// applyContentChanges applies the given content change events, in order, to the given contents and returns
+// the updated contents. A change without a range replaces the contents in full. The columns of the change
+// ranges are in the given position encoding.
+func applyContentChanges(contents string, changes []protocol.TextDocumentContentChangeEvent, encoding protocol.PositionEncodingKind) (string, error) {
+	for index, change := range changes {
+		if change.Range == nil {
+			contents = change.Text
+			continue
+		}
+
+		startOffset, err := offsetForPosition(contents, change.Range.Start, encoding)
+		if err != nil {
+			return "", fmt.Errorf("Invalid start position for change #%v: %v", index, err)
+		}
+
+		endOffset, err := offsetForPosition(contents, change.Range.End, encoding)
+		if err != nil {
+			return "", fmt.Errorf("Invalid end position for change #%v: %v", index, err)
+		}
+
+		if endOffset < startOffset {
+			return "", fmt.Errorf("Invalid range for change #%v: end position %v:%v is before start position %v:%v", index,
+				change.Range.End.Line, change.Range.End.Column, change.Range.Start.Line, change.Range.Start.Column)
+		}
+
+		contents = contents[0:startOffset] + change.Text + contents[endOffset:]
+	}
+
+	return contents, nil
+}
+
+// offsetForPosition returns the byte offset in the contents of the given position, whose column is in the
+// given position encoding. Per the spec, a column past the end of its line refers to the end of the line.
+func offsetForPosition(contents string, position protocol.Position, encoding protocol.PositionEncodingKind) (int, error) {
+	if position.Line < 0 || position.Column < 0 {
+		return 0, fmt.Errorf("Position %v:%v is negative", position.Line, position.Column)
+	}
+
+	lineStart := 0
+	for line := 0; line < position.Line; line++ {
+		index := strings.IndexByte(contents[lineStart:], '\n')
+		if index < 0 {
+			return 0, fmt.Errorf("Line %v is beyond the end of the document", position.Line)
+		}
+
+		lineStart = lineStart + index + 1
+	}
+
+	lineEnd := len(contents)
+	if index := strings.IndexByte(contents[lineStart:], '\n'); index >= 0 {
+		lineEnd = lineStart + index
+	}
+
+	if lineEnd > lineStart && contents[lineEnd-1] == '\r' {
+		lineEnd--
+	}
+
+	return lineStart + byteColumn(contents[lineStart:lineEnd], position.Column, encoding), nil
+}
+
+// positionForOffset returns the position of the given byte offset in the contents, with its column in
+// the given position encoding. The inverse of offsetForPosition.
+func positionForOffset(contents string, offset int, encoding protocol.PositionEncodingKind) protocol.Position {
+	if offset > len(contents) {
+		offset = len(contents)
+	}
+
+	lineStart := strings.LastIndexByte(contents[0:offset], '\n') + 1
+	return protocol.Position{
+		Line:   strings.Count(contents[0:lineStart], "\n"),
+		Column: encodedColumn(contents[lineStart:offset], offset-lineStart, encoding),
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"testing"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+func changeRange(startLine int, startColumn int, endLine int, endColumn int) *protocol.Range {
+	return &protocol.Range{
+		Start: protocol.Position{Line: startLine, Column: startColumn},
+		End:   protocol.Position{Line: endLine, Column: endColumn},
+	}
+}
+
+type applyContentChangesTest struct {
+	name          string
+	contents      string
+	changes       []protocol.TextDocumentContentChangeEvent
+	expected      string
+	expectedError bool
+}
+
+var applyContentChangesTests = []applyContentChangesTest{
+	{"no changes", "var a = 1", []protocol.TextDocumentContentChangeEvent{}, "var a = 1", false},
+
+	{"full replacement", "var a = 1", []protocol.TextDocumentContentChangeEvent{
+		{Text: "var b = 2"},
+	}, "var b = 2", false},
+
+	{"insertion", "var a = 1", []protocol.TextDocumentContentChangeEvent{
+		{Range: changeRange(0, 5, 0, 5), Text: "bc"},
+	}, "var abc = 1", false},
+
+	{"deletion", "var abc = 1", []protocol.TextDocumentContentChangeEvent{
+		{Range: changeRange(0, 5, 0, 7)},
+	}, "var a = 1", false},
+
+	{"replacement across lines", "function f() {\n  return 1\n}\n", []protocol.TextDocumentContentChangeEvent{
+		{Range: changeRange(0, 13, 2, 1), Text: "{ return 2 }"},
+	}, "function f() { return 2 }\n", false},
+
+	{"insertion of lines", "a\nc", []protocol.TextDocumentContentChangeEvent{
+		{Range: changeRange(1, 0, 1, 0), Text: "b\n"},
+	}, "a\nb\nc", false},
+
+	{"changes applied in order", "abc", []protocol.TextDocumentContentChangeEvent{
+		{Range: changeRange(0, 3, 0, 3), Text: "d"},
+		{Range: changeRange(0, 0, 0, 1), Text: ""},
+		{Range: changeRange(0, 3, 0, 3), Text: "e"},
+	}, "bcde", false},
+
+	{"full replacement followed by range change", "old", []protocol.TextDocumentContentChangeEvent{
+		{Text: "new\ntext"},
+		{Range: changeRange(1, 0, 1, 4), Text: "contents"},
+	}, "new\ncontents", false},
+
+	{"column past end of line", "ab\ncd", []protocol.TextDocumentContentChangeEvent{
+		{Range: changeRange(0, 10, 0, 10), Text: "!"},
+	}, "ab!\ncd", false},
+
+	{"end of line before carriage return", "ab\r\ncd", []protocol.TextDocumentContentChangeEvent{
+		{Range: changeRange(0, 10, 0, 10), Text: "!"},
+	}, "ab!\r\ncd", false},
+
+	{"utf-16 columns", "var s = '😀x'", []protocol.TextDocumentContentChangeEvent{
+		{Range: changeRange(0, 11, 0, 12), Text: "y"},
+	}, "var s = '😀y'", false},
+
+	{"line past end of document", "a\nb", []protocol.TextDocumentContentChangeEvent{
+		{Range: changeRange(3, 0, 3, 0), Text: "c"},
+	}, "", true},
+
+	{"end before start", "abc", []protocol.TextDocumentContentChangeEvent{
+		{Range: changeRange(0, 2, 0, 1), Text: "c"},
+	}, "", true},
+
+	{"negative position", "abc", []protocol.TextDocumentContentChangeEvent{
+		{Range: changeRange(0, -1, 0, 1), Text: "c"},
+	}, "", true},
+}
+
+func TestApplyContentChanges(t *testing.T) {
+	for _, test := range applyContentChangesTests {
+		updated, err := applyContentChanges(test.contents, test.changes, protocol.PositionEncodingUTF16)
+		if test.expectedError {
+			if err == nil {
+				t.Errorf("%s: expected error, found contents %q", test.name, updated)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+
+		if updated != test.expected {
+			t.Errorf("%s: expected %q, found %q", test.name, test.expected, updated)
+		}
+	}
+}
+
+type offsetPositionTest struct {
+	name     string
+	contents string
+	offset   int
+	position protocol.Position
+	encoding protocol.PositionEncodingKind
+}
+
+var offsetPositionTests = []offsetPositionTest{
+	{"start of contents", "abc\ndef", 0, protocol.Position{Line: 0, Column: 0}, protocol.PositionEncodingUTF16},
+	{"middle of first line", "abc\ndef", 2, protocol.Position{Line: 0, Column: 2}, protocol.PositionEncodingUTF16},
+	{"end of first line", "abc\ndef", 3, protocol.Position{Line: 0, Column: 3}, protocol.PositionEncodingUTF16},
+	{"start of second line", "abc\ndef", 4, protocol.Position{Line: 1, Column: 0}, protocol.PositionEncodingUTF16},
+	{"end of contents", "abc\ndef", 7, protocol.Position{Line: 1, Column: 3}, protocol.PositionEncodingUTF16},
+	{"empty line", "a\n\nb", 2, protocol.Position{Line: 1, Column: 0}, protocol.PositionEncodingUTF16},
+	{"after trailing newline", "a\n", 2, protocol.Position{Line: 1, Column: 0}, protocol.PositionEncodingUTF16},
+	{"after two byte character in utf-16", "é!", 2, protocol.Position{Line: 0, Column: 1}, protocol.PositionEncodingUTF16},
+	{"after surrogate pair in utf-16", "😀!", 4, protocol.Position{Line: 0, Column: 2}, protocol.PositionEncodingUTF16},
+	{"after surrogate pair in utf-32", "😀!", 4, protocol.Position{Line: 0, Column: 1}, protocol.PositionEncodingUTF32},
+	{"after surrogate pair in utf-8", "😀!", 4, protocol.Position{Line: 0, Column: 4}, protocol.PositionEncodingUTF8},
+	{"second line with unicode", "é\nxé!", 6, protocol.Position{Line: 1, Column: 2}, protocol.PositionEncodingUTF16},
+}
+
+func TestOffsetForPosition(t *testing.T) {
+	for _, test := range offsetPositionTests {
+		offset, err := offsetForPosition(test.contents, test.position, test.encoding)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+
+		if offset != test.offset {
+			t.Errorf("%s: expected offset %v, found %v", test.name, test.offset, offset)
+		}
+	}
+}
+
+func TestPositionForOffset(t *testing.T) {
+	for _, test := range offsetPositionTests {
+		position := positionForOffset(test.contents, test.offset, test.encoding)
+		if position != test.position {
+			t.Errorf("%s: expected position %v, found %v", test.name, test.position, position)
+		}
+	}
+}
+
+func TestPositionForOffsetPastEnd(t *testing.T) {
+	position := positionForOffset("ab\ncd", 100, protocol.PositionEncodingUTF16)
+	if expected := (protocol.Position{Line: 1, Column: 2}); position != expected {
+		t.Errorf("Expected position %v, found %v", expected, position)
+	}
+}
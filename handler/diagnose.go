@@ -6,9 +6,11 @@ package handler
 
 import (
 	"context"
+	"fmt"
 	"log"
 
 	"github.com/serulian/compiler/compilercommon"
+	"github.com/serulian/compiler/grok"
 
 	"github.com/serulian/serulian-langserver/protocol"
 
@@ -35,16 +37,24 @@ func diagnoseDocument(data interface{}) {
 	path := params.path
 	version := params.version
 	isWorkspaceDiagnose := params.isWorkspaceDiagnose
-	workspaceGroker := dt.workspaceGrok
 
 	log.Printf("Starting diagnoseDocument for %s at version %v (isWorkspace=%v) \n", path, version, isWorkspaceDiagnose)
 
 	// If this is a diagnose for a non-workspace document, then make sure we are still at the correct version.
-	groker := workspaceGroker
+	var grokers []*grok.Groker
 	pathsToReport := []string{path}
 
 	if isWorkspaceDiagnose {
-		pathsToReport = dt.documents.Keys()
+		// Retrieve the workspace folder, reporting only for the documents found in it.
+		currentValue, exists := dt.workspaceFolders.Get(path)
+		if !exists {
+			log.Printf("Canceled (#1) diagnoseDocument for removed workspace folder %s", path)
+			return
+		}
+
+		folder := currentValue.(workspaceFolder)
+		grokers = folder.grokers
+		pathsToReport = dt.workspaceFolderDocuments(folder)
 	} else {
 		// Ensure we are still at the current version.
 		current, valid := dt.getDocumentAtVersion(path, version)
@@ -54,20 +64,28 @@ func diagnoseDocument(data interface{}) {
 		}
 
 		// Retrieve the handle.
-		groker = current.groker
-		if groker == nil {
+		if current.groker == nil {
 			log.Printf("No groker for diagnoseDocument for %s at version %v", path, version)
 			return
 		}
+
+		grokers = []*grok.Groker{current.groker}
 	}
 
-	handle, err := groker.GetHandle()
-	if err != nil {
-		log.Printf("Encountered error retrieving handle for diagnoseDocument for %s at version %v", path, version)
-		return
+	// Retrieve the handles, one for each entrypoint of a workspace folder.
+	handles := make([]grok.Handle, 0, len(grokers))
+	for _, groker := range grokers {
+		handle, err := groker.GetHandle()
+		if err != nil {
+			log.Printf("Encountered error retrieving handle for diagnoseDocument for %s at version %v", path, version)
+			return
+		}
+
+		log.Printf("Got handle with status %v for diagnoseDocument for %s at version %v", handle.IsCompilable(), path, version)
+		handles = append(handles, handle)
 	}
 
-	log.Printf("Got handle with status %v for diagnoseDocument for %s at version %v", handle.IsCompilable(), path, version)
+	warningSeverity, reportWarnings := diagnosticSeverity(dt.settingsFor(path).warningSeverity)
 
 	// Ensure we are still at the current version.
 	if !isWorkspaceDiagnose {
@@ -80,18 +98,35 @@ func diagnoseDocument(data interface{}) {
 
 	// Collect any issues found, by document.
 	for _, currentPath := range pathsToReport {
-		// Skip any paths no longer referenced by the document. They'll be updated on next edit.
-		if !handle.ContainsSource(compilercommon.InputSource(currentPath)) {
+		// Clear the diagnostics for any paths no longer referenced, if published by this kind of diagnose.
+		// Otherwise, they'll be updated on next edit.
+		containingHandles := []grok.Handle{}
+		for _, handle := range handles {
+			if handle.ContainsSource(compilercommon.InputSource(currentPath)) {
+				containingHandles = append(containingHandles, handle)
+			}
+		}
+
+		if len(containingHandles) == 0 {
+			dt.clearDiagnostics(ctx, conn, currentPath, isWorkspaceDiagnose)
 			continue
 		}
 
+		// Issues found by more than one handle (for sources shared between entrypoints) are only reported once.
 		var issues = []protocol.Diagnostic{}
+		var encountered = map[string]bool{}
 		addIssue := func(sourceRange compilercommon.SourceRange, message string, severity protocol.DiagnosticSeverity) {
 			documentRange, err := dt.convertRange(sourceRange)
 			if err != nil {
 				return
 			}
 
+			key := fmt.Sprintf("%v:%v:%s", documentRange, severity, message)
+			if encountered[key] {
+				return
+			}
+
+			encountered[key] = true
 			issues = append(issues, protocol.Diagnostic{
 				Severity: severity,
 				Message:  message,
@@ -99,22 +134,22 @@ func diagnoseDocument(data interface{}) {
 			})
 		}
 
-		for _, sourceError := range handle.Errors() {
-			if string(sourceError.SourceRange().Source()) == currentPath {
-				addIssue(sourceError.SourceRange(), sourceError.Error(), protocol.DiagnosticError)
+		for _, handle := range containingHandles {
+			for _, sourceError := range handle.Errors() {
+				if string(sourceError.SourceRange().Source()) == currentPath {
+					addIssue(sourceError.SourceRange(), sourceError.Error(), protocol.DiagnosticError)
+				}
 			}
-		}
 
-		for _, sourceWarning := range handle.Warnings() {
-			if string(sourceWarning.SourceRange().Source()) == currentPath {
-				addIssue(sourceWarning.SourceRange(), sourceWarning.Warning(), protocol.DiagnosticWarning)
+			if !reportWarnings {
+				continue
 			}
-		}
 
-		uri, okay := dt.sourceToURI(compilercommon.InputSource(currentPath))
-		if !okay {
-			log.Printf("Could not convert path `%s` to URI in diagnoseDocument at version %v", currentPath, version)
-			continue
+			for _, sourceWarning := range handle.Warnings() {
+				if string(sourceWarning.SourceRange().Source()) == currentPath {
+					addIssue(sourceWarning.SourceRange(), sourceWarning.Warning(), warningSeverity)
+				}
+			}
 		}
 
 		if !isWorkspaceDiagnose {
@@ -123,16 +158,88 @@ func diagnoseDocument(data interface{}) {
 			if !valid {
 				continue
 			}
+		} else if !dt.documents.Has(currentPath) {
+			// Skip any documents closed since the diagnose started.
+			continue
 		}
 
-		err = conn.Notify(ctx, protocol.PublicDiagonsticsNotification, protocol.PublishDiagnosticsParams{
-			URI:         uri,
-			Diagnostics: issues,
-		})
-
+		err := dt.publishDiagnostics(ctx, conn, currentPath, issues, isWorkspaceDiagnose)
 		if err != nil {
 			log.Printf("Notify failed for diagnoseDocument for %s at version %v: %v", currentPath, version, err)
 			continue
 		}
 	}
 }
+
+// diagnosticSeverity returns the diagnostic severity corresponding to the given lint severity, and false if
+// issues with that severity are not reported.
+func diagnosticSeverity(severity protocol.LintSeverity) (protocol.DiagnosticSeverity, bool) {
+	switch severity {
+	case protocol.LintSeverityOff:
+		return protocol.DiagnosticWarning, false
+
+	case protocol.LintSeverityHint:
+		return protocol.DiagnosticHint, true
+
+	case protocol.LintSeverityInformation:
+		return protocol.DiagnosticInformation, true
+
+	case protocol.LintSeverityError:
+		return protocol.DiagnosticError, true
+
+	default:
+		return protocol.DiagnosticWarning, true
+	}
+}
+
+// publishDiagnostics publishes the given diagnostics for the given path to the client, and records whether
+// the path has diagnostics published, and by which kind of diagnose, so they can be cleared later.
+func (dt *documentTracker) publishDiagnostics(ctx context.Context, conn *jsonrpc2.Conn, path string, issues []protocol.Diagnostic, isWorkspaceDiagnose bool) error {
+	uri, okay := dt.sourceToURI(compilercommon.InputSource(path))
+	if !okay {
+		return fmt.Errorf("Could not convert path `%s` to URI", path)
+	}
+
+	err := conn.Notify(ctx, protocol.PublicDiagonsticsNotification, protocol.PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: issues,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(issues) == 0 {
+		dt.publishedDiagnostics.Remove(path)
+	} else {
+		dt.publishedDiagnostics.Set(path, isWorkspaceDiagnose)
+	}
+
+	return nil
+}
+
+// clearDiagnostics publishes an empty set of diagnostics for the given path, if diagnostics were previously
+// published for it by the given kind of diagnose.
+func (dt *documentTracker) clearDiagnostics(ctx context.Context, conn *jsonrpc2.Conn, path string, isWorkspaceDiagnose bool) {
+	publishedByWorkspace, wasPublished := dt.publishedDiagnostics.Get(path)
+	if !wasPublished || publishedByWorkspace.(bool) != isWorkspaceDiagnose {
+		return
+	}
+
+	err := dt.publishDiagnostics(ctx, conn, path, []protocol.Diagnostic{}, isWorkspaceDiagnose)
+	if err != nil {
+		log.Printf("Could not clear diagnostics for %s: %v", path, err)
+	}
+}
+
+// clearAllDiagnostics publishes an empty set of diagnostics for the given path, if any diagnostics were
+// previously published for it.
+func (dt *documentTracker) clearAllDiagnostics(ctx context.Context, conn *jsonrpc2.Conn, path string) {
+	if !dt.publishedDiagnostics.Has(path) {
+		return
+	}
+
+	err := dt.publishDiagnostics(ctx, conn, path, []protocol.Diagnostic{}, false)
+	if err != nil {
+		log.Printf("Could not clear diagnostics for %s: %v", path, err)
+	}
+}
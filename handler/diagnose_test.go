@@ -0,0 +1,49 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"testing"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+const brokenSource = "function First() {\n\tSecond()\n}\n"
+
+const fixedSource = "function First() {\n}\n\nfunction Second() {\n\tFirst()\n}\n"
+
+// hasDiagnostics returns true if non-empty diagnostics were published.
+func hasDiagnostics(diagnostics []protocol.Diagnostic, published bool) bool {
+	return len(diagnostics) > 0
+}
+
+// hasClearedDiagnostics returns true if empty diagnostics were published.
+func hasClearedDiagnostics(diagnostics []protocol.Diagnostic, published bool) bool {
+	return published && len(diagnostics) == 0
+}
+
+func TestDiagnosticsClearedOnClose(t *testing.T) {
+	ts := newTestServer(t, map[string]string{"main.seru": brokenSource})
+	defer ts.close()
+	ts.open("main.seru")
+
+	diagnostics := ts.waitForDiagnostics(ts.uri("main.seru"), hasDiagnostics)
+	if diagnostics[0].Severity != protocol.DiagnosticError || diagnostics[0].Range.Start.Line != 1 {
+		t.Errorf("Expected an error on line 1, found %v", diagnostics)
+	}
+
+	ts.closeDocument("main.seru")
+	ts.waitForDiagnostics(ts.uri("main.seru"), hasClearedDiagnostics)
+}
+
+func TestDiagnosticsClearedWhenFixed(t *testing.T) {
+	ts := newTestServer(t, map[string]string{"main.seru": brokenSource})
+	defer ts.close()
+	ts.open("main.seru")
+
+	ts.waitForDiagnostics(ts.uri("main.seru"), hasDiagnostics)
+	ts.change("main.seru", 2, fixedSource)
+	ts.waitForDiagnostics(ts.uri("main.seru"), hasClearedDiagnostics)
+}
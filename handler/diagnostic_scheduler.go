@@ -0,0 +1,101 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"sync"
+	"time"
+)
+
+// debouncedCall holds a debounced function, along with the function to flush its pending call.
+type debouncedCall struct {
+	call  func(data interface{})
+	flush func()
+}
+
+// diagnosticScheduler schedules the diagnosis of documents. Diagnoses are debounced separately for each
+// document and for each workspace folder, so that scheduling the diagnosis of one never drops the pending
+// diagnosis of another, while repeated diagnoses of the same document are coalesced into a diagnosis of its
+// latest version.
+type diagnosticScheduler struct {
+	// diagnose is the function invoked to perform a diagnosis.
+	diagnose func(data interface{})
+
+	// delayFor returns the delay waited after the last scheduling of a diagnosis of the document or workspace
+	// folder with the given path before it is performed.
+	delayFor func(path string) time.Duration
+
+	// callsLock guards the workspaces and documents maps.
+	callsLock sync.Mutex
+
+	// workspaces is the map of debounced calls for diagnosing each workspace folder, keyed by root path.
+	workspaces map[string]debouncedCall
+
+	// documents is the map of debounced calls for diagnosing each document, keyed by path.
+	documents map[string]debouncedCall
+}
+
+func newDiagnosticScheduler(diagnose func(data interface{}), delayFor func(path string) time.Duration) *diagnosticScheduler {
+	return &diagnosticScheduler{
+		diagnose:   diagnose,
+		delayFor:   delayFor,
+		workspaces: map[string]debouncedCall{},
+		documents:  map[string]debouncedCall{},
+	}
+}
+
+// schedule schedules the diagnosis described by the given parameters.
+func (ds *diagnosticScheduler) schedule(params diagnoseParams) {
+	ds.callsLock.Lock()
+	calls := ds.documents
+	if params.isWorkspaceDiagnose {
+		calls = ds.workspaces
+	}
+
+	diagnoseCall, exists := calls[params.path]
+	if !exists {
+		path := params.path
+		call, flush := debounce(ds.diagnose, func() time.Duration { return ds.delayFor(path) })
+		diagnoseCall = debouncedCall{call, flush}
+		calls[params.path] = diagnoseCall
+	}
+	ds.callsLock.Unlock()
+
+	diagnoseCall.call(params)
+}
+
+// forget stops tracking diagnosis for the document with the given path. A diagnosis already pending
+// will still be performed.
+func (ds *diagnosticScheduler) forget(path string) {
+	ds.callsLock.Lock()
+	defer ds.callsLock.Unlock()
+	delete(ds.documents, path)
+}
+
+// forgetWorkspace stops tracking diagnosis for the workspace folder with the given root path. A diagnosis
+// already pending will still be performed.
+func (ds *diagnosticScheduler) forgetWorkspace(rootPath string) {
+	ds.callsLock.Lock()
+	defer ds.callsLock.Unlock()
+	delete(ds.workspaces, rootPath)
+}
+
+// flush immediately performs all pending diagnoses.
+func (ds *diagnosticScheduler) flush() {
+	ds.callsLock.Lock()
+	diagnoseCalls := make([]debouncedCall, 0, len(ds.documents)+len(ds.workspaces))
+	for _, documentCall := range ds.documents {
+		diagnoseCalls = append(diagnoseCalls, documentCall)
+	}
+
+	for _, workspaceCall := range ds.workspaces {
+		diagnoseCalls = append(diagnoseCalls, workspaceCall)
+	}
+	ds.callsLock.Unlock()
+
+	for _, diagnoseCall := range diagnoseCalls {
+		diagnoseCall.flush()
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// newRecordingScheduler returns a diagnostic scheduler waiting the given delay for every path, whose diagnoses
+// are recorded by the returned recorder.
+func newRecordingScheduler(delay time.Duration) (*diagnosticScheduler, *debounceRecorder) {
+	recorder := newDebounceRecorder()
+	scheduler := newDiagnosticScheduler(recorder.record, func(path string) time.Duration { return delay })
+	return scheduler, recorder
+}
+
+// waitForDiagnoses waits for the given number of diagnoses to be recorded, returning them described as their
+// kind, path and version, sorted.
+func waitForDiagnoses(t *testing.T, recorder *debounceRecorder, count int) []string {
+	for i := 0; i < count; i++ {
+		select {
+		case <-recorder.called:
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for diagnosis %v, found %v", i+1, recorder.recorded())
+		}
+	}
+
+	described := []string{}
+	for _, data := range recorder.recorded() {
+		params := data.(diagnoseParams)
+		kind := "document"
+		if params.isWorkspaceDiagnose {
+			kind = "workspace"
+		}
+		described = append(described, fmt.Sprintf("%s %s@%v", kind, params.path, params.version))
+	}
+
+	sort.Strings(described)
+	return described
+}
+
+// expectNoMoreDiagnoses fails the test if any further diagnosis is recorded within the given duration.
+func expectNoMoreDiagnoses(t *testing.T, recorder *debounceRecorder, duration time.Duration) {
+	select {
+	case <-recorder.called:
+		t.Errorf("Expected no further diagnoses, found %v", recorder.recorded())
+	case <-time.After(duration):
+	}
+}
+
+func TestDiagnosticSchedulerCoalescesPerDocument(t *testing.T) {
+	scheduler, recorder := newRecordingScheduler(20 * time.Millisecond)
+
+	scheduler.schedule(diagnoseParams{path: "/a.seru", version: 1})
+	scheduler.schedule(diagnoseParams{path: "/b.seru", version: 1})
+	scheduler.schedule(diagnoseParams{path: "/a.seru", version: 2})
+	scheduler.schedule(diagnoseParams{path: "/a.seru", version: 3})
+
+	// Scheduling document b must not drop the pending diagnosis of document a, while the diagnoses of document a
+	// are coalesced into one of its latest version.
+	expected := []string{"document /a.seru@3", "document /b.seru@1"}
+	if found := waitForDiagnoses(t, recorder, 2); !reflect.DeepEqual(found, expected) {
+		t.Errorf("Expected diagnoses %v, found %v", expected, found)
+	}
+
+	expectNoMoreDiagnoses(t, recorder, 50*time.Millisecond)
+}
+
+func TestDiagnosticSchedulerKeepsWorkspaceDiagnosesSeparate(t *testing.T) {
+	scheduler, recorder := newRecordingScheduler(20 * time.Millisecond)
+
+	scheduler.schedule(diagnoseParams{path: "/workspace", isWorkspaceDiagnose: true})
+	scheduler.schedule(diagnoseParams{path: "/workspace", version: 1})
+	scheduler.schedule(diagnoseParams{path: "/other", isWorkspaceDiagnose: true})
+
+	expected := []string{"document /workspace@1", "workspace /other@0", "workspace /workspace@0"}
+	if found := waitForDiagnoses(t, recorder, 3); !reflect.DeepEqual(found, expected) {
+		t.Errorf("Expected diagnoses %v, found %v", expected, found)
+	}
+}
+
+func TestDiagnosticSchedulerFlush(t *testing.T) {
+	scheduler, recorder := newRecordingScheduler(time.Hour)
+
+	scheduler.schedule(diagnoseParams{path: "/a.seru", version: 1})
+	scheduler.schedule(diagnoseParams{path: "/a.seru", version: 2})
+	scheduler.schedule(diagnoseParams{path: "/workspace", isWorkspaceDiagnose: true})
+	scheduler.flush()
+
+	expected := []string{"document /a.seru@2", "workspace /workspace@0"}
+	if found := waitForDiagnoses(t, recorder, 2); !reflect.DeepEqual(found, expected) {
+		t.Errorf("Expected diagnoses %v, found %v", expected, found)
+	}
+
+	scheduler.flush()
+	expectNoMoreDiagnoses(t, recorder, 20*time.Millisecond)
+}
+
+func TestDiagnosticSchedulerForget(t *testing.T) {
+	scheduler, recorder := newRecordingScheduler(20 * time.Millisecond)
+
+	// A diagnosis pending when the document is forgotten is still performed, and the document's later
+	// diagnoses are scheduled anew.
+	scheduler.schedule(diagnoseParams{path: "/a.seru", version: 1})
+	scheduler.forget("/a.seru")
+	scheduler.schedule(diagnoseParams{path: "/a.seru", version: 2})
+
+	expected := []string{"document /a.seru@1", "document /a.seru@2"}
+	if found := waitForDiagnoses(t, recorder, 2); !reflect.DeepEqual(found, expected) {
+		t.Errorf("Expected diagnoses %v, found %v", expected, found)
+	}
+
+	// Forgotten documents are no longer flushed.
+	scheduler.schedule(diagnoseParams{path: "/b.seru", version: 1})
+	scheduler.forget("/b.seru")
+	scheduler.flush()
+	if recorded := recorder.recorded(); len(recorded) != 2 {
+		t.Errorf("Expected the forgotten document to not be flushed, found %v", recorded)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"github.com/serulian/compiler/compilercommon"
+	"github.com/serulian/compiler/grok"
+	"github.com/serulian/compiler/sourceshape"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+// documentHighlights returns highlights for all occurrences of the entity described by the given range
+// information in the document with the given URI. Occurrences of types and modules are highlighted as
+// text, while occurrences of values are highlighted as either reads or writes.
+func (dt *documentTracker) documentHighlights(uri string, target grok.RangeInformation, cancelationHandle *CancelationHandle) ([]protocol.DocumentHighlight, error) {
+	handle, err := dt.getGrokHandle(uri, grok.HandleMustBeFresh)
+	if err != nil {
+		return []protocol.DocumentHighlight{}, err
+	}
+
+	rootNode, source, _, err := dt.parseDocument(uri)
+	if err != nil {
+		return []protocol.DocumentHighlight{}, err
+	}
+
+	references, err := dt.findReferencesInSources(handle, []compilercommon.InputSource{source}, target, cancelationHandle)
+	if err != nil {
+		return []protocol.DocumentHighlight{}, err
+	}
+
+	isValue := isValueReference(target)
+	writeTargets := assignmentTargets(rootNode)
+
+	highlights := make([]protocol.DocumentHighlight, 0, len(references))
+	for _, reference := range references {
+		documentRange, err := dt.convertRange(reference.sourceRange)
+		if err != nil {
+			continue
+		}
+
+		kind := protocol.DocumentHighlightText
+		if isValue {
+			kind = protocol.DocumentHighlightRead
+			if reference.isDeclaration || isAssignmentTarget(reference.sourceRange, writeTargets) {
+				kind = protocol.DocumentHighlightWrite
+			}
+		}
+
+		highlights = append(highlights, protocol.DocumentHighlight{
+			Range: documentRange,
+			Kind:  kind,
+		})
+	}
+
+	return highlights, nil
+}
+
+// isValueReference returns true if the given range information refers to a value (a variable, member,
+// parameter, etc) that can be read or written, rather than a type or module.
+func isValueReference(target grok.RangeInformation) bool {
+	switch target.Kind {
+	case grok.LocalValue:
+		return true
+
+	case grok.NamedReference:
+		_, isType := target.NamedReference.Type()
+		return !isType
+
+	default:
+		return false
+	}
+}
+
+// assignmentTargets returns the parse nodes of all expressions assigned to in the given parse tree, such
+// as the left hand side of assignment and arrow statements.
+func assignmentTargets(rootNode *parseNode) []*parseNode {
+	targets := []*parseNode{}
+	rootNode.walk(func(node *parseNode) bool {
+		switch node.nodeType {
+		case sourceshape.NodeTypeAssignStatement:
+			targets = append(targets, node.getChildren(sourceshape.NodeAssignStatementName)...)
+
+		case sourceshape.NodeTypeArrowStatement:
+			targets = append(targets, node.getChildren(sourceshape.NodeArrowStatementDestination, sourceshape.NodeArrowStatementRejection)...)
+		}
+
+		return true
+	})
+	return targets
+}
+
+// isAssignmentTarget returns true if the given name range is the entity being assigned by any of the given
+// assignment target expressions. As a target can be a member access (`a.b = c`), only a name found at the
+// very end of the target expression is considered to be written.
+func isAssignmentTarget(nameRange compilercommon.SourceRange, targets []*parseNode) bool {
+	endRune, err := nameRange.End().RunePosition()
+	if err != nil {
+		return false
+	}
+
+	for _, target := range targets {
+		_, targetEndRune, ok := target.runeRange()
+		if ok && target.containsPosition(endRune) && targetEndRune == endRune {
+			return true
+		}
+	}
+
+	return false
+}
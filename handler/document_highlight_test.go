@@ -0,0 +1,74 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+const documentHighlightSource = `class SomeClass {}
+
+function First(someInstance SomeClass) {
+	var value = 1
+	value = value + 1
+	First(someInstance)
+}
+`
+
+type documentHighlightTest struct {
+	name string
+
+	// text and occurrence are the text under the cursor and its occurrence in the document.
+	text       string
+	occurrence int
+
+	expected []string
+}
+
+var documentHighlightTests = []documentHighlightTest{
+	{"local variable", "value", 0, []string{"3:5 write", "4:1 write", "4:9 read"}},
+	{"parameter", "someInstance", 1, []string{"2:15 write", "5:7 read"}},
+	{"function", "First", 1, []string{"2:9 write", "5:1 read"}},
+	{"type", "SomeClass", 0, []string{"0:6 text", "2:28 text"}},
+}
+
+// describeDocumentHighlights describes the given highlights as their start position followed by their kind, sorted.
+func describeDocumentHighlights(highlights []protocol.DocumentHighlight) []string {
+	kindNames := map[protocol.DocumentHighlightKind]string{
+		protocol.DocumentHighlightText:  "text",
+		protocol.DocumentHighlightRead:  "read",
+		protocol.DocumentHighlightWrite: "write",
+	}
+
+	described := make([]string, 0, len(highlights))
+	for _, highlight := range highlights {
+		described = append(described, fmt.Sprintf("%v:%v %s", highlight.Range.Start.Line, highlight.Range.Start.Column, kindNames[highlight.Kind]))
+	}
+
+	sort.Strings(described)
+	return described
+}
+
+func TestDocumentHighlights(t *testing.T) {
+	ts := newTestServer(t, map[string]string{"main.seru": documentHighlightSource})
+	defer ts.close()
+	ts.open("main.seru")
+
+	for _, test := range documentHighlightTests {
+		highlights := []protocol.DocumentHighlight{}
+		ts.call(protocol.DocumentHighlightRequest, protocol.DocumentHighlightParams{
+			TextDocumentPositionParams: ts.position("main.seru", test.text, test.occurrence, 0),
+		}, &highlights)
+
+		if found := describeDocumentHighlights(highlights); !reflect.DeepEqual(found, test.expected) {
+			t.Errorf("%s: expected highlights %v, found %v", test.name, test.expected, found)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"path"
+	"strings"
+
+	"github.com/serulian/compiler/compilercommon"
+	"github.com/serulian/compiler/packageloader"
+	"github.com/serulian/compiler/parser/shared"
+	"github.com/serulian/compiler/sourceshape"
+	"github.com/serulian/compiler/vcs"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+// webIDLFileExtension defines the file extension for WebIDL files.
+const webIDLFileExtension = ".webidl"
+
+// documentLinks returns a link for the source of each import found in the document with the given URI. The
+// targets of the links are found when the links are resolved.
+func (dt *documentTracker) documentLinks(uri string) ([]protocol.DocumentLink, error) {
+	rootNode, _, contents, err := dt.parseDocument(uri)
+	if err != nil {
+		return []protocol.DocumentLink{}, err
+	}
+
+	links := []protocol.DocumentLink{}
+	for _, importNode := range rootNode.getChildren(sourceshape.NodePredicateChild) {
+		if importNode.nodeType != sourceshape.NodeTypeImport {
+			continue
+		}
+
+		importSource, hasSource := importNode.getProperty(sourceshape.NodeImportPredicateSource)
+		startRune, endRune, ok := importNode.runeRange()
+		if !hasSource || !ok || endRune >= len(contents) {
+			continue
+		}
+
+		// Skip library aliases, which have no path of their own.
+		_, importType, err := shared.ParseImportValue(importSource)
+		if err != nil || importType == shared.ParsedImportTypeAlias {
+			continue
+		}
+
+		sourceOffset := strings.Index(string(contents[startRune:endRune+1]), importSource)
+		if sourceOffset < 0 {
+			continue
+		}
+
+		sourceOffset = startRune + sourceOffset
+		importKind, _ := importNode.getProperty(sourceshape.NodeImportPredicateKind)
+		links = append(links, protocol.DocumentLink{
+			Range: protocol.Range{
+				Start: positionForOffset(string(contents), sourceOffset, dt.positionEncoding),
+				End:   positionForOffset(string(contents), sourceOffset+len(importSource), dt.positionEncoding),
+			},
+			Data: map[string]interface{}{
+				"uri":    uri,
+				"kind":   importKind,
+				"source": importSource,
+			},
+		})
+	}
+
+	return links, nil
+}
+
+// resolveDocumentLink finds the target of the given document link to an import: local imports resolve to the
+// imported module file or package directory, VCS imports to the directory into which the package is checked out
+// and WebIDL imports to the imported WebIDL file.
+func (dt *documentTracker) resolveDocumentLink(link protocol.DocumentLink) protocol.DocumentLink {
+	dataMap, ok := link.Data.(map[string]interface{})
+	if !ok {
+		return link
+	}
+
+	uri, hasURI := dataMap["uri"].(string)
+	importKind, hasKind := dataMap["kind"].(string)
+	importSource, hasSource := dataMap["source"].(string)
+	if !hasURI || !hasKind || !hasSource {
+		return link
+	}
+
+	documentPath, err := dt.uriToPath(uri)
+	if err != nil {
+		return link
+	}
+
+	importPath, importType, err := shared.ParseImportValue(importSource)
+	if err != nil {
+		return link
+	}
+
+	var targetPath = ""
+	var tooltip = ""
+
+	switch importType {
+	case shared.ParsedImportTypeVCS:
+		packageDirectory := dt.VCSPackageDirectory(packageloader.Entrypoint(documentPath))
+		checkoutDirectory, err := vcs.GetVCSCheckoutDirectory(importPath, packageDirectory, dt.settingsFor(documentPath).vcsDevelopmentDirectories...)
+		if err != nil {
+			return link
+		}
+
+		targetPath = path.Join(checkoutDirectory, vcsSubpackage(importPath))
+		tooltip = "Open VCS package checkout"
+
+	case shared.ParsedImportTypeLocal:
+		fileExtension := sourceshape.SerulianFileExtension
+		if importKind == webIDLImportKind {
+			fileExtension = webIDLFileExtension
+		}
+
+		// Imports refer to a single file if it exists, and to the package directory otherwise.
+		packagePath := path.Join(path.Dir(documentPath), importPath)
+		if exists, _ := dt.Exists(packagePath + fileExtension); exists {
+			targetPath = packagePath + fileExtension
+			tooltip = "Open module"
+			if importKind == webIDLImportKind {
+				tooltip = "Open WebIDL file"
+			}
+		} else if exists, _ := dt.Exists(packagePath); exists {
+			targetPath = packagePath
+			tooltip = "Open package directory"
+		}
+	}
+
+	if targetPath == "" {
+		return link
+	}
+
+	target, _ := dt.sourceToURI(compilercommon.InputSource(targetPath))
+	link.Target = &target
+	link.Tooltip = tooltip
+	return link
+}
+
+// vcsSubpackage returns the path of the subpackage referenced by the given VCS import path, if any.
+func vcsSubpackage(importPath string) string {
+	index := strings.Index(importPath, "//")
+	if index < 0 {
+		return ""
+	}
+
+	subpackage := importPath[index+2:]
+	if end := strings.IndexAny(subpackage, "@:"); end >= 0 {
+		subpackage = subpackage[0:end]
+	}
+
+	return subpackage
+}
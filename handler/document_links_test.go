@@ -0,0 +1,35 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"testing"
+)
+
+type vcsSubpackageTest struct {
+	name       string
+	importPath string
+	expected   string
+}
+
+var vcsSubpackageTests = []vcsSubpackageTest{
+	{"no subpackage", "github.com/serulian/corelib", ""},
+	{"no subpackage with tag", "github.com/serulian/corelib@v1.0.0", ""},
+	{"no subpackage with branch", "github.com/serulian/corelib:master", ""},
+	{"subpackage", "github.com/serulian/corelib//collections", "collections"},
+	{"nested subpackage", "github.com/serulian/corelib//collections/lists", "collections/lists"},
+	{"subpackage with tag", "github.com/serulian/corelib//collections@v1.0.0", "collections"},
+	{"subpackage with branch", "github.com/serulian/corelib//collections:master", "collections"},
+	{"empty subpackage", "github.com/serulian/corelib//@v1.0.0", ""},
+}
+
+func TestVCSSubpackage(t *testing.T) {
+	for _, test := range vcsSubpackageTests {
+		subpackage := vcsSubpackage(test.importPath)
+		if subpackage != test.expected {
+			t.Errorf("%s: expected subpackage %q, found %q", test.name, test.expected, subpackage)
+		}
+	}
+}
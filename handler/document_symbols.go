@@ -0,0 +1,261 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/serulian/compiler/compilercommon"
+	"github.com/serulian/compiler/graphs/typegraph"
+	"github.com/serulian/compiler/grok"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+// documentSymbols returns the symbols defined in the document with the given URI, as a hierarchy of
+// module -> types -> members.
+func (dt *documentTracker) documentSymbols(uri string, handle grok.Handle) ([]protocol.DocumentSymbol, error) {
+	path, err := dt.uriToPath(uri)
+	if err != nil {
+		return []protocol.DocumentSymbol{}, err
+	}
+
+	contents, err := dt.LoadSourceFile(path)
+	if err != nil {
+		return []protocol.DocumentSymbol{}, err
+	}
+
+	source := compilercommon.InputSource(path)
+	module, found, err := moduleForSource(handle, source)
+	if err != nil {
+		return []protocol.DocumentSymbol{}, err
+	}
+
+	if !found {
+		return []protocol.DocumentSymbol{}, nil
+	}
+
+	children := []protocol.DocumentSymbol{}
+	for _, typeDecl := range module.Types() {
+		if typeSymbol, ok := dt.typeDocumentSymbol(typeDecl, source, contents); ok {
+			children = append(children, typeSymbol)
+		}
+	}
+
+	for _, member := range module.Members() {
+		if memberSymbol, ok := dt.memberDocumentSymbol(member, source, contents); ok {
+			children = append(children, memberSymbol)
+		}
+	}
+
+	documentRange := fullDocumentRange(string(contents), dt.positionEncoding)
+	return []protocol.DocumentSymbol{
+		{
+			Name:           module.Name(),
+			Kind:           protocol.SymbolModule,
+			Range:          documentRange,
+			SelectionRange: protocol.Range{Start: documentRange.Start, End: documentRange.Start},
+			Children:       sortDocumentSymbols(children),
+		},
+	}, nil
+}
+
+// moduleForSource returns the module defined by the given source, if any.
+func moduleForSource(handle grok.Handle, source compilercommon.InputSource) (typegraph.TGModule, bool, error) {
+	symbols, err := handle.FindSymbols("")
+	if err != nil {
+		return typegraph.TGModule{}, false, err
+	}
+
+	for _, symbol := range symbols {
+		if symbol.Kind == grok.ModuleSymbol && symbol.Module.Path() == source {
+			return *symbol.Module, true, nil
+		}
+	}
+
+	return typegraph.TGModule{}, false, nil
+}
+
+// memberDeclaredAt returns the member of a type or module whose declaration starts at the given rune position
+// in the given source, if any.
+func memberDeclaredAt(handle grok.Handle, source compilercommon.InputSource, startRune int) (typegraph.TGMember, bool) {
+	module, found, err := moduleForSource(handle, source)
+	if err != nil || !found {
+		return typegraph.TGMember{}, false
+	}
+
+	members := module.Members()
+	for _, typeDecl := range module.Types() {
+		members = append(members, typeDecl.MembersAndOperators()...)
+	}
+
+	for _, member := range members {
+		for _, sourceRange := range member.SourceRanges() {
+			memberStart, err := sourceRange.Start().RunePosition()
+			if err == nil && sourceRange.Source() == source && memberStart == startRune {
+				return member, true
+			}
+		}
+	}
+
+	return typegraph.TGMember{}, false
+}
+
+// typeDocumentSymbol returns the document symbol for the given type, if it is defined in the source.
+func (dt *documentTracker) typeDocumentSymbol(typeDecl typegraph.TGTypeDecl, source compilercommon.InputSource, contents []byte) (protocol.DocumentSymbol, bool) {
+	documentSymbol, ok := dt.declarationDocumentSymbol(typeDecl.Name(), typeDecl.SourceRanges(), source, contents)
+	if !ok {
+		return protocol.DocumentSymbol{}, false
+	}
+
+	children := []protocol.DocumentSymbol{}
+	for _, member := range typeDecl.MembersAndOperators() {
+		if memberSymbol, ok := dt.memberDocumentSymbol(member, source, contents); ok {
+			children = append(children, memberSymbol)
+		}
+	}
+
+	documentSymbol.Kind = symbolKindForType(typeDecl)
+	documentSymbol.Detail = typeDetail(typeDecl)
+	documentSymbol.Children = sortDocumentSymbols(children)
+	return documentSymbol, true
+}
+
+// memberDocumentSymbol returns the document symbol for the given member, if it is defined in the source.
+// Members inherited or composed from other types have no range in the source and are skipped.
+func (dt *documentTracker) memberDocumentSymbol(member typegraph.TGMember, source compilercommon.InputSource, contents []byte) (protocol.DocumentSymbol, bool) {
+	documentSymbol, ok := dt.declarationDocumentSymbol(member.Name(), member.SourceRanges(), source, contents)
+	if !ok {
+		return protocol.DocumentSymbol{}, false
+	}
+
+	documentSymbol.Kind = symbolKindForMember(member)
+	documentSymbol.Detail = memberDetail(member)
+	return documentSymbol, true
+}
+
+// declarationDocumentSymbol returns a document symbol with the name and ranges of the declaration with the
+// given name and source ranges, if it is defined in the source. The selection range is the first occurrence
+// of the name within the declaration, or the start of the declaration if not found.
+func (dt *documentTracker) declarationDocumentSymbol(name string, sourceRanges []compilercommon.SourceRange, source compilercommon.InputSource, contents []byte) (protocol.DocumentSymbol, bool) {
+	for _, sourceRange := range sourceRanges {
+		if sourceRange.Source() != source {
+			continue
+		}
+
+		documentRange, err := dt.convertRange(sourceRange)
+		if err != nil {
+			return protocol.DocumentSymbol{}, false
+		}
+
+		selectionRange := protocol.Range{Start: documentRange.Start, End: documentRange.Start}
+
+		startRune, startErr := sourceRange.Start().RunePosition()
+		endRune, endErr := sourceRange.End().RunePosition()
+		if startErr == nil && endErr == nil && endRune < len(contents) {
+			offsets := identifierOffsets(string(contents[startRune:endRune+1]), name)
+			if len(offsets) > 0 {
+				offset := startRune + offsets[0]
+				nameRange, err := dt.convertRange(source.RangeForRunePositions(offset, offset+len(name)-1, dt))
+				if err == nil {
+					selectionRange = nameRange
+				}
+			}
+		}
+
+		return protocol.DocumentSymbol{
+			Name:           name,
+			Range:          documentRange,
+			SelectionRange: selectionRange,
+		}, true
+	}
+
+	return protocol.DocumentSymbol{}, false
+}
+
+// typeDetail returns the detail string for the given type, describing the types it references.
+func typeDetail(typeDecl typegraph.TGTypeDecl) string {
+	switch typeDecl.TypeKind() {
+	case typegraph.NominalType:
+		parentTypes := typeDecl.ParentTypes()
+		if len(parentTypes) > 0 {
+			return ": " + parentTypes[0].String()
+		}
+
+	case typegraph.AgentType:
+		if principalType, hasPrincipalType := typeDecl.PrincipalType(); hasPrincipalType {
+			return "for " + principalType.String()
+		}
+
+	case typegraph.ClassType:
+		composedAgents := typeDecl.ComposedAgents()
+		if len(composedAgents) > 0 {
+			agentNames := make([]string, 0, len(composedAgents))
+			for _, agent := range composedAgents {
+				agentNames = append(agentNames, agent.AgentType().String())
+			}
+			return "with " + strings.Join(agentNames, ", ")
+		}
+	}
+
+	return ""
+}
+
+// memberDetail returns the detail string for the given member, which is its type.
+func memberDetail(member typegraph.TGMember) string {
+	memberType := member.MemberType()
+	if memberType.IsVoid() {
+		return ""
+	}
+
+	return memberType.String()
+}
+
+// sortDocumentSymbols sorts the given document symbols by their position in the document.
+func sortDocumentSymbols(symbols []protocol.DocumentSymbol) []protocol.DocumentSymbol {
+	sort.SliceStable(symbols, func(i, j int) bool {
+		first := symbols[i].Range.Start
+		second := symbols[j].Range.Start
+		if first.Line != second.Line {
+			return first.Line < second.Line
+		}
+
+		return first.Column < second.Column
+	})
+	return symbols
+}
+
+// fullDocumentRange returns the range covering the entirety of the given contents, with columns in the
+// given position encoding.
+func fullDocumentRange(contents string, encoding protocol.PositionEncodingKind) protocol.Range {
+	lines := strings.Split(contents, "\n")
+	lastLine := lines[len(lines)-1]
+	return protocol.Range{
+		Start: protocol.Position{Line: 0, Column: 0},
+		End:   protocol.Position{Line: len(lines) - 1, Column: encodedColumn(lastLine, len(lastLine), encoding)},
+	}
+}
+
+// flattenDocumentSymbols converts the given hierarchical document symbols into a flat list of symbol
+// information, for clients that do not support hierarchical document symbols.
+func flattenDocumentSymbols(uri protocol.DocumentURI, symbols []protocol.DocumentSymbol, containerName *string) []protocol.SymbolInformation {
+	symbolInfo := []protocol.SymbolInformation{}
+	for _, symbol := range symbols {
+		symbolInfo = append(symbolInfo, protocol.SymbolInformation{
+			Name:          symbol.Name,
+			Kind:          symbol.Kind,
+			ContainerName: containerName,
+			Location: protocol.Location{
+				URI:   uri,
+				Range: symbol.Range,
+			},
+		})
+
+		name := symbol.Name
+		symbolInfo = append(symbolInfo, flattenDocumentSymbols(uri, symbol.Children, &name)...)
+	}
+	return symbolInfo
+}
@@ -0,0 +1,164 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+type fullDocumentRangeTest struct {
+	name     string
+	contents string
+	expected protocol.Position
+}
+
+var fullDocumentRangeTests = []fullDocumentRangeTest{
+	{"empty", "", protocol.Position{Line: 0, Column: 0}},
+	{"single line", "var a = 1", protocol.Position{Line: 0, Column: 9}},
+	{"trailing newline", "var a = 1\n", protocol.Position{Line: 1, Column: 0}},
+	{"multiple lines", "a\nbc\ndéf", protocol.Position{Line: 2, Column: 3}},
+	{"surrogate pair", "a\n😀", protocol.Position{Line: 1, Column: 2}},
+}
+
+func TestFullDocumentRange(t *testing.T) {
+	for _, test := range fullDocumentRangeTests {
+		documentRange := fullDocumentRange(test.contents, protocol.PositionEncodingUTF16)
+		expected := protocol.Range{Start: protocol.Position{Line: 0, Column: 0}, End: test.expected}
+		if documentRange != expected {
+			t.Errorf("%s: expected range %v, found %v", test.name, expected, documentRange)
+		}
+	}
+}
+
+// symbolAt returns a document symbol with the given name and children, starting at the given line.
+func symbolAt(name string, line int, children ...protocol.DocumentSymbol) protocol.DocumentSymbol {
+	symbolRange := protocol.Range{Start: protocol.Position{Line: line, Column: 0}, End: protocol.Position{Line: line, Column: 1}}
+	return protocol.DocumentSymbol{Name: name, Range: symbolRange, SelectionRange: symbolRange, Children: children}
+}
+
+func TestSortDocumentSymbols(t *testing.T) {
+	sorted := sortDocumentSymbols([]protocol.DocumentSymbol{symbolAt("c", 7), symbolAt("a", 1), symbolAt("b", 3)})
+	names := []string{}
+	for _, symbol := range sorted {
+		names = append(names, symbol.Name)
+	}
+
+	if expected := []string{"a", "b", "c"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected symbols sorted as %v, found %v", expected, names)
+	}
+}
+
+func TestFlattenDocumentSymbols(t *testing.T) {
+	symbols := []protocol.DocumentSymbol{
+		symbolAt("module", 0, symbolAt("SomeClass", 1, symbolAt("DoSomething", 2)), symbolAt("TopLevel", 5)),
+	}
+
+	flattened := flattenDocumentSymbols("file:///main.seru", symbols, nil)
+	found := []string{}
+	for _, symbolInfo := range flattened {
+		containerName := ""
+		if symbolInfo.ContainerName != nil {
+			containerName = *symbolInfo.ContainerName
+		}
+
+		if symbolInfo.Location.URI != "file:///main.seru" {
+			t.Errorf("Expected symbol %s to be located in the document, found %s", symbolInfo.Name, symbolInfo.Location.URI)
+		}
+
+		found = append(found, containerName+"/"+symbolInfo.Name)
+	}
+
+	expected := []string{"/module", "module/SomeClass", "SomeClass/DoSomething", "module/TopLevel"}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Expected flattened symbols %v, found %v", expected, found)
+	}
+}
+
+const documentSymbolsSource = `class SomeClass {
+	var someField int = 2
+
+	function DoSomething() int {
+		return 42
+	}
+}
+
+function TopLevel() {}
+`
+
+// describeDocumentSymbols describes the given document symbols, along with their children, in order, as their
+// path in the hierarchy followed by the start of their selection range.
+func describeDocumentSymbols(symbols []protocol.DocumentSymbol, prefix string) []string {
+	described := []string{}
+	for _, symbol := range symbols {
+		start := symbol.SelectionRange.Start
+		described = append(described, fmt.Sprintf("%s%s %v:%v", prefix, symbol.Name, start.Line, start.Column))
+		described = append(described, describeDocumentSymbols(symbol.Children, prefix+symbol.Name+"/")...)
+	}
+	return described
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	hierarchicalSupport := true
+	ts := startTestServer(t, map[string]string{"main.seru": documentSymbolsSource}, protocol.InitializeParams{
+		Capabilities: protocol.ClientCapabilities{
+			TextDocument: &protocol.TextDocumentClientCapabilities{
+				DocumentSymbol: &protocol.DocumentSymbolClientCapabilities{HierarchicalDocumentSymbolSupport: &hierarchicalSupport},
+			},
+		},
+	})
+	defer ts.close()
+	ts.open("main.seru")
+
+	symbols := []protocol.DocumentSymbol{}
+	ts.call(protocol.DocumentSymbolRequest, protocol.DocumentSymbolParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(ts.uri("main.seru"))},
+	}, &symbols)
+
+	if len(symbols) != 1 || symbols[0].Kind != protocol.SymbolModule {
+		t.Fatalf("Expected a single module symbol, found %+v", symbols)
+	}
+
+	found := describeDocumentSymbols(symbols[0].Children, "")
+	expected := []string{"SomeClass 0:6", "SomeClass/someField 1:5", "SomeClass/DoSomething 3:10", "TopLevel 8:9"}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Expected symbols %v, found %v", expected, found)
+	}
+
+	someClass := symbols[0].Children[0]
+	if someClass.Kind != protocol.SymbolClass || someClass.Range.End.Line != 6 {
+		t.Errorf("Expected a class spanning lines 0 to 6, found %+v", someClass)
+	}
+
+	if len(someClass.Children) == 2 && someClass.Children[1].Detail == "" {
+		t.Errorf("Expected the detail of the method to describe its type")
+	}
+}
+
+func TestDocumentSymbolsFlattened(t *testing.T) {
+	ts := newTestServer(t, map[string]string{"main.seru": documentSymbolsSource})
+	defer ts.close()
+	ts.open("main.seru")
+
+	symbolInfo := []protocol.SymbolInformation{}
+	ts.call(protocol.DocumentSymbolRequest, protocol.DocumentSymbolParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(ts.uri("main.seru"))},
+	}, &symbolInfo)
+
+	found := []string{}
+	for _, info := range symbolInfo {
+		if info.ContainerName != nil {
+			found = append(found, *info.ContainerName+"/"+info.Name)
+		}
+	}
+
+	expected := []string{"main/SomeClass", "SomeClass/someField", "SomeClass/DoSomething", "main/TopLevel"}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Expected symbols %v, found %v", expected, found)
+	}
+}
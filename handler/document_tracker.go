@@ -10,33 +10,27 @@ import (
 	"log"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/serulian/compiler/builder"
 	"github.com/serulian/compiler/compilercommon"
 	"github.com/serulian/compiler/formatter"
 	"github.com/serulian/compiler/grok"
 	"github.com/serulian/compiler/packageloader"
+	"github.com/serulian/compiler/sourceshape"
 
 	"github.com/serulian/serulian-langserver/protocol"
 
-	"path"
-
 	"github.com/sourcegraph/jsonrpc2"
 	cmap "github.com/streamrail/concurrent-map"
 )
 
-// MaximumBuildDuration is the maximum duration to build a handle before we timeout.
+// MaximumBuildDuration is the default maximum duration to build a handle before we timeout.
 const MaximumBuildDuration = 5 * time.Second
 
-// DiagnoseDelay is the delay waited before a document is re-parsed.
+// DiagnoseDelay is the default delay waited before a document is re-parsed.
 const DiagnoseDelay = 500 * time.Millisecond
 
-// getPackageLibraries returns the libraries to load, if any, when creating a Groker.
-func getPackageLibraries(path string) []packageloader.Library {
-	return []packageloader.Library{builder.CORE_LIBRARY}
-}
-
 // document represents a single document found in the tracker.
 type document struct {
 	// path is the local file system path of the document.
@@ -65,47 +59,129 @@ type documentTracker struct {
 	// for the document tracker's path loading for files that are *not* being tracked.
 	localPathLoader packageloader.LocalFilePathLoader
 
-	// debouncedDiagnose is a debounced-wrapped call over the diagnoseDocument function.
-	debouncedDiagnose func(data interface{})
+	// diagnostics schedules the (debounced) calls to the diagnoseDocument function.
+	diagnostics *diagnosticScheduler
+
+	// publishedDiagnostics is the set of paths for which non-empty diagnostics have been published
+	// to the client. The value indicates whether they were published by the workspace diagnose.
+	publishedDiagnostics cmap.ConcurrentMap
+
+	// workspaceFolders is the map of the folders of the workspace, keyed by root path. May be empty
+	// if there is no workspace being used.
+	workspaceFolders cmap.ConcurrentMap
+
+	// positionEncoding is the encoding of the column positions exchanged with the client, as
+	// negotiated at initialization.
+	positionEncoding protocol.PositionEncodingKind
+
+	// settingsLock guards the settings.
+	settingsLock sync.RWMutex
 
-	// vcsDevelopmentDirectories are the specified VCS development directories to be passed
-	// to Grok, if any.
-	vcsDevelopmentDirectories []string
+	// commandLineSettings holds the default settings, as specified on the command line.
+	commandLineSettings serverSettings
 
-	// workspaceRootPath is the root path of the workspace. May be empty if there is no
-	// workspace being used.
-	workspaceRootPath string
+	// clientSettings holds the settings last specified by the client.
+	clientSettings protocol.Settings
 
-	// workspaceGrok is (if defined) the workspace-wide Grok.
-	workspaceGrok *grok.Groker
+	// settings holds the current settings of the paths found outside of the workspace folders, layered
+	// from the command line settings and the client settings. The settings of each workspace folder also
+	// layer its project configuration, and are held by the folder.
+	settings serverSettings
+
+	// parsedDocuments holds the parse tree of each document at its latest parsed version, keyed by path.
+	parsedDocuments cmap.ConcurrentMap
+
+	// sourceLines holds the lines of each source at the revision last used for converting positions, keyed by
+	// path.
+	sourceLines cmap.ConcurrentMap
+
+	// sourceImports holds the imports of each source at the revision last searched for references, keyed by
+	// path.
+	sourceImports cmap.ConcurrentMap
+
+	// semanticTokenResults holds the last full semantic tokens result sent for each document, keyed by path.
+	semanticTokenResults cmap.ConcurrentMap
+
+	// lastSemanticTokensResultID is the ID of the last semantic tokens result produced.
+	lastSemanticTokensResultID uint64
 }
 
 func newDocumentTracker(vcsDevelopmentDirectories []string) *documentTracker {
-	return &documentTracker{
-		documents:         cmap.New(),
-		localPathLoader:   packageloader.LocalFilePathLoader{},
-		debouncedDiagnose: debounce(diagnoseDocument, DiagnoseDelay),
+	dt := &documentTracker{
+		documents:       cmap.New(),
+		localPathLoader: packageloader.LocalFilePathLoader{},
+
+		publishedDiagnostics: cmap.New(),
 
-		vcsDevelopmentDirectories: vcsDevelopmentDirectories,
+		workspaceFolders: cmap.New(),
+		positionEncoding: protocol.PositionEncodingUTF16,
 
-		workspaceRootPath: "",
-		workspaceGrok:     nil,
+		commandLineSettings: defaultSettings(vcsDevelopmentDirectories),
+		settings:            defaultSettings(vcsDevelopmentDirectories),
+
+		parsedDocuments:      cmap.New(),
+		sourceLines:          cmap.New(),
+		sourceImports:        cmap.New(),
+		semanticTokenResults: cmap.New(),
 	}
+
+	dt.diagnostics = newDiagnosticScheduler(diagnoseDocument, func(path string) time.Duration {
+		return dt.settingsFor(path).diagnoseDelay
+	})
+	return dt
 }
 
-// initializeWorkspace initializes the document tracker over the given workspace root.
-func (dt *documentTracker) initializeWorkspace(ctx context.Context, conn *jsonrpc2.Conn, workspaceRootPath string) {
-	dt.workspaceRootPath = workspaceRootPath
-	if workspaceRootPath != "" {
-		dt.workspaceGrok = grok.NewGrokerWithConfig(grok.Config{
-			EntrypointPath:            workspaceRootPath,
-			VCSDevelopmentDirectories: dt.vcsDevelopmentDirectories,
-			Libraries:                 getPackageLibraries(workspaceRootPath),
-			PathLoader:                dt,
-			ScopePaths:                []compilercommon.InputSource{},
-			MaximumBuildDuration:      MaximumBuildDuration,
-		})
-		dt.debouncedDiagnose(diagnoseParams{dt, workspaceRootPath, -1, true, ctx, conn})
+// initializeWorkspace initializes the document tracker over the given workspace folder roots.
+func (dt *documentTracker) initializeWorkspace(ctx context.Context, conn *jsonrpc2.Conn, workspaceRootPaths []string) {
+	for _, workspaceRootPath := range workspaceRootPaths {
+		dt.addWorkspaceFolder(ctx, conn, workspaceRootPath)
+	}
+}
+
+// newGroker creates a Groker over the given entrypoint path, whose sources are loaded via the document tracker,
+// configured with the settings of the workspace folder containing it.
+func (dt *documentTracker) newGroker(entrypointPath string, scopePaths []compilercommon.InputSource) *grok.Groker {
+	settings := dt.settingsFor(entrypointPath)
+	return grok.NewGrokerWithConfig(grok.Config{
+		EntrypointPath:            entrypointPath,
+		VCSDevelopmentDirectories: settings.vcsDevelopmentDirectories,
+		Libraries:                 dt.getPackageLibraries(entrypointPath),
+		PathLoader:                dt,
+		ScopePaths:                scopePaths,
+		MaximumBuildDuration:      settings.buildTimeout,
+	})
+}
+
+// replaceGrokers replaces the Grokers of the workspace folders and documents for which isAffected returns true,
+// so that their stale handles are discarded and new ones built with the current sources and settings, and
+// re-diagnoses them.
+func (dt *documentTracker) replaceGrokers(ctx context.Context, conn *jsonrpc2.Conn, isAffected func(groker *grok.Groker) bool) {
+	for _, folder := range dt.getWorkspaceFolders() {
+		if !folder.isAffected(isAffected) {
+			continue
+		}
+
+		log.Printf("Replacing Grokers for workspace folder %s", folder.rootPath)
+		folder.grokers = dt.newFolderGrokers(folder)
+		dt.workspaceFolders.Set(folder.rootPath, folder)
+		dt.diagnostics.schedule(diagnoseParams{dt, folder.rootPath, -1, true, ctx, conn})
+	}
+
+	for _, path := range dt.documents.Keys() {
+		currentValue, exists := dt.documents.Get(path)
+		if !exists {
+			continue
+		}
+
+		current := currentValue.(document)
+		if current.groker == nil || !isAffected(current.groker) {
+			continue
+		}
+
+		log.Printf("Replacing Groker for document %s", path)
+		current.groker = dt.newGroker(path, []compilercommon.InputSource{compilercommon.InputSource(path)})
+		dt.documents.Set(path, current)
+		dt.diagnostics.schedule(diagnoseParams{dt, path, current.version, false, ctx, conn})
 	}
 }
 
@@ -146,14 +222,7 @@ func (dt *documentTracker) openDocument(ctx context.Context, conn *jsonrpc2.Conn
 		return
 	}
 
-	documentGroker := grok.NewGrokerWithConfig(grok.Config{
-		EntrypointPath:            path,
-		VCSDevelopmentDirectories: dt.vcsDevelopmentDirectories,
-		Libraries:                 getPackageLibraries(path),
-		PathLoader:                dt,
-		ScopePaths:                []compilercommon.InputSource{compilercommon.InputSource(path)},
-		MaximumBuildDuration:      MaximumBuildDuration,
-	})
+	documentGroker := dt.newGroker(path, []compilercommon.InputSource{compilercommon.InputSource(path)})
 
 	dt.documents.Set(path, document{
 		path:                 path,
@@ -163,18 +232,26 @@ func (dt *documentTracker) openDocument(ctx context.Context, conn *jsonrpc2.Conn
 		codeContextOrActions: cmap.New(),
 	})
 
-	dt.debouncedDiagnose(diagnoseParams{dt, path, version, false, ctx, conn})
+	dt.diagnostics.schedule(diagnoseParams{dt, path, version, false, ctx, conn})
 }
 
-// updateDocument updates the contents of the document with the given URI. If the document is not being
-// tracked, does nothing.
-func (dt *documentTracker) updateDocument(ctx context.Context, conn *jsonrpc2.Conn, uri string, contents string, version int) {
+// updateDocument applies the given content changes, in order, to the document with the given URI. If the
+// document is not being tracked, does nothing. If any of the changes cannot be applied, the document is left
+// unchanged.
+func (dt *documentTracker) updateDocument(ctx context.Context, conn *jsonrpc2.Conn, uri string, changes []protocol.TextDocumentContentChangeEvent, version int) {
 	path, err := dt.uriToPath(uri)
 	if err != nil {
 		return
 	}
 
-	if !dt.documents.Has(path) {
+	currentValue, exists := dt.documents.Get(path)
+	if !exists {
+		return
+	}
+
+	contents, err := applyContentChanges(currentValue.(document).contents, changes, dt.positionEncoding)
+	if err != nil {
+		log.Printf("Could not apply changes to document %s at version %v: %v", uri, version, err)
 		return
 	}
 
@@ -197,17 +274,23 @@ func (dt *documentTracker) updateDocument(ctx context.Context, conn *jsonrpc2.Co
 		}
 	})
 
-	dt.debouncedDiagnose(diagnoseParams{dt, path, version, false, ctx, conn})
+	dt.diagnostics.schedule(diagnoseParams{dt, path, version, false, ctx, conn})
 }
 
-// closeDocument stops tracking the document with the given URI.
-func (dt *documentTracker) closeDocument(uri string) {
+// closeDocument stops tracking the document with the given URI, clearing any diagnostics published for it.
+func (dt *documentTracker) closeDocument(ctx context.Context, conn *jsonrpc2.Conn, uri string) {
 	path, err := dt.uriToPath(uri)
 	if err != nil {
 		return
 	}
 
 	dt.documents.Remove(path)
+	dt.parsedDocuments.Remove(path)
+	dt.sourceLines.Remove(path)
+	dt.sourceImports.Remove(path)
+	dt.semanticTokenResults.Remove(path)
+	dt.diagnostics.forget(path)
+	dt.clearAllDiagnostics(ctx, conn, path)
 }
 
 // getDocumentAtVersion returns the document at the specified version, for the specified path, if any.
@@ -221,6 +304,29 @@ func (dt *documentTracker) getDocumentAtVersion(path string, version int) (docum
 	return current, current.version == version
 }
 
+// sourceRevision identifies a revision of a source: either a version of a tracked document or a revision of
+// the file on disk.
+type sourceRevision struct {
+	tracked    bool
+	revisionID int64
+}
+
+// currentRevision returns the current revision of the source with the given path, along with the document
+// for the source, if it is being tracked.
+func (dt *documentTracker) currentRevision(path string) (sourceRevision, document, bool, error) {
+	if currentValue, exists := dt.documents.Get(path); exists {
+		current := currentValue.(document)
+		return sourceRevision{true, int64(current.version)}, current, true, nil
+	}
+
+	revisionID, err := dt.localPathLoader.GetRevisionID(path)
+	if err != nil {
+		return sourceRevision{}, document{}, false, err
+	}
+
+	return sourceRevision{false, revisionID}, document{}, false, nil
+}
+
 // getGrokHandle returns the Grok handle using the given URI as the root source path.
 func (dt *documentTracker) getGrokHandle(uri string, freshnessOption grok.HandleFreshnessOption) (grok.Handle, error) {
 	handle, _, err := dt.getGrokHandleAndDocument(uri, freshnessOption)
@@ -270,9 +376,19 @@ func (dt *documentTracker) convertRange(sourceRange compilercommon.SourceRange)
 		return protocol.Range{}, err
 	}
 
+	start, err := dt.documentPosition(sourceRange.Source(), startLine, startCol)
+	if err != nil {
+		return protocol.Range{}, err
+	}
+
+	end, err := dt.documentPosition(sourceRange.Source(), endLine, endCol+1)
+	if err != nil {
+		return protocol.Range{}, err
+	}
+
 	return protocol.Range{
-		Start: protocol.Position{startLine, startCol},
-		End:   protocol.Position{endLine, endCol + 1},
+		Start: start,
+		End:   end,
 	}, nil
 }
 
@@ -298,7 +414,7 @@ func (dt *documentTracker) convertRanges(sourceRanges []compilercommon.SourceRan
 	return locations
 }
 
-// getLineText returns the text found on the line of the given position before its column position.
+// getLineText returns the text found on the line of the given position before its (byte) column position.
 func (dt *documentTracker) getLineText(uri string, lineNumber int, colPosition int) (string, error) {
 	path, err := dt.uriToPath(uri)
 	if err != nil {
@@ -325,54 +441,97 @@ func (dt *documentTracker) getLineText(uri string, lineNumber int, colPosition i
 
 // formatDocument formats the document found at the given URI, return a set of edits.
 func (dt *documentTracker) formatDocument(uri string) []protocol.TextEdit {
+	_, edits := dt.formatDocumentContents(uri)
+	return edits
+}
+
+// formatDocumentContents formats the document found at the given URI, returning the contents formatted along
+// with the set of edits.
+func (dt *documentTracker) formatDocumentContents(uri string) (string, []protocol.TextEdit) {
 	path, err := dt.uriToPath(uri)
 	if err != nil {
-		return []protocol.TextEdit{}
+		return "", []protocol.TextEdit{}
 	}
 
 	currentValue, exists := dt.documents.Get(path)
 	if !exists {
-		return []protocol.TextEdit{}
+		return "", []protocol.TextEdit{}
 	}
 
 	current := currentValue.(document)
 	if len(current.contents) == 0 {
-		return []protocol.TextEdit{}
+		return current.contents, []protocol.TextEdit{}
 	}
 
 	formatted, err := formatter.FormatSource(current.contents)
 	if err != nil {
 		log.Printf("Error when trying to format source for URI %s: %v", uri, err)
-		return []protocol.TextEdit{}
+		return current.contents, []protocol.TextEdit{}
 	}
 
 	// Skip if nothing has changed.
 	if formatted == current.contents {
-		return []protocol.TextEdit{}
+		return current.contents, []protocol.TextEdit{}
+	}
+
+	return current.contents, computeTextEdits(current.contents, formatted, dt.positionEncoding)
+}
+
+// formatDocumentRange formats the document found at the given URI, returning only those edits intersecting
+// the given range, clipped to it so that no text outside of it is changed.
+func (dt *documentTracker) formatDocumentRange(uri string, documentRange protocol.Range) []protocol.TextEdit {
+	contents, edits := dt.formatDocumentContents(uri)
+	return clipTextEdits(contents, edits, documentRange, dt.positionEncoding)
+}
+
+// workspaceSources returns the Serulian source files found in the given Grok handle that are part of
+// the workspace, which excludes any sources found under the VCS package directory.
+func (dt *documentTracker) workspaceSources(handle grok.Handle) ([]compilercommon.InputSource, error) {
+	symbols, err := handle.FindSymbols("")
+	if err != nil {
+		return []compilercommon.InputSource{}, err
+	}
+
+	sources := []compilercommon.InputSource{}
+	for _, symbol := range symbols {
+		if symbol.Kind != grok.ModuleSymbol {
+			continue
+		}
+
+		source := symbol.Module.Path()
+		if dt.isWorkspaceSource(source) {
+			sources = append(sources, source)
+		}
 	}
 
-	lines := strings.Split(current.contents, "\n")
-	lastLine := len(lines) - 1
-	lastLineLength := len(lines[len(lines)-1])
+	return sources, nil
+}
+
+// isWorkspaceSource returns true if the given source is a Serulian source file found in a folder of the workspace,
+// outside of its VCS package directory and included by its project configuration. If there is no workspace, only those documents being tracked are considered
+// to be part of the workspace.
+func (dt *documentTracker) isWorkspaceSource(source compilercommon.InputSource) bool {
+	sourcePath := string(source)
+	if !strings.HasSuffix(sourcePath, sourceshape.SerulianFileExtension) {
+		return false
+	}
 
-	changeAll := protocol.TextEdit{
-		NewText: formatted,
-		Range: protocol.Range{
-			protocol.Position{0, 0},
-			protocol.Position{lastLine, lastLineLength + 1},
-		},
+	if dt.workspaceFolders.IsEmpty() {
+		return dt.documents.Has(sourcePath)
 	}
-	return []protocol.TextEdit{changeAll}
+
+	folder, found := dt.workspaceFolderFor(sourcePath)
+	return found && !strings.HasPrefix(sourcePath, folder.vcsPackageDirectory()+"/") && folder.includes(sourcePath)
 }
 
+// VCSPackageDirectory returns the VCS package directory of the workspace folder containing the given
+// entrypoint, if any, so that all sources of a folder share the same checked out packages.
 func (dt *documentTracker) VCSPackageDirectory(entrypoint packageloader.Entrypoint) string {
-	workspaceRootDirectory := dt.workspaceRootPath
-	if dt.IsSourceFile(workspaceRootDirectory) {
-		workspaceRootDirectory = path.Dir(workspaceRootDirectory)
+	if folder, found := dt.workspaceFolderFor(entrypoint.Path()); found {
+		return folder.vcsPackageDirectory()
 	}
 
-	workspacePackageDirectory := path.Join(workspaceRootDirectory, packageloader.SerulianPackageDirectory)
-	return workspacePackageDirectory
+	return dt.localPathLoader.VCSPackageDirectory(entrypoint)
 }
 
 func (dt *documentTracker) LoadSourceFile(path string) ([]byte, error) {
@@ -410,3 +569,35 @@ func (dt *documentTracker) IsSourceFile(path string) bool {
 func (dt *documentTracker) LoadDirectory(path string) ([]packageloader.DirectoryEntry, error) {
 	return dt.localPathLoader.LoadDirectory(path)
 }
+
+func (dt *documentTracker) RunePositionToLineAndCol(runePosition int, path compilercommon.InputSource, sourceOption compilercommon.SourceMappingOption) (int, int, error) {
+	contents, err := dt.LoadSourceFile(string(path))
+	if err != nil {
+		return -1, -1, err
+	}
+
+	return compilercommon.CreateSourcePositionMapper(contents).RunePositionToLineAndCol(runePosition)
+}
+
+func (dt *documentTracker) LineAndColToRunePosition(lineNumber int, colPosition int, path compilercommon.InputSource, sourceOption compilercommon.SourceMappingOption) (int, error) {
+	contents, err := dt.LoadSourceFile(string(path))
+	if err != nil {
+		return -1, err
+	}
+
+	return compilercommon.CreateSourcePositionMapper(contents).LineAndColToRunePosition(lineNumber, colPosition)
+}
+
+func (dt *documentTracker) TextForLine(lineNumber int, path compilercommon.InputSource, sourceOption compilercommon.SourceMappingOption) (string, error) {
+	contents, err := dt.LoadSourceFile(string(path))
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(string(contents), "\n")
+	if lineNumber >= len(lines) {
+		return "", fmt.Errorf("Invalid line %v for path %s", lineNumber, path)
+	}
+
+	return lines[lineNumber], nil
+}
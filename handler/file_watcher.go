@@ -0,0 +1,377 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"context"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/serulian/compiler/compilercommon"
+	"github.com/serulian/compiler/sourceshape"
+
+	"github.com/serulian/serulian-langserver/protocol"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// FileWatchDelay is the delay waited after the last change to a file on disk before the changes are handled,
+// so that bulk changes (such as switching branches) are handled together.
+const FileWatchDelay = 250 * time.Millisecond
+
+// fileWatcher watches directories on disk for changes to Serulian and WebIDL files, and project configuration
+// files. Used as a fallback for clients that do not support watching files themselves.
+type fileWatcher struct {
+	// watcher is the underlying file system watcher.
+	watcher *fsnotify.Watcher
+
+	// ignorePatterns are the glob patterns matched against each file and directory found, as described by
+	// matchesIgnorePattern; those matching are not watched.
+	ignorePatterns []string
+
+	// watchedLock guards the roots and directories.
+	watchedLock sync.Mutex
+
+	// roots are the root directories being watched: those of the workspace folders and their VCS package
+	// directories. A root is found once for each folder watching it.
+	roots []string
+
+	// directories is the set of the directories being watched, found under the roots.
+	directories map[string]bool
+
+	// pendingLock guards the pending map and closed.
+	pendingLock sync.Mutex
+
+	// closed indicates whether the watcher was closed, after which pending changes are dropped rather than
+	// handed to the changes handler.
+	closed bool
+
+	// pending is the map of changes not yet handled, keyed by path.
+	pending map[string]protocol.FileChangeType
+
+	// notify is the debounced call which hands the pending changes to the changes handler.
+	notify func(data interface{})
+
+	// handleChanges is the function invoked with the changes found.
+	handleChanges func(changes map[string]protocol.FileChangeType)
+}
+
+// startFileWatcher starts watching the folders of the workspace and their VCS package directories for changes
+// to files on disk, feeding the changes found to the document tracker in the same manner as those reported by
+// the client. Must be called with the synchronousLock held.
+func (h *SerulianLangServerHandler) startFileWatcher(ctx context.Context, conn *jsonrpc2.Conn) {
+	dt := h.documentTracker
+	watcher, err := newFileWatcher(h.fileWatchIgnorePatterns, func(changes map[string]protocol.FileChangeType) {
+		events := make([]protocol.FileEvent, 0, len(changes))
+		for changedPath, changeType := range changes {
+			uri, ok := dt.sourceToURI(compilercommon.InputSource(changedPath))
+			if !ok {
+				continue
+			}
+
+			events = append(events, protocol.FileEvent{URI: uri, Type: changeType})
+		}
+
+		h.synchronousLock.Lock()
+		defer h.synchronousLock.Unlock()
+
+		// Changes found while shutting down are dropped, as no new work is accepted.
+		if h.getState() == stateShuttingDown {
+			return
+		}
+
+		log.Printf("Watched files changed on disk: %v\n", events)
+		dt.watchedFilesChanged(ctx, conn, events)
+	})
+	if err != nil {
+		log.Printf("Could not start file watcher: %v", err)
+		return
+	}
+
+	for _, folder := range dt.getWorkspaceFolders() {
+		watcher.watchFolder(folder)
+	}
+
+	h.fileWatcher = watcher
+}
+
+// newFileWatcher creates a file watcher, which watches no directories until told to do so. The given handler is
+// invoked with the changes found, once no further changes have occurred for the FileWatchDelay.
+func newFileWatcher(ignorePatterns []string, handleChanges func(changes map[string]protocol.FileChangeType)) (*fileWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	fw := &fileWatcher{
+		watcher:        watcher,
+		ignorePatterns: ignorePatterns,
+		directories:    map[string]bool{},
+		pending:        map[string]protocol.FileChangeType{},
+		handleChanges:  handleChanges,
+	}
+
+	fw.notify, _ = debounce(fw.flushPending, func() time.Duration { return FileWatchDelay })
+
+	go fw.run()
+	return fw, nil
+}
+
+// close stops the file watcher. Any changes still pending are dropped.
+func (fw *fileWatcher) close() {
+	fw.pendingLock.Lock()
+	fw.closed = true
+	fw.pending = map[string]protocol.FileChangeType{}
+	fw.pendingLock.Unlock()
+
+	err := fw.watcher.Close()
+	if err != nil {
+		log.Printf("Could not close file watcher: %v", err)
+	}
+}
+
+// run processes the events of the underlying file system watcher until it is closed.
+func (fw *fileWatcher) run() {
+	for {
+		select {
+		case event, ok := <-fw.watcher.Events:
+			if !ok {
+				return
+			}
+
+			fw.handleEvent(event)
+
+		case err, ok := <-fw.watcher.Errors:
+			if !ok {
+				return
+			}
+
+			log.Printf("Got error from file watcher: %v", err)
+		}
+	}
+}
+
+// handleEvent records the change represented by the given file system event, if any.
+func (fw *fileWatcher) handleEvent(event fsnotify.Event) {
+	if fw.isIgnored(event.Name) {
+		return
+	}
+
+	switch {
+	case event.Op&fsnotify.Create == fsnotify.Create:
+		// New directories must be watched as well, and any files already created under them reported.
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			fw.watchDirectory(event.Name, true)
+			return
+		}
+
+		fw.addChange(event.Name, protocol.FileCreated)
+
+	case event.Op&fsnotify.Write == fsnotify.Write:
+		fw.addChange(event.Name, protocol.FileChanged)
+
+	case event.Op&fsnotify.Remove == fsnotify.Remove, event.Op&fsnotify.Rename == fsnotify.Rename:
+		fw.addChange(event.Name, protocol.FileDeleted)
+	}
+}
+
+// watchFolder starts watching the given workspace folder and its VCS package directory.
+func (fw *fileWatcher) watchFolder(folder workspaceFolder) {
+	roots := folderWatchRoots(folder)
+
+	fw.watchedLock.Lock()
+	fw.roots = append(fw.roots, roots...)
+	fw.watchedLock.Unlock()
+
+	for _, root := range roots {
+		fw.watchDirectory(root, false)
+	}
+}
+
+// unwatchFolder stops watching the given workspace folder and its VCS package directory, other than those
+// directories still watched for another folder.
+func (fw *fileWatcher) unwatchFolder(folder workspaceFolder) {
+	fw.watchedLock.Lock()
+	defer fw.watchedLock.Unlock()
+
+	for _, root := range folderWatchRoots(folder) {
+		for index, existing := range fw.roots {
+			if existing == root {
+				fw.roots = append(fw.roots[:index], fw.roots[index+1:]...)
+				break
+			}
+		}
+	}
+
+	for directory := range fw.directories {
+		if _, found := fw.rootFor(directory); found {
+			continue
+		}
+
+		delete(fw.directories, directory)
+		err := fw.watcher.Remove(directory)
+		if err != nil {
+			log.Printf("Could not stop watching directory %s: %v", directory, err)
+		}
+	}
+}
+
+// folderWatchRoots returns the root directories watched for the given workspace folder.
+func folderWatchRoots(folder workspaceFolder) []string {
+	packageDirectory := folder.vcsPackageDirectory()
+	if folder.contains(packageDirectory) {
+		return []string{folder.rootDirectory}
+	}
+
+	return []string{folder.rootDirectory, packageDirectory}
+}
+
+// rootFor returns the innermost root directory being watched which contains the given path, if any. Must be
+// called with watchedLock held.
+func (fw *fileWatcher) rootFor(filePath string) (string, bool) {
+	var found = ""
+	var hasFound = false
+
+	for _, root := range fw.roots {
+		if (filePath == root || strings.HasPrefix(filePath, root+"/")) && len(root) > len(found) {
+			found = root
+			hasFound = true
+		}
+	}
+
+	return found, hasFound
+}
+
+// watchDirectory starts watching the given directory and all directories found under it not matching the
+// ignore patterns. If reportFiles is true, the watched files found are reported as created.
+func (fw *fileWatcher) watchDirectory(directory string, reportFiles bool) {
+	filepath.Walk(directory, func(currentPath string, info os.FileInfo, err error) error {
+		if err != nil || fw.isIgnored(currentPath) {
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !info.IsDir() {
+			if reportFiles {
+				fw.addChange(currentPath, protocol.FileCreated)
+			}
+
+			return nil
+		}
+
+		err = fw.watcher.Add(currentPath)
+		if err != nil {
+			log.Printf("Could not watch directory %s: %v", currentPath, err)
+			return nil
+		}
+
+		fw.watchedLock.Lock()
+		fw.directories[currentPath] = true
+		fw.watchedLock.Unlock()
+		return nil
+	})
+}
+
+// isIgnored returns true if the given file or directory matches any of the ignore patterns. The root directories
+// being watched are never ignored.
+func (fw *fileWatcher) isIgnored(filePath string) bool {
+	fw.watchedLock.Lock()
+	root, found := fw.rootFor(filePath)
+	fw.watchedLock.Unlock()
+
+	relativePath := filepath.Base(filePath)
+	if found {
+		if filePath == root {
+			return false
+		}
+
+		relativePath = strings.TrimPrefix(filePath, root+"/")
+	}
+
+	for _, pattern := range fw.ignorePatterns {
+		if matchesIgnorePattern(pattern, relativePath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesIgnorePattern returns true if the given path, relative to the watched root containing it, matches the
+// given ignore pattern. Patterns without a separator are matched against the name of the file or directory and
+// of each directory containing it, such as `.git`. Patterns with a separator are matched against the relative
+// path of the file or directory and of each directory containing it, such as `out/*.seru`. A pattern ending
+// in `/**` matches the directory before it and everything found under it, such as `build/**`.
+func matchesIgnorePattern(pattern string, relativePath string) bool {
+	isPathPattern := strings.Contains(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/**")
+
+	for current := relativePath; current != "." && current != "/"; current = path.Dir(current) {
+		candidate := current
+		if !isPathPattern {
+			candidate = path.Base(current)
+		}
+
+		if matched, _ := path.Match(pattern, candidate); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// addChange records a change of the given type to the file at the given path, if it is a watched file.
+func (fw *fileWatcher) addChange(filePath string, changeType protocol.FileChangeType) {
+	if !isWatchedFile(filePath) {
+		return
+	}
+
+	fw.pendingLock.Lock()
+	if fw.closed {
+		fw.pendingLock.Unlock()
+		return
+	}
+
+	// A file created and then written is still reported as created.
+	if fw.pending[filePath] != protocol.FileCreated || changeType != protocol.FileChanged {
+		fw.pending[filePath] = changeType
+	}
+	fw.pendingLock.Unlock()
+
+	fw.notify(nil)
+}
+
+// flushPending hands all pending changes to the changes handler, unless the watcher was closed.
+func (fw *fileWatcher) flushPending(data interface{}) {
+	fw.pendingLock.Lock()
+	if fw.closed {
+		fw.pendingLock.Unlock()
+		return
+	}
+
+	changes := fw.pending
+	fw.pending = map[string]protocol.FileChangeType{}
+	fw.pendingLock.Unlock()
+
+	if len(changes) > 0 {
+		fw.handleChanges(changes)
+	}
+}
+
+// isWatchedFile returns true if the given path is that of a file whose changes are watched.
+func isWatchedFile(filePath string) bool {
+	return strings.HasSuffix(filePath, sourceshape.SerulianFileExtension) ||
+		strings.HasSuffix(filePath, webIDLFileExtension) ||
+		filepath.Base(filePath) == ProjectConfigFileName
+}
@@ -0,0 +1,101 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+type matchesIgnorePatternTest struct {
+	name         string
+	pattern      string
+	relativePath string
+	expected     bool
+}
+
+var matchesIgnorePatternTests = []matchesIgnorePatternTest{
+	{"name matches file", ".git", ".git", true},
+	{"name matches directory", ".git", ".git/HEAD", true},
+	{"name matches nested directory", "node_modules", "web/node_modules/lib/index.js", true},
+	{"name glob matches file", "*.gen.seru", "src/types.gen.seru", true},
+	{"name does not match", ".git", "src/main.seru", false},
+	{"name does not match partially", "node", "node_modules/lib.js", false},
+
+	{"path matches file", "out/*.seru", "out/main.seru", true},
+	{"path does not match nested file", "out/*.seru", "out/sub/main.seru", false},
+	{"path is relative to the root", "out/*.seru", "src/out/main.seru", false},
+
+	{"trailing globstar matches directory", "build/**", "build", true},
+	{"trailing globstar matches contents", "build/**", "build/lib/main.seru", true},
+	{"trailing globstar is relative to the root", "build/**", "src/build/main.seru", false},
+	{"trailing globstar does not match sibling", "build/**", "builder/main.seru", false},
+}
+
+func TestMatchesIgnorePattern(t *testing.T) {
+	for _, test := range matchesIgnorePatternTests {
+		matched := matchesIgnorePattern(test.pattern, test.relativePath)
+		if matched != test.expected {
+			t.Errorf("%s: expected %v, found %v", test.name, test.expected, matched)
+		}
+	}
+}
+
+// newTestFileWatcher creates a file watcher whose found changes are sent to the returned channel.
+func newTestFileWatcher(t *testing.T) (*fileWatcher, chan map[string]protocol.FileChangeType) {
+	handled := make(chan map[string]protocol.FileChangeType, 4)
+	watcher, err := newFileWatcher([]string{}, func(changes map[string]protocol.FileChangeType) {
+		handled <- changes
+	})
+	if err != nil {
+		t.Fatalf("Could not create file watcher: %v", err)
+	}
+	return watcher, handled
+}
+
+func TestFileWatcherHandlesPendingChanges(t *testing.T) {
+	watcher, handled := newTestFileWatcher(t)
+	defer watcher.close()
+
+	watcher.addChange("/workspace/created.seru", protocol.FileCreated)
+	watcher.addChange("/workspace/created.seru", protocol.FileChanged)
+	watcher.addChange("/workspace/changed.seru", protocol.FileChanged)
+	watcher.addChange("/workspace/"+ProjectConfigFileName, protocol.FileDeleted)
+	watcher.addChange("/workspace/notes.txt", protocol.FileChanged)
+
+	expected := map[string]protocol.FileChangeType{
+		"/workspace/created.seru":             protocol.FileCreated,
+		"/workspace/changed.seru":             protocol.FileChanged,
+		"/workspace/" + ProjectConfigFileName: protocol.FileDeleted,
+	}
+
+	select {
+	case changes := <-handled:
+		if !reflect.DeepEqual(changes, expected) {
+			t.Errorf("Expected changes %v, found %v", expected, changes)
+		}
+
+	case <-time.After(10 * FileWatchDelay):
+		t.Fatalf("Expected pending changes to be handled")
+	}
+}
+
+func TestFileWatcherDropsChangesOnClose(t *testing.T) {
+	watcher, handled := newTestFileWatcher(t)
+
+	watcher.addChange("/workspace/changed.seru", protocol.FileChanged)
+	watcher.close()
+	watcher.addChange("/workspace/other.seru", protocol.FileChanged)
+
+	select {
+	case changes := <-handled:
+		t.Errorf("Expected no changes to be handled after close, found %v", changes)
+
+	case <-time.After(4 * FileWatchDelay):
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"sort"
+
+	"github.com/serulian/compiler/compilercommon"
+	"github.com/serulian/compiler/sourceshape"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+// foldingRanges returns the folding ranges for the document with the given URI, as derived from its
+// parse tree.
+func (dt *documentTracker) foldingRanges(uri string) ([]protocol.FoldingRange, error) {
+	rootNode, _, contents, err := dt.parseDocument(uri)
+	if err != nil {
+		return []protocol.FoldingRange{}, err
+	}
+
+	return foldingRangesForTree(rootNode, contents), nil
+}
+
+// foldingRangesForTree returns the folding ranges for the given parse tree of the given contents: type
+// bodies, member and statement blocks, SML expressions, multiline comments and runs of imports. Ranges
+// are ordered by their start line, with at most one range starting on any line.
+func foldingRangesForTree(rootNode *parseNode, contents []byte) []protocol.FoldingRange {
+	mapper := compilercommon.CreateSourcePositionMapper(contents)
+	ranges := []protocol.FoldingRange{}
+
+	addRange := func(startLine int, endLine int, kind protocol.FoldingRangeKind) {
+		if endLine > startLine {
+			ranges = append(ranges, protocol.FoldingRange{
+				StartLine: startLine,
+				EndLine:   endLine,
+				Kind:      kind,
+			})
+		}
+	}
+
+	rootNode.walk(func(node *parseNode) bool {
+		switch node.nodeType {
+		case sourceshape.NodeTypeClass,
+			sourceshape.NodeTypeInterface,
+			sourceshape.NodeTypeNominal,
+			sourceshape.NodeTypeStruct,
+			sourceshape.NodeTypeAgent,
+			sourceshape.NodeTypeProperty,
+			sourceshape.NodeTypeStatementBlock,
+			sourceshape.NodeTypeSmlExpression:
+			// Fold up to, but not including, the line containing the closing brace or tag, so that it
+			// remains visible when folded.
+			if startLine, endLine, ok := nodeLineRange(node, mapper); ok {
+				addRange(startLine, endLine-1, "")
+			}
+
+		case sourceshape.NodeTypeComment:
+			if startLine, endLine, ok := nodeLineRange(node, mapper); ok {
+				addRange(startLine, endLine, protocol.FoldingRangeComment)
+			}
+		}
+
+		return true
+	})
+
+	// Fold each run of imports found at the top of the module.
+	var importsStart *parseNode
+	var importsEnd *parseNode
+	addImportsRange := func() {
+		if importsStart == nil {
+			return
+		}
+
+		startLine, _, startOk := nodeLineRange(importsStart, mapper)
+		_, endLine, endOk := nodeLineRange(importsEnd, mapper)
+		if startOk && endOk {
+			addRange(startLine, endLine, protocol.FoldingRangeImports)
+		}
+
+		importsStart = nil
+		importsEnd = nil
+	}
+
+	for _, child := range rootNode.allChildren() {
+		if child.nodeType == sourceshape.NodeTypeComment {
+			continue
+		}
+
+		if child.nodeType != sourceshape.NodeTypeImport {
+			addImportsRange()
+			continue
+		}
+
+		if importsStart == nil {
+			importsStart = child
+		}
+		importsEnd = child
+	}
+	addImportsRange()
+
+	// Clients only support a single range per start line, so keep the largest.
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].StartLine != ranges[j].StartLine {
+			return ranges[i].StartLine < ranges[j].StartLine
+		}
+		return ranges[i].EndLine > ranges[j].EndLine
+	})
+
+	filtered := make([]protocol.FoldingRange, 0, len(ranges))
+	for index, foldingRange := range ranges {
+		if index > 0 && ranges[index-1].StartLine == foldingRange.StartLine {
+			continue
+		}
+		filtered = append(filtered, foldingRange)
+	}
+
+	return filtered
+}
+
+// nodeLineRange returns the zero-based start and end lines of the given node in source, if any.
+func nodeLineRange(node *parseNode, mapper compilercommon.SourcePositionMapper) (int, int, bool) {
+	startRune, endRune, ok := node.runeRange()
+	if !ok {
+		return 0, 0, false
+	}
+
+	startLine, _, err := mapper.RunePositionToLineAndCol(startRune)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	endLine, _, err := mapper.RunePositionToLineAndCol(endRune)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return startLine, endLine, true
+}
@@ -0,0 +1,88 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/serulian/compiler/compilercommon"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+const foldingRangesSource = `import first
+import second
+
+/*
+ * Some comment.
+ */
+class SomeClass {
+	function DoSomething() {
+		var x = 1
+	}
+}
+
+function Render() {
+	return <div>
+		<span />
+	</div>
+}
+`
+
+// foldingRangesExpected are the folding ranges expected for foldingRangesSource, described as their start and end
+// lines followed by their kind.
+var foldingRangesExpected = []string{
+	"0-1 imports",
+	"3-5 comment",
+	"6-9 ",
+	"7-8 ",
+	"12-15 ",
+	"13-14 ",
+}
+
+// describeFoldingRanges describes the given folding ranges as their start and end lines followed by their kind.
+func describeFoldingRanges(ranges []protocol.FoldingRange) []string {
+	described := make([]string, 0, len(ranges))
+	for _, foldingRange := range ranges {
+		described = append(described, fmt.Sprintf("%v-%v %s", foldingRange.StartLine, foldingRange.EndLine, foldingRange.Kind))
+	}
+	return described
+}
+
+func TestFoldingRangesForTree(t *testing.T) {
+	source := compilercommon.InputSource("/main.seru")
+	ranges := foldingRangesForTree(parseSource(source, foldingRangesSource), []byte(foldingRangesSource))
+	if found := describeFoldingRanges(ranges); !reflect.DeepEqual(found, foldingRangesExpected) {
+		t.Errorf("Expected folding ranges %v, found %v", foldingRangesExpected, found)
+	}
+}
+
+func TestFoldingRangesSingleRangePerLine(t *testing.T) {
+	// The body of the function and the SML expression returned start on the same line, so only the largest
+	// range is kept.
+	contents := "function Render() { return <div>\n\t<span />\n</div>\n}\n"
+	source := compilercommon.InputSource("/main.seru")
+	ranges := foldingRangesForTree(parseSource(source, contents), []byte(contents))
+	if found, expected := describeFoldingRanges(ranges), []string{"0-2 "}; !reflect.DeepEqual(found, expected) {
+		t.Errorf("Expected folding ranges %v, found %v", expected, found)
+	}
+}
+
+func TestFoldingRangesRequest(t *testing.T) {
+	ts := newTestServer(t, map[string]string{"main.seru": foldingRangesSource})
+	defer ts.close()
+	ts.open("main.seru")
+
+	ranges := []protocol.FoldingRange{}
+	ts.call(protocol.FoldingRangeRequest, protocol.FoldingRangeParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(ts.uri("main.seru"))},
+	}, &ranges)
+
+	if found := describeFoldingRanges(ranges); !reflect.DeepEqual(found, foldingRangesExpected) {
+		t.Errorf("Expected folding ranges %v, found %v", foldingRangesExpected, found)
+	}
+}
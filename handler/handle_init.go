@@ -39,50 +39,121 @@ func (h *SerulianLangServerHandler) handlePreInit(ctx context.Context, conn *jso
 				}
 			}
 
-			// Initialize the document tracker.
-			workspaceRoot := h.entrypointSourceFile
-			if workspaceRoot == "" {
-				if initializeParams.RootURI.String() != "" {
-					workspaceRoot, err = h.documentTracker.uriToPath(initializeParams.RootURI.String())
+			// Initialize the document tracker. If an entrypoint source file was specified, it defines the
+			// workspace. Otherwise, each workspace folder (or the workspace root) is used.
+			workspaceRoots := []string{}
+			if h.entrypointSourceFile != "" {
+				workspaceRoots = append(workspaceRoots, h.entrypointSourceFile)
+			} else if len(initializeParams.WorkspaceFolders) > 0 {
+				for _, folder := range initializeParams.WorkspaceFolders {
+					workspaceRoot, err := h.documentTracker.uriToPath(folder.URI.String())
 					if err != nil {
-						log.Printf("Error when trying to convert workspace root URI to a path: %v\n", err)
-						return nil, err
+						log.Printf("Error when trying to convert workspace folder URI to a path: %v\n", err)
+						continue
 					}
+
+					workspaceRoots = append(workspaceRoots, workspaceRoot)
+				}
+			} else if initializeParams.RootURI.String() != "" {
+				workspaceRoot, err := h.documentTracker.uriToPath(initializeParams.RootURI.String())
+				if err != nil {
+					log.Printf("Error when trying to convert workspace root URI to a path: %v\n", err)
+					return nil, err
 				}
+
+				workspaceRoots = append(workspaceRoots, workspaceRoot)
 			}
 
-			h.documentTracker.initializeWorkspace(ctx, conn, workspaceRoot)
+			// Apply the initial settings, if any, before any Grokers are created.
+			if initializeParams.InitializationOptions != nil {
+				h.documentTracker.applySettings(ctx, conn, initializeParams.InitializationOptions.Settings)
+			}
+
+			// Save the capabilities of the client and pick the position encoding to use, before the workspace
+			// is initialized, as the positions of any issues found in it depend on the encoding.
+			h.clientCapabilities = initializeParams.Capabilities
+			h.documentTracker.positionEncoding = negotiatePositionEncoding(initializeParams.Capabilities)
+
+			h.documentTracker.initializeWorkspace(ctx, conn, workspaceRoots)
 
 			// Set the state as initializing.
-			h.currentState = stateInitializing
+			h.setState(stateInitializing)
 
 			// Respond back with our capabilities.
 			trueValue := true
-			fullDoc := protocol.FullDocument
+			incrementalDoc := protocol.IncrementalDocument
+			moreOnTypeFormattingTriggerCharacters := onTypeFormattingTriggerCharacters[1:]
+
+			// Only report the prepare rename support if the client supports it, per the spec.
+			var renameProvider interface{} = &trueValue
+			if h.supportsPrepareRename() {
+				renameProvider = &protocol.RenameOptions{
+					PrepareProvider: &trueValue,
+				}
+			}
+
 			return protocol.InitializeResult{
 				Capabilities: protocol.ServerCapabilities{
+					PositionEncoding: h.documentTracker.positionEncoding,
 					TextDocumentSync: &protocol.TextDocumentSyncOptions{
 						OpenClose:         &trueValue,
-						Change:            &fullDoc,
+						Change:            &incrementalDoc,
 						WillSaveWaitUntil: &trueValue,
 					},
-					HoverProvider:              &trueValue,
-					DefinitionProvider:         &trueValue,
-					WorkspaceSymbolProvider:    &trueValue,
-					DocumentFormattingProvider: &trueValue,
+					HoverProvider:                   &trueValue,
+					DefinitionProvider:              &trueValue,
+					TypeDefinitionProvider:          &trueValue,
+					ImplementationProvider:          &trueValue,
+					ReferencesProvider:              &trueValue,
+					DocumentHighlightProvider:       &trueValue,
+					WorkspaceSymbolProvider:         &trueValue,
+					DocumentSymbolProvider:          &trueValue,
+					DocumentFormattingProvider:      &trueValue,
+					DocumentRangeFormattingProvider: &trueValue,
+					FoldingRangeProvider:            &trueValue,
+					SelectionRangeProvider:          &trueValue,
+					CallHierarchyProvider:           &trueValue,
+					TypeHierarchyProvider:           &trueValue,
+					DocumentOnTypeFormattingProvider: &protocol.DocumentOnTypeFormattingOptions{
+						FirstTriggerCharacter: onTypeFormattingTriggerCharacters[0],
+						MoreTriggerCharacter:  &moreOnTypeFormattingTriggerCharacters,
+					},
 					CompletionProvider: &protocol.CompletionOptions{
 						TriggerCharacters: []string{" ", ".", "<"},
 					},
 					SignatureHelpProvider: &protocol.SignatureHelpOptions{
 						TriggerCharacters: []string{"(", "[", ","},
 					},
+					InlayHintProvider: &protocol.InlayHintOptions{
+						ResolveProvider: &trueValue,
+					},
+					SemanticTokensProvider: &protocol.SemanticTokensOptions{
+						Legend: protocol.SemanticTokensLegend{
+							TokenTypes:     semanticTokenTypes,
+							TokenModifiers: semanticTokenModifiers,
+						},
+						Range: &trueValue,
+						Full: &protocol.SemanticTokensFullOptions{
+							Delta: &trueValue,
+						},
+					},
 					CodeLensProvider: &protocol.CodeLensOptions{
 						ResolveProvider: &trueValue,
 					},
+					DocumentLinkProvider: &protocol.DocumentLinkOptions{
+						ResolveProvider: &trueValue,
+					},
 					ExecuteCommandProvider: &protocol.ExecuteCommandOptions{
 						Commands: grok.AllActions,
 					},
 					CodeActionProvider: &trueValue,
+					RenameProvider:     renameProvider,
+					Workspace: &protocol.WorkspaceServerCapabilities{
+						WorkspaceFolders: &protocol.WorkspaceFoldersServerCapabilities{
+							Supported:           &trueValue,
+							ChangeNotifications: &trueValue,
+						},
+					},
 				},
 			}, nil
 		}
@@ -112,7 +183,36 @@ func (h *SerulianLangServerHandler) handleInitializing(ctx context.Context, conn
 		os.Exit(-1) // -1 since we haven't received the shutdown notification.
 
 	case protocol.InitializedNotification:
-		h.currentState = stateRunning
+		h.setState(stateRunning)
+
+		// Report any issues found in the project configuration files when the workspace was initialized,
+		// as diagnostics cannot be published before the response to `initialize`.
+		for _, folder := range h.documentTracker.getWorkspaceFolders() {
+			h.documentTracker.reportProjectConfigIssues(ctx, conn, folder)
+		}
+
+		// Register to be notified of changes to files on disk, as those not open are read from there. If
+		// the client cannot watch files, watch them ourselves if requested.
+		registrations := []protocol.Registration{}
+		if h.supportsWatchedFilesRegistration() {
+			registrations = append(registrations, watchedFilesRegistration())
+		} else if h.watchFiles {
+			h.startFileWatcher(ctx, conn)
+		}
+
+		// Register to be notified of changes to the settings.
+		if h.supportsConfigurationChangeRegistration() {
+			registrations = append(registrations, settingsRegistration())
+		}
+
+		if len(registrations) > 0 {
+			go h.registerCapabilities(ctx, conn, registrations)
+		}
+
+		// Retrieve the current settings.
+		if h.supportsConfiguration() {
+			go h.pullSettings(ctx, conn)
+		}
 		return nil, nil
 	}
 
@@ -120,12 +220,27 @@ func (h *SerulianLangServerHandler) handleInitializing(ctx context.Context, conn
 	return nil, nil
 }
 
+// registerCapabilities registers the given capabilities with the client.
+func (h *SerulianLangServerHandler) registerCapabilities(ctx context.Context, conn *jsonrpc2.Conn, registrations []protocol.Registration) {
+	err := conn.Call(ctx, protocol.RegisterCapabilityRequest, protocol.RegistrationParams{
+		Registrations: registrations,
+	}, nil)
+	if err != nil {
+		log.Printf("Could not register capabilities: %v", err)
+	}
+}
+
 func (h *SerulianLangServerHandler) handleShuttingDown(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (result interface{}, err error) {
 	// Only supported notification in this state is the `exit` notification.
 	if req.Method == protocol.ExitNotification {
 		os.Exit(0) // 0 since we've received the exit notification.
 	}
 
-	// Ignore all other commands and notifications.
+	// All other requests are invalid, per the spec.
+	if !req.Notif {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidRequest, Message: "Server is shutting down"}
+	}
+
+	// Ignore all other notifications.
 	return nil, nil
 }
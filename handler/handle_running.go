@@ -6,6 +6,7 @@ package handler
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
 
@@ -60,9 +61,16 @@ func (h *SerulianLangServerHandler) handleRunning(ctx context.Context, conn *jso
 			return protocol.CodeActionResult([]protocol.Command{}), nil
 		}
 
-		// Retrieve the actions.
+		// Convert the position's column into the compiler's column.
 		source := compilercommon.InputSource(path)
-		actions, err := handle.GetActionsForPosition(source, params.Range.Start.Line, params.Range.Start.Column)
+		column, err := h.documentTracker.compilerColumn(source, params.Range.Start)
+		if err != nil {
+			log.Printf("Got error when trying to convert position for %s: %v", uri, err)
+			return protocol.CodeActionResult([]protocol.Command{}), nil
+		}
+
+		// Retrieve the actions.
+		actions, err := handle.GetActionsForPosition(source, params.Range.Start.Line, column)
 		if err != nil {
 			log.Printf("Got error when trying to retrieve actions for path %s: %v", uri, err)
 			return protocol.CodeActionResult([]protocol.Command{}), nil
@@ -147,6 +155,11 @@ func (h *SerulianLangServerHandler) handleRunning(ctx context.Context, conn *jso
 			return protocol.CodeLensResult([]protocol.CodeLens{}), nil
 		}
 
+		if !h.documentTracker.documentSettings(params.TextDocument.URI.String()).codeLens {
+			log.Printf("Code lenses are disabled\n")
+			return protocol.CodeLensResult([]protocol.CodeLens{}), nil
+		}
+
 		if cancelationHandle.WasCanceled() {
 			return nil, cancelationHandle.Error()
 		}
@@ -287,8 +300,16 @@ func (h *SerulianLangServerHandler) handleRunning(ctx context.Context, conn *jso
 			return protocol.SignatureHelpResult{[]protocol.SignatureInformation{}, 0, 0}, nil
 		}
 
+		// Convert the position's column into the compiler's column.
+		source := compilercommon.InputSource(path)
+		column, err := h.documentTracker.compilerColumn(source, params.Position)
+		if err != nil {
+			log.Printf("Got error when trying to convert position for %s: %v", uri, err)
+			return protocol.SignatureHelpResult{[]protocol.SignatureInformation{}, 0, 0}, nil
+		}
+
 		// Find the position's line text in the document.
-		lineText, err := h.documentTracker.getLineText(uri.String(), params.Position.Line, params.Position.Column)
+		lineText, err := h.documentTracker.getLineText(uri.String(), params.Position.Line, column)
 		if err != nil {
 			log.Printf("Got error when trying to retrieve line text for %s: %v", uri, err)
 			return protocol.SignatureHelpResult{[]protocol.SignatureInformation{}, 0, 0}, nil
@@ -301,8 +322,7 @@ func (h *SerulianLangServerHandler) handleRunning(ctx context.Context, conn *jso
 		log.Printf("Retrieving signature help for file %s and text: %s", uri, lineText)
 
 		// Lookup the signature help via Grok.
-		source := compilercommon.InputSource(path)
-		signatureInformation, err := handle.GetSignatureForPosition(strings.TrimSpace(lineText), source, params.Position.Line, params.Position.Column)
+		signatureInformation, err := handle.GetSignatureForPosition(strings.TrimSpace(lineText), source, params.Position.Line, column)
 		if err != nil {
 			log.Printf("Got error when retrieving signature for %s: %v", uri, err)
 			return protocol.SignatureHelpResult{[]protocol.SignatureInformation{}, 0, 0}, nil
@@ -325,7 +345,7 @@ func (h *SerulianLangServerHandler) handleRunning(ctx context.Context, conn *jso
 				return nil, cancelationHandle.Error()
 			}
 
-			signatureInformation, err = handle.GetSignatureForPosition(strings.TrimSpace(lineText), source, params.Position.Line, params.Position.Column)
+			signatureInformation, err = handle.GetSignatureForPosition(strings.TrimSpace(lineText), source, params.Position.Line, column)
 			if err != nil {
 				log.Printf("Got error when signature completions for %s: %v", uri, err)
 				return protocol.SignatureHelpResult{[]protocol.SignatureInformation{}, 0, 0}, nil
@@ -429,8 +449,16 @@ func (h *SerulianLangServerHandler) handleRunning(ctx context.Context, conn *jso
 			return protocol.CompletionResult([]protocol.CompletionItem{}), nil
 		}
 
+		// Convert the position's column into the compiler's column.
+		source := compilercommon.InputSource(path)
+		column, err := h.documentTracker.compilerColumn(source, params.Position)
+		if err != nil {
+			log.Printf("Got error when trying to convert position for %s: %v", uri, err)
+			return protocol.CompletionResult([]protocol.CompletionItem{}), nil
+		}
+
 		// Find the position's line text in the document.
-		lineText, err := h.documentTracker.getLineText(uri.String(), params.Position.Line, params.Position.Column)
+		lineText, err := h.documentTracker.getLineText(uri.String(), params.Position.Line, column)
 		if err != nil {
 			log.Printf("Got error when trying to retrieve line text for %s: %v", uri, err)
 			return protocol.CompletionResult([]protocol.CompletionItem{}), nil
@@ -439,8 +467,7 @@ func (h *SerulianLangServerHandler) handleRunning(ctx context.Context, conn *jso
 		log.Printf("Retrieving completions for file %s and text: `%s`", uri, lineText)
 
 		// Lookup the completion via Grok.
-		source := compilercommon.InputSource(path)
-		completionInfo, err := handle.GetCompletionsForPosition(strings.TrimSpace(lineText), source, params.Position.Line, params.Position.Column)
+		completionInfo, err := handle.GetCompletionsForPosition(strings.TrimSpace(lineText), source, params.Position.Line, column)
 		if err != nil {
 			log.Printf("Got error when retrieving completions for %s: %v", uri, err)
 			return protocol.CompletionResult([]protocol.CompletionItem{}), nil
@@ -463,7 +490,7 @@ func (h *SerulianLangServerHandler) handleRunning(ctx context.Context, conn *jso
 				return nil, cancelationHandle.Error()
 			}
 
-			completionInfo, err = handle.GetCompletionsForPosition(strings.TrimSpace(lineText), source, params.Position.Line, params.Position.Column)
+			completionInfo, err = handle.GetCompletionsForPosition(strings.TrimSpace(lineText), source, params.Position.Line, column)
 			if err != nil {
 				log.Printf("Got error when retrieving completions for %s: %v", uri, err)
 				return protocol.CompletionResult([]protocol.CompletionItem{}), nil
@@ -564,9 +591,16 @@ func (h *SerulianLangServerHandler) handleRunning(ctx context.Context, conn *jso
 			return nil, err
 		}
 
-		if params.Reason != protocol.WillSaveManual {
-			log.Printf("Save is not a manual action; skipping formatting\n")
+		switch h.documentTracker.documentSettings(params.TextDocument.URI.String()).formatOnSave {
+		case protocol.FormatOnSaveOff:
+			log.Printf("Format on save is disabled; skipping formatting\n")
 			return protocol.WillSaveWaitUntilTextDocumentResult([]protocol.TextEdit{}), nil
+
+		case protocol.FormatOnSaveManual:
+			if params.Reason != protocol.WillSaveManual {
+				log.Printf("Save is not a manual action; skipping formatting\n")
+				return protocol.WillSaveWaitUntilTextDocumentResult([]protocol.TextEdit{}), nil
+			}
 		}
 
 		log.Printf("Document %s is about to be saved", params.TextDocument.URI)
@@ -593,275 +627,962 @@ func (h *SerulianLangServerHandler) handleRunning(ctx context.Context, conn *jso
 		edits := h.documentTracker.formatDocument(string(params.TextDocument.URI))
 		return protocol.DocumentFormattingResult(edits), nil
 
-	// Workspace symbol lookup.
-	case protocol.WorkspaceSymbolRequest:
-		params := protocol.WorkspaceSymbolParams{}
+	// Document range formatting request.
+	case protocol.DocumentRangeFormattingRequest:
+		params := protocol.DocumentRangeFormattingParams{}
 		err := h.decodeParameters(req, &params)
 		if err != nil {
 			return nil, err
 		}
 
-		log.Printf("Got workspace symbol request with query: %s", params.Query)
-		groker := h.documentTracker.workspaceGrok
-		if groker == nil {
-			log.Printf("No workspace Grok available\n")
-			return protocol.WorkspaceSymbolResponse([]protocol.SymbolInformation{}), nil
-		}
+		log.Printf("Got range formatting request for document: %s", params.TextDocument.URI)
 
-		if cancelationHandle.WasCanceled() {
-			return nil, cancelationHandle.Error()
-		}
+		// Format the document via the tracker.
+		edits := h.documentTracker.formatDocumentRange(string(params.TextDocument.URI), params.Range)
+		return protocol.DocumentRangeFormattingResult(edits), nil
 
-		// Grab a Grok handle.
-		handle, err := groker.GetHandleWithOption(grok.HandleAllowStale)
+	// Document on-type formatting request.
+	case protocol.DocumentOnTypeFormattingRequest:
+		params := protocol.DocumentOnTypeFormattingParams{}
+		err := h.decodeParameters(req, &params)
 		if err != nil {
-			log.Printf("Got error when trying to get grok handle for global workspace: %v", err)
-			return protocol.WorkspaceSymbolResponse([]protocol.SymbolInformation{}), nil
+			return nil, err
 		}
 
-		if cancelationHandle.WasCanceled() {
-			return nil, cancelationHandle.Error()
+		log.Printf("Got on-type formatting request for document %s at position %v:%v\n", params.TextDocument.URI, params.Position.Line, params.Position.Column)
+		if !h.documentTracker.isTracking(params.TextDocument.URI.String()) {
+			log.Printf("Not tracking document %s\n", params.TextDocument.URI)
+			return protocol.DocumentOnTypeFormattingResult([]protocol.TextEdit{}), nil
+		}
+
+		if !h.documentTracker.documentSettings(params.TextDocument.URI.String()).onTypeFormatting {
+			log.Printf("On-type formatting is disabled\n")
+			return protocol.DocumentOnTypeFormattingResult([]protocol.TextEdit{}), nil
 		}
 
-		// Perform symbol lookup.
-		symbols, err := handle.FindSymbols(params.Query)
+		// Format the completed statement or block via the tracker.
+		edits := h.documentTracker.formatDocumentOnType(string(params.TextDocument.URI), params.Position, params.Ch)
+		return protocol.DocumentOnTypeFormattingResult(edits), nil
+
+	// Folding ranges.
+	case protocol.FoldingRangeRequest:
+		params := protocol.FoldingRangeParams{}
+		err := h.decodeParameters(req, &params)
 		if err != nil {
-			log.Printf("Got error when trying to find symbol %s in global workspace: %v", params.Query, err)
-			return protocol.WorkspaceSymbolResponse([]protocol.SymbolInformation{}), nil
+			return nil, err
 		}
 
-		if cancelationHandle.WasCanceled() {
-			return nil, cancelationHandle.Error()
+		log.Printf("Got folding range request for document %s", params.TextDocument.URI)
+		if !h.documentTracker.isTracking(params.TextDocument.URI.String()) {
+			log.Printf("Not tracking document %s\n", params.TextDocument.URI)
+			return protocol.FoldingRangeResult([]protocol.FoldingRange{}), nil
 		}
 
-		// Convert the symbols and return.
-		symbolInfo := make([]protocol.SymbolInformation, 0, len(symbols))
-		for _, symbol := range symbols {
-			if converted, success := h.symbolInfoFromSymbol(symbol); success {
-				symbolInfo = append(symbolInfo, converted)
-			}
+		// Compute the folding ranges from the document's parse tree.
+		ranges, err := h.documentTracker.foldingRanges(params.TextDocument.URI.String())
+		if err != nil {
+			log.Printf("Got error when trying to compute folding ranges for %s: %v", params.TextDocument.URI, err)
+			return protocol.FoldingRangeResult([]protocol.FoldingRange{}), nil
 		}
-		return protocol.WorkspaceSymbolResponse(symbolInfo), nil
 
-	// Definition.
-	case protocol.DefinitionRequest:
-		params := protocol.DefinitionParams{}
+		return protocol.FoldingRangeResult(ranges), nil
+
+	// Selection ranges.
+	case protocol.SelectionRangeRequest:
+		params := protocol.SelectionRangeParams{}
 		err := h.decodeParameters(req, &params)
 		if err != nil {
 			return nil, err
 		}
 
-		log.Printf("Got definition request for document %s at position %v:%v\n", params.TextDocument.URI, params.Position.Line, params.Position.Column)
-		rangeInfo, _, status := h.lookupRange(params.TextDocument.URI, params.Position, cancelationHandle)
-		if !status {
-			log.Printf("No valid range found for document %s at position %v:%v\n", params.TextDocument.URI, params.Position.Line, params.Position.Column)
-			return protocol.DefinitionResult([]protocol.Location{}), nil
+		log.Printf("Got selection range request for document %s at %v position(s)", params.TextDocument.URI, len(params.Positions))
+		if !h.documentTracker.isTracking(params.TextDocument.URI.String()) {
+			log.Printf("Not tracking document %s\n", params.TextDocument.URI)
+			return protocol.SelectionRangeResult([]protocol.SelectionRange{}), nil
 		}
 
-		if cancelationHandle.WasCanceled() {
-			return nil, cancelationHandle.Error()
+		// Compute the selection ranges from the document's parse tree.
+		ranges, err := h.documentTracker.selectionRanges(params.TextDocument.URI.String(), params.Positions)
+		if err != nil {
+			log.Printf("Got error when trying to compute selection ranges for %s: %v", params.TextDocument.URI, err)
+			return protocol.SelectionRangeResult([]protocol.SelectionRange{}), nil
 		}
 
-		locations := h.documentTracker.convertRanges(rangeInfo.SourceRanges)
-		return protocol.DefinitionResult(locations), nil
+		return protocol.SelectionRangeResult(ranges), nil
 
-	// Hover.
-	case protocol.HoverRequest:
-		params := protocol.HoverParams{}
+	// Semantic tokens for a full document.
+	case protocol.SemanticTokensFullRequest:
+		params := protocol.SemanticTokensParams{}
 		err := h.decodeParameters(req, &params)
 		if err != nil {
 			return nil, err
 		}
 
-		log.Printf("Got hover request for document %s at position %v:%v\n", params.TextDocument.URI, params.Position.Line, params.Position.Column)
-		rangeInfo, _, status := h.lookupRange(params.TextDocument.URI, params.Position, cancelationHandle)
-		if !status {
-			return protocol.HoverResult{
-				Contents: []interface{}{},
-			}, nil
+		log.Printf("Got semantic tokens request for document %s", params.TextDocument.URI)
+		if !h.documentTracker.isTracking(params.TextDocument.URI.String()) {
+			log.Printf("Not tracking document %s\n", params.TextDocument.URI)
+			return protocol.SemanticTokensResult{Data: []int{}}, nil
 		}
 
-		markedTexts := rangeInfo.HumanReadable()
-		markedStrings := make([]interface{}, len(markedTexts))
-		for index, hr := range markedTexts {
-			if hr.Kind == grok.SerulianCodeText {
-				markedStrings[index] = protocol.MarkedString{"serulian", hr.Value}
-			} else {
-				markedStrings[index] = hr.Value
+		if !h.documentTracker.documentSettings(params.TextDocument.URI.String()).semanticTokens {
+			log.Printf("Semantic tokens are disabled\n")
+			return protocol.SemanticTokensResult{Data: []int{}}, nil
+		}
+
+		tokens, err := h.documentTracker.semanticTokens(params.TextDocument.URI.String(), cancelationHandle)
+		if err != nil {
+			if cancelationHandle.WasCanceled() {
+				return nil, cancelationHandle.Error()
 			}
+
+			log.Printf("Got error when trying to compute semantic tokens for %s: %v", params.TextDocument.URI, err)
+			return protocol.SemanticTokensResult{Data: []int{}}, nil
 		}
 
-		return protocol.HoverResult{
-			Contents: markedStrings,
-		}, nil
+		return protocol.SemanticTokensResult(tokens), nil
 
-	// Document opened.
-	case protocol.DidOpenTextDocumentNotification:
-		params := protocol.DidOpenTextDocumentParams{}
+	// Semantic tokens for a full document, as a delta.
+	case protocol.SemanticTokensFullDeltaRequest:
+		params := protocol.SemanticTokensDeltaParams{}
 		err := h.decodeParameters(req, &params)
 		if err != nil {
 			return nil, err
 		}
 
-		if h.documentTracker.tracksLanguage(params.TextDocument.LanguageID) {
-			log.Printf("Document opened: %s\n", params.TextDocument.URI)
-			h.documentTracker.openDocument(ctx, conn, params.TextDocument.URI.String(), params.TextDocument.Text, params.TextDocument.Version)
+		log.Printf("Got semantic tokens delta request for document %s from result %s", params.TextDocument.URI, params.PreviousResultID)
+		if !h.documentTracker.isTracking(params.TextDocument.URI.String()) {
+			log.Printf("Not tracking document %s\n", params.TextDocument.URI)
+			return protocol.SemanticTokensResult{Data: []int{}}, nil
 		}
-		return nil, nil
 
-	// Document updated.
-	case protocol.DidChangeTextDocumentNotification:
-		params := protocol.DidChangeTextDocumentParams{}
-		err := h.decodeParameters(req, &params)
+		if !h.documentTracker.documentSettings(params.TextDocument.URI.String()).semanticTokens {
+			log.Printf("Semantic tokens are disabled\n")
+			return protocol.SemanticTokensResult{Data: []int{}}, nil
+		}
+
+		delta, err := h.documentTracker.semanticTokensDelta(params.TextDocument.URI.String(), params.PreviousResultID, cancelationHandle)
 		if err != nil {
-			return nil, err
+			if cancelationHandle.WasCanceled() {
+				return nil, cancelationHandle.Error()
+			}
+
+			log.Printf("Got error when trying to compute semantic tokens for %s: %v", params.TextDocument.URI, err)
+			return protocol.SemanticTokensResult{Data: []int{}}, nil
 		}
 
-		log.Printf("Document updated: %s\n", params.TextDocument.URI)
-		h.documentTracker.updateDocument(ctx, conn, params.TextDocument.URI.String(), params.ContentChanges[0].Text, params.TextDocument.Version)
-		return nil, nil
+		return delta, nil
 
-	// Document closed.
-	case protocol.DidCloseTextDocumentNotification:
-		params := protocol.DidCloseTextDocumentParams{}
+	// Semantic tokens for a range of a document.
+	case protocol.SemanticTokensRangeRequest:
+		params := protocol.SemanticTokensRangeParams{}
 		err := h.decodeParameters(req, &params)
 		if err != nil {
 			return nil, err
 		}
 
-		if h.documentTracker.tracksLanguage(params.TextDocument.LanguageID) {
-			log.Printf("Document closed: %s\n", params.TextDocument.URI)
-			h.documentTracker.closeDocument(params.TextDocument.URI.String())
+		log.Printf("Got semantic tokens request for document %s in range %v:%v-%v:%v", params.TextDocument.URI,
+			params.Range.Start.Line, params.Range.Start.Column, params.Range.End.Line, params.Range.End.Column)
+		if !h.documentTracker.isTracking(params.TextDocument.URI.String()) {
+			log.Printf("Not tracking document %s\n", params.TextDocument.URI)
+			return protocol.SemanticTokensResult{Data: []int{}}, nil
 		}
-		return nil, nil
 
-	// Exit notification.
-	case protocol.ExitNotification:
-		os.Exit(-1) // -1 since we haven't received the shutdown notification.
-	}
+		if !h.documentTracker.documentSettings(params.TextDocument.URI.String()).semanticTokens {
+			log.Printf("Semantic tokens are disabled\n")
+			return protocol.SemanticTokensResult{Data: []int{}}, nil
+		}
 
-	return nil, nil
-}
+		tokens, err := h.documentTracker.semanticTokensRange(params.TextDocument.URI.String(), params.Range, cancelationHandle)
+		if err != nil {
+			if cancelationHandle.WasCanceled() {
+				return nil, cancelationHandle.Error()
+			}
 
-// lookupRange performs lookup of the range matching the given position in the given document.
-func (h *SerulianLangServerHandler) lookupRange(uri protocol.DocumentURI, position protocol.Position, cancelationHandle *CancelationHandle) (grok.RangeInformation, error, bool) {
-	// Make sure we are tracking this document.
-	if !h.documentTracker.isTracking(uri.String()) {
-		log.Printf("Not tracking document %s\n", uri)
-		return grok.RangeInformation{}, nil, false
-	}
+			log.Printf("Got error when trying to compute semantic tokens for %s: %v", params.TextDocument.URI, err)
+			return protocol.SemanticTokensResult{Data: []int{}}, nil
+		}
 
-	if cancelationHandle.WasCanceled() {
-		return grok.RangeInformation{}, cancelationHandle.Error(), false
-	}
+		return protocol.SemanticTokensResult(tokens), nil
 
-	// Grab a Grok handle for the document.
-	handle, err := h.documentTracker.getGrokHandle(uri.String(), grok.HandleMustBeFresh)
-	if err != nil {
-		log.Printf("Got error when trying to get grok handle for %s: %v", uri, err)
-		return grok.RangeInformation{}, err, false
-	}
+	// Inlay hints.
+	case protocol.InlayHintRequest:
+		params := protocol.InlayHintParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
 
-	if cancelationHandle.WasCanceled() {
-		return grok.RangeInformation{}, cancelationHandle.Error(), false
-	}
+		log.Printf("Got inlay hint request for document %s in range %v:%v-%v:%v", params.TextDocument.URI,
+			params.Range.Start.Line, params.Range.Start.Column, params.Range.End.Line, params.Range.End.Column)
+		if !h.documentTracker.isTracking(params.TextDocument.URI.String()) {
+			log.Printf("Not tracking document %s\n", params.TextDocument.URI)
+			return protocol.InlayHintResult([]protocol.InlayHint{}), nil
+		}
 
-	// Retrieve the path.
-	path, err := h.documentTracker.uriToPath(uri.String())
-	if err != nil {
-		log.Printf("Got error when trying to convert URI to path for %s: %v", uri, err)
-		return grok.RangeInformation{}, err, false
-	}
+		hints, err := h.documentTracker.inlayHints(params.TextDocument.URI.String(), params.Range, cancelationHandle)
+		if err != nil {
+			if cancelationHandle.WasCanceled() {
+				return nil, cancelationHandle.Error()
+			}
 
-	// Lookup the position via Grok.
-	source := compilercommon.InputSource(path)
-	rangeInfo, err := handle.LookupPosition(source, position.Line, position.Column)
-	if err != nil {
-		log.Printf("Got error when trying to lookup range for %s: %v", uri, err)
-		return grok.RangeInformation{}, err, false
-	}
+			log.Printf("Got error when trying to compute inlay hints for %s: %v", params.TextDocument.URI, err)
+			return protocol.InlayHintResult([]protocol.InlayHint{}), nil
+		}
 
-	// If we found a result, turn it into code and report it.
-	log.Printf("Got result: %v => %v", rangeInfo.Kind, rangeInfo.HumanReadable())
-	return rangeInfo, nil, rangeInfo.Kind != grok.NotFound
-}
+		return protocol.InlayHintResult(hints), nil
 
-// symbolInfoFromSymbol converts a Grok Symbol into a SymbolInformation struct.
-func (h *SerulianLangServerHandler) symbolInfoFromSymbol(symbol grok.Symbol) (protocol.SymbolInformation, bool) {
-	if len(symbol.SourceRanges) < 1 {
-		return protocol.SymbolInformation{}, false
-	}
+	// Resolve inlay hint.
+	case protocol.InlayHintResolveRequest:
+		params := protocol.InlayHint{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
 
-	var containerName *string
-	var symbolKind = protocol.SymbolFile
+		if cancelationHandle.WasCanceled() {
+			return nil, cancelationHandle.Error()
+		}
 
-	switch symbol.Kind {
-	case grok.TypeSymbol:
-		switch symbol.Type.TypeKind() {
-		case typegraph.StructType:
-			symbolKind = protocol.SymbolStruct
+		return protocol.InlayHintResolveResult(h.documentTracker.resolveInlayHint(params)), nil
 
-		case typegraph.AgentType:
-			fallthrough
+	// Document links.
+	case protocol.DocumentLinkRequest:
+		params := protocol.DocumentLinkParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
 
-		case typegraph.ClassType:
-			symbolKind = protocol.SymbolClass
+		log.Printf("Got document link request for document %s", params.TextDocument.URI)
+		if !h.documentTracker.isTracking(params.TextDocument.URI.String()) {
+			log.Printf("Not tracking document %s\n", params.TextDocument.URI)
+			return protocol.DocumentLinkResult([]protocol.DocumentLink{}), nil
+		}
 
-		case typegraph.NominalType:
-			symbolKind = protocol.SymbolObject
+		if !h.documentTracker.documentSettings(params.TextDocument.URI.String()).documentLinks {
+			log.Printf("Document links are disabled\n")
+			return protocol.DocumentLinkResult([]protocol.DocumentLink{}), nil
+		}
 
-		case typegraph.ImplicitInterfaceType:
-			fallthrough
+		if cancelationHandle.WasCanceled() {
+			return nil, cancelationHandle.Error()
+		}
 
-		case typegraph.ExternalInternalType:
-			symbolKind = protocol.SymbolInterface
+		links, err := h.documentTracker.documentLinks(params.TextDocument.URI.String())
+		if err != nil {
+			log.Printf("Got error when trying to compute document links for %s: %v", params.TextDocument.URI, err)
+			return protocol.DocumentLinkResult([]protocol.DocumentLink{}), nil
+		}
 
-		case typegraph.AliasType:
-			fallthrough
+		return protocol.DocumentLinkResult(links), nil
 
-		case typegraph.GenericType:
-			symbolKind = protocol.SymbolTypeParameter
+	// Resolve document link.
+	case protocol.DocumentLinkResolveRequest:
+		params := protocol.DocumentLink{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
 
-		default:
-			panic("Unknown kind of type")
+		if cancelationHandle.WasCanceled() {
+			return nil, cancelationHandle.Error()
 		}
 
-	case grok.MemberSymbol:
-		_, isConstructor := symbol.Member.ConstructorType()
-		_, hasReturnType := symbol.Member.ReturnType()
+		return protocol.DocumentLinkResolveResult(h.documentTracker.resolveDocumentLink(params)), nil
 
-		if containingType, hasContainingType := symbol.Member.ParentType(); hasContainingType {
-			typeName := containingType.Name()
-			containerName = &typeName
+	// Prepare call hierarchy.
+	case protocol.PrepareCallHierarchyRequest:
+		params := protocol.CallHierarchyPrepareParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
 		}
 
-		switch {
-		case symbol.Member.IsOperator():
-			symbolKind = protocol.SymbolOperator
-
-		case isConstructor:
-			symbolKind = protocol.SymbolConstructor
+		log.Printf("Got prepare call hierarchy request for document %s at position %v:%v\n", params.TextDocument.URI, params.Position.Line, params.Position.Column)
+		rangeInfo, _, status := h.lookupRange(params.TextDocument.URI, params.Position, cancelationHandle)
+		if !status {
+			log.Printf("No valid range found for document %s at position %v:%v\n", params.TextDocument.URI, params.Position.Line, params.Position.Column)
+			return nil, nil
+		}
 
-		case symbol.Member.IsField():
-			symbolKind = protocol.SymbolField
+		if cancelationHandle.WasCanceled() {
+			return nil, cancelationHandle.Error()
+		}
 
-		case hasReturnType:
-			symbolKind = protocol.SymbolFunction
+		item, found, err := h.documentTracker.prepareCallHierarchy(params.TextDocument.URI.String(), rangeInfo)
+		if err != nil {
+			log.Printf("Got error when trying to prepare call hierarchy for %s: %v", params.TextDocument.URI, err)
+			return nil, nil
+		}
 
-		default:
-			symbolKind = protocol.SymbolProperty
+		if !found {
+			return nil, nil
 		}
 
-	case grok.ModuleSymbol:
-		symbolKind = protocol.SymbolFile
+		return protocol.PrepareCallHierarchyResult([]protocol.CallHierarchyItem{item}), nil
 
-	default:
-		panic("Unknown kind of Grok symbol")
-	}
+	// Incoming calls.
+	case protocol.CallHierarchyIncomingCallsRequest:
+		params := protocol.CallHierarchyIncomingCallsParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
 
-	ranges := h.documentTracker.convertRanges(symbol.SourceRanges)
-	if len(ranges) == 0 {
-		return protocol.SymbolInformation{}, false
+		log.Printf("Got incoming calls request for %s in document %s", params.Item.Name, params.Item.URI)
+		calls, err := h.documentTracker.incomingCalls(params.Item, cancelationHandle)
+		if err != nil {
+			if cancelationHandle.WasCanceled() {
+				return nil, cancelationHandle.Error()
+			}
+
+			log.Printf("Got error when trying to find incoming calls for %s: %v", params.Item.Name, err)
+			return protocol.CallHierarchyIncomingCallsResult([]protocol.CallHierarchyIncomingCall{}), nil
+		}
+
+		return protocol.CallHierarchyIncomingCallsResult(calls), nil
+
+	// Outgoing calls.
+	case protocol.CallHierarchyOutgoingCallsRequest:
+		params := protocol.CallHierarchyOutgoingCallsParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Got outgoing calls request for %s in document %s", params.Item.Name, params.Item.URI)
+		calls, err := h.documentTracker.outgoingCalls(params.Item, cancelationHandle)
+		if err != nil {
+			if cancelationHandle.WasCanceled() {
+				return nil, cancelationHandle.Error()
+			}
+
+			log.Printf("Got error when trying to find outgoing calls for %s: %v", params.Item.Name, err)
+			return protocol.CallHierarchyOutgoingCallsResult([]protocol.CallHierarchyOutgoingCall{}), nil
+		}
+
+		return protocol.CallHierarchyOutgoingCallsResult(calls), nil
+
+	// Prepare type hierarchy.
+	case protocol.PrepareTypeHierarchyRequest:
+		params := protocol.TypeHierarchyPrepareParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Got prepare type hierarchy request for document %s at position %v:%v\n", params.TextDocument.URI, params.Position.Line, params.Position.Column)
+		rangeInfo, _, status := h.lookupRange(params.TextDocument.URI, params.Position, cancelationHandle)
+		if !status {
+			log.Printf("No valid range found for document %s at position %v:%v\n", params.TextDocument.URI, params.Position.Line, params.Position.Column)
+			return nil, nil
+		}
+
+		if cancelationHandle.WasCanceled() {
+			return nil, cancelationHandle.Error()
+		}
+
+		item, found, err := h.documentTracker.prepareTypeHierarchy(params.TextDocument.URI.String(), rangeInfo)
+		if err != nil {
+			log.Printf("Got error when trying to prepare type hierarchy for %s: %v", params.TextDocument.URI, err)
+			return nil, nil
+		}
+
+		if !found {
+			return nil, nil
+		}
+
+		return protocol.PrepareTypeHierarchyResult([]protocol.TypeHierarchyItem{item}), nil
+
+	// Supertypes.
+	case protocol.TypeHierarchySupertypesRequest:
+		params := protocol.TypeHierarchySupertypesParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Got supertypes request for %s in document %s", params.Item.Name, params.Item.URI)
+		items, err := h.documentTracker.supertypes(params.Item, cancelationHandle)
+		if err != nil {
+			if cancelationHandle.WasCanceled() {
+				return nil, cancelationHandle.Error()
+			}
+
+			log.Printf("Got error when trying to find supertypes for %s: %v", params.Item.Name, err)
+			return protocol.TypeHierarchyResult([]protocol.TypeHierarchyItem{}), nil
+		}
+
+		return protocol.TypeHierarchyResult(items), nil
+
+	// Subtypes.
+	case protocol.TypeHierarchySubtypesRequest:
+		params := protocol.TypeHierarchySubtypesParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Got subtypes request for %s in document %s", params.Item.Name, params.Item.URI)
+		items, err := h.documentTracker.subtypes(params.Item, cancelationHandle)
+		if err != nil {
+			if cancelationHandle.WasCanceled() {
+				return nil, cancelationHandle.Error()
+			}
+
+			log.Printf("Got error when trying to find subtypes for %s: %v", params.Item.Name, err)
+			return protocol.TypeHierarchyResult([]protocol.TypeHierarchyItem{}), nil
+		}
+
+		return protocol.TypeHierarchyResult(items), nil
+
+	// Document symbols.
+	case protocol.DocumentSymbolRequest:
+		params := protocol.DocumentSymbolParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Got document symbol request for document %s", params.TextDocument.URI)
+		emptyResult := h.documentSymbolResult(params.TextDocument.URI, []protocol.DocumentSymbol{})
+		if !h.documentTracker.isTracking(params.TextDocument.URI.String()) {
+			log.Printf("Not tracking document %s\n", params.TextDocument.URI)
+			return emptyResult, nil
+		}
+
+		if cancelationHandle.WasCanceled() {
+			return nil, cancelationHandle.Error()
+		}
+
+		// Grab a Grok handle for the document.
+		uri := params.TextDocument.URI
+		handle, err := h.documentTracker.getGrokHandle(uri.String(), grok.HandleAllowStale)
+		if err != nil {
+			log.Printf("Got error when trying to get grok handle for %s: %v", uri, err)
+			return emptyResult, nil
+		}
+
+		if cancelationHandle.WasCanceled() {
+			return nil, cancelationHandle.Error()
+		}
+
+		// Collect the symbols defined in the document.
+		symbols, err := h.documentTracker.documentSymbols(uri.String(), handle)
+		if err != nil {
+			log.Printf("Got error when trying to find symbols for %s: %v", uri, err)
+			return emptyResult, nil
+		}
+
+		return h.documentSymbolResult(uri, symbols), nil
+
+	// Workspace symbol lookup.
+	case protocol.WorkspaceSymbolRequest:
+		params := protocol.WorkspaceSymbolParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Got workspace symbol request with query: %s", params.Query)
+		folders := h.documentTracker.getWorkspaceFolders()
+		if len(folders) == 0 {
+			log.Printf("No workspace Grok available\n")
+			return protocol.WorkspaceSymbolResponse([]protocol.SymbolInformation{}), nil
+		}
+
+		// Perform symbol lookup in each entrypoint of each workspace folder, skipping symbols shared between
+		// them (such as those of the core library).
+		symbolInfo := []protocol.SymbolInformation{}
+		encountered := map[string]bool{}
+		for _, folder := range folders {
+			if cancelationHandle.WasCanceled() {
+				return nil, cancelationHandle.Error()
+			}
+
+			for _, groker := range folder.grokers {
+				// Grab a Grok handle.
+				handle, err := groker.GetHandleWithOption(grok.HandleAllowStale)
+				if err != nil {
+					log.Printf("Got error when trying to get grok handle for workspace folder %s: %v", folder.rootPath, err)
+					continue
+				}
+
+				if cancelationHandle.WasCanceled() {
+					return nil, cancelationHandle.Error()
+				}
+
+				symbols, err := handle.FindSymbols(params.Query)
+				if err != nil {
+					log.Printf("Got error when trying to find symbol %s in workspace folder %s: %v", params.Query, folder.rootPath, err)
+					continue
+				}
+
+				// Convert the symbols.
+				for _, symbol := range symbols {
+					if len(symbol.SourceRanges) > 0 {
+						if key, ok := sourceRangeKey(symbol.SourceRanges[0]); ok {
+							if encountered[key] {
+								continue
+							}
+
+							encountered[key] = true
+						}
+					}
+
+					if converted, success := h.symbolInfoFromSymbol(symbol); success {
+						symbolInfo = append(symbolInfo, converted)
+					}
+				}
+			}
+		}
+
+		return protocol.WorkspaceSymbolResponse(symbolInfo), nil
+
+	// Definition.
+	case protocol.DefinitionRequest:
+		params := protocol.DefinitionParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Got definition request for document %s at position %v:%v\n", params.TextDocument.URI, params.Position.Line, params.Position.Column)
+		rangeInfo, _, status := h.lookupRange(params.TextDocument.URI, params.Position, cancelationHandle)
+		if !status {
+			log.Printf("No valid range found for document %s at position %v:%v\n", params.TextDocument.URI, params.Position.Line, params.Position.Column)
+			return protocol.DefinitionResult([]protocol.Location{}), nil
+		}
+
+		if cancelationHandle.WasCanceled() {
+			return nil, cancelationHandle.Error()
+		}
+
+		locations := h.documentTracker.convertRanges(rangeInfo.SourceRanges)
+		return protocol.DefinitionResult(locations), nil
+
+	// Type definition.
+	case protocol.TypeDefinitionRequest:
+		params := protocol.TypeDefinitionParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Got type definition request for document %s at position %v:%v\n", params.TextDocument.URI, params.Position.Line, params.Position.Column)
+		rangeInfo, _, status := h.lookupRange(params.TextDocument.URI, params.Position, cancelationHandle)
+		if !status {
+			log.Printf("No valid range found for document %s at position %v:%v\n", params.TextDocument.URI, params.Position.Line, params.Position.Column)
+			return protocol.TypeDefinitionResult([]protocol.Location{}), nil
+		}
+
+		if cancelationHandle.WasCanceled() {
+			return nil, cancelationHandle.Error()
+		}
+
+		sourceRanges, err := h.documentTracker.typeDefinition(params.TextDocument.URI.String(), rangeInfo)
+		if err != nil {
+			log.Printf("Got error when trying to find type definition for %s: %v", params.TextDocument.URI, err)
+			return protocol.TypeDefinitionResult([]protocol.Location{}), nil
+		}
+
+		locations := h.documentTracker.convertRanges(sourceRanges)
+		return protocol.TypeDefinitionResult(locations), nil
+
+	// Implementation.
+	case protocol.ImplementationRequest:
+		params := protocol.ImplementationParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Got implementation request for document %s at position %v:%v\n", params.TextDocument.URI, params.Position.Line, params.Position.Column)
+		rangeInfo, _, status := h.lookupRange(params.TextDocument.URI, params.Position, cancelationHandle)
+		if !status {
+			log.Printf("No valid range found for document %s at position %v:%v\n", params.TextDocument.URI, params.Position.Line, params.Position.Column)
+			return protocol.ImplementationResult([]protocol.Location{}), nil
+		}
+
+		if cancelationHandle.WasCanceled() {
+			return nil, cancelationHandle.Error()
+		}
+
+		sourceRanges, err := h.documentTracker.findImplementations(params.TextDocument.URI.String(), rangeInfo, cancelationHandle)
+		if err != nil {
+			if cancelationHandle.WasCanceled() {
+				return nil, cancelationHandle.Error()
+			}
+
+			log.Printf("Got error when trying to find implementations for %s: %v", params.TextDocument.URI, err)
+			return protocol.ImplementationResult([]protocol.Location{}), nil
+		}
+
+		locations := h.documentTracker.convertRanges(sourceRanges)
+		return protocol.ImplementationResult(locations), nil
+
+	// References.
+	case protocol.ReferencesRequest:
+		params := protocol.ReferenceParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Got references request for document %s at position %v:%v\n", params.TextDocument.URI, params.Position.Line, params.Position.Column)
+		rangeInfo, _, status := h.lookupRange(params.TextDocument.URI, params.Position, cancelationHandle)
+		if !status {
+			log.Printf("No valid range found for document %s at position %v:%v\n", params.TextDocument.URI, params.Position.Line, params.Position.Column)
+			return protocol.ReferencesResult([]protocol.Location{}), nil
+		}
+
+		if cancelationHandle.WasCanceled() {
+			return nil, cancelationHandle.Error()
+		}
+
+		references, err := h.documentTracker.findReferences(params.TextDocument.URI.String(), rangeInfo, cancelationHandle)
+		if err != nil {
+			if cancelationHandle.WasCanceled() {
+				return nil, cancelationHandle.Error()
+			}
+
+			log.Printf("Got error when trying to find references for %s: %v", params.TextDocument.URI, err)
+			return protocol.ReferencesResult([]protocol.Location{}), nil
+		}
+
+		sourceRanges := make([]compilercommon.SourceRange, 0, len(references))
+		for _, reference := range references {
+			if reference.isDeclaration && !params.Context.IncludeDeclaration {
+				continue
+			}
+
+			sourceRanges = append(sourceRanges, reference.sourceRange)
+		}
+
+		locations := h.documentTracker.convertRanges(sourceRanges)
+		return protocol.ReferencesResult(locations), nil
+
+	// Document highlight.
+	case protocol.DocumentHighlightRequest:
+		params := protocol.DocumentHighlightParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Got document highlight request for document %s at position %v:%v\n", params.TextDocument.URI, params.Position.Line, params.Position.Column)
+		rangeInfo, _, status := h.lookupRange(params.TextDocument.URI, params.Position, cancelationHandle)
+		if !status {
+			log.Printf("No valid range found for document %s at position %v:%v\n", params.TextDocument.URI, params.Position.Line, params.Position.Column)
+			return protocol.DocumentHighlightResult([]protocol.DocumentHighlight{}), nil
+		}
+
+		if cancelationHandle.WasCanceled() {
+			return nil, cancelationHandle.Error()
+		}
+
+		highlights, err := h.documentTracker.documentHighlights(params.TextDocument.URI.String(), rangeInfo, cancelationHandle)
+		if err != nil {
+			if cancelationHandle.WasCanceled() {
+				return nil, cancelationHandle.Error()
+			}
+
+			log.Printf("Got error when trying to find highlights for %s: %v", params.TextDocument.URI, err)
+			return protocol.DocumentHighlightResult([]protocol.DocumentHighlight{}), nil
+		}
+
+		return protocol.DocumentHighlightResult(highlights), nil
+
+	// Prepare rename.
+	case protocol.PrepareRenameRequest:
+		params := protocol.PrepareRenameParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Got prepare rename request for document %s at position %v:%v\n", params.TextDocument.URI, params.Position.Line, params.Position.Column)
+		rangeInfo, _, status := h.lookupRange(params.TextDocument.URI, params.Position, cancelationHandle)
+		if !status {
+			return nil, nil
+		}
+
+		if cancelationHandle.WasCanceled() {
+			return nil, cancelationHandle.Error()
+		}
+
+		err = h.documentTracker.checkRenameable(rangeInfo)
+		if err != nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidRequest, Message: err.Error()}
+		}
+
+		name, _ := rangeInfo.Name()
+		nameRange, found := h.documentTracker.nameRangeAtPosition(params.TextDocument.URI.String(), name, params.Position)
+		if !found {
+			return nil, nil
+		}
+
+		return protocol.PrepareRenameResult{
+			Range:       nameRange,
+			Placeholder: name,
+		}, nil
+
+	// Rename.
+	case protocol.RenameRequest:
+		params := protocol.RenameParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Got rename request for document %s at position %v:%v to `%s`\n", params.TextDocument.URI, params.Position.Line, params.Position.Column, params.NewName)
+		if !isValidIdentifier(params.NewName) {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("`%s` is not a valid name", params.NewName)}
+		}
+
+		rangeInfo, _, status := h.lookupRange(params.TextDocument.URI, params.Position, cancelationHandle)
+		if !status {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidRequest, Message: "No symbol found to rename"}
+		}
+
+		if cancelationHandle.WasCanceled() {
+			return nil, cancelationHandle.Error()
+		}
+
+		err = h.documentTracker.checkRenameable(rangeInfo)
+		if err != nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidRequest, Message: err.Error()}
+		}
+
+		references, err := h.documentTracker.findReferences(params.TextDocument.URI.String(), rangeInfo, cancelationHandle)
+		if err != nil {
+			if cancelationHandle.WasCanceled() {
+				return nil, cancelationHandle.Error()
+			}
+
+			log.Printf("Got error when trying to find references for %s: %v", params.TextDocument.URI, err)
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInternalError, Message: "Could not find references to rename"}
+		}
+
+		if cancelationHandle.WasCanceled() {
+			return nil, cancelationHandle.Error()
+		}
+
+		edit := h.documentTracker.buildRenameEdit(references, params.NewName, h.supportsDocumentChanges())
+		return protocol.RenameResult(edit), nil
+
+	// Hover.
+	case protocol.HoverRequest:
+		params := protocol.HoverParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Got hover request for document %s at position %v:%v\n", params.TextDocument.URI, params.Position.Line, params.Position.Column)
+		rangeInfo, _, status := h.lookupRange(params.TextDocument.URI, params.Position, cancelationHandle)
+		if !status {
+			return protocol.HoverResult{
+				Contents: []interface{}{},
+			}, nil
+		}
+
+		markedTexts := rangeInfo.HumanReadable()
+		markedStrings := make([]interface{}, len(markedTexts))
+		for index, hr := range markedTexts {
+			if hr.Kind == grok.SerulianCodeText {
+				markedStrings[index] = protocol.MarkedString{"serulian", hr.Value}
+			} else {
+				markedStrings[index] = hr.Value
+			}
+		}
+
+		return protocol.HoverResult{
+			Contents: markedStrings,
+		}, nil
+
+	// Document opened.
+	case protocol.DidOpenTextDocumentNotification:
+		params := protocol.DidOpenTextDocumentParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		if h.documentTracker.tracksLanguage(params.TextDocument.LanguageID) {
+			log.Printf("Document opened: %s\n", params.TextDocument.URI)
+			h.documentTracker.openDocument(ctx, conn, params.TextDocument.URI.String(), params.TextDocument.Text, params.TextDocument.Version)
+		}
+		return nil, nil
+
+	// Document updated.
+	case protocol.DidChangeTextDocumentNotification:
+		params := protocol.DidChangeTextDocumentParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Document updated: %s\n", params.TextDocument.URI)
+		h.documentTracker.updateDocument(ctx, conn, params.TextDocument.URI.String(), params.ContentChanges, params.TextDocument.Version)
+		return nil, nil
+
+	// Document closed.
+	case protocol.DidCloseTextDocumentNotification:
+		params := protocol.DidCloseTextDocumentParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		if h.documentTracker.tracksLanguage(params.TextDocument.LanguageID) {
+			log.Printf("Document closed: %s\n", params.TextDocument.URI)
+			h.documentTracker.closeDocument(ctx, conn, params.TextDocument.URI.String())
+		}
+		return nil, nil
+
+	// Configuration changed.
+	case protocol.DidChangeConfigurationNotification:
+		params := protocol.DidChangeConfigurationParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Configuration changed\n")
+
+		// If the client supports pulling the configuration, the settings sent (if any) may not be
+		// complete, so retrieve them. As the client must be called to do so, the settings are retrieved
+		// and applied outside of this (synchronous) notification, and are not tracked as in-flight.
+		if h.supportsConfiguration() {
+			go h.pullSettings(ctx, conn)
+			return nil, nil
+		}
+
+		if params.Settings != nil && params.Settings.Serulian != nil {
+			h.applySettings(ctx, conn, *params.Settings.Serulian, true)
+		}
+		return nil, nil
+
+	// Watched files changed.
+	case protocol.DidChangeWatchedFilesNotification:
+		params := protocol.DidChangeWatchedFilesParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Watched files changed: %v\n", params.Changes)
+		h.documentTracker.watchedFilesChanged(ctx, conn, params.Changes)
+		return nil, nil
+
+	// Workspace folders changed.
+	case protocol.DidChangeWorkspaceFoldersNotification:
+		params := protocol.DidChangeWorkspaceFoldersParams{}
+		err := h.decodeParameters(req, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Workspace folders changed: %v\n", params.Event)
+		h.changeWorkspaceFolders(ctx, conn, params.Event)
+		return nil, nil
+
+	// Shutdown request.
+	case protocol.ShutdownMethod:
+		log.Printf("Shutting down")
+		h.shutdown(req.ID)
+		return nil, nil
+
+	// Exit notification.
+	case protocol.ExitNotification:
+		os.Exit(-1) // -1 since we haven't received the shutdown notification.
+	}
+
+	return nil, nil
+}
+
+// lookupRange performs lookup of the range matching the given position in the given document.
+func (h *SerulianLangServerHandler) lookupRange(uri protocol.DocumentURI, position protocol.Position, cancelationHandle *CancelationHandle) (grok.RangeInformation, error, bool) {
+	// Make sure we are tracking this document.
+	if !h.documentTracker.isTracking(uri.String()) {
+		log.Printf("Not tracking document %s\n", uri)
+		return grok.RangeInformation{}, nil, false
+	}
+
+	if cancelationHandle.WasCanceled() {
+		return grok.RangeInformation{}, cancelationHandle.Error(), false
+	}
+
+	// Grab a Grok handle for the document.
+	handle, err := h.documentTracker.getGrokHandle(uri.String(), grok.HandleMustBeFresh)
+	if err != nil {
+		log.Printf("Got error when trying to get grok handle for %s: %v", uri, err)
+		return grok.RangeInformation{}, err, false
+	}
+
+	if cancelationHandle.WasCanceled() {
+		return grok.RangeInformation{}, cancelationHandle.Error(), false
+	}
+
+	// Retrieve the path.
+	path, err := h.documentTracker.uriToPath(uri.String())
+	if err != nil {
+		log.Printf("Got error when trying to convert URI to path for %s: %v", uri, err)
+		return grok.RangeInformation{}, err, false
+	}
+
+	// Convert the position's column into the compiler's column.
+	source := compilercommon.InputSource(path)
+	column, err := h.documentTracker.compilerColumn(source, position)
+	if err != nil {
+		log.Printf("Got error when trying to convert position for %s: %v", uri, err)
+		return grok.RangeInformation{}, err, false
+	}
+
+	// Lookup the position via Grok.
+	rangeInfo, err := handle.LookupPosition(source, position.Line, column)
+	if err != nil {
+		log.Printf("Got error when trying to lookup range for %s: %v", uri, err)
+		return grok.RangeInformation{}, err, false
+	}
+
+	// If we found a result, turn it into code and report it.
+	log.Printf("Got result: %v => %v", rangeInfo.Kind, rangeInfo.HumanReadable())
+	return rangeInfo, nil, rangeInfo.Kind != grok.NotFound
+}
+
+// symbolInfoFromSymbol converts a Grok Symbol into a SymbolInformation struct.
+func (h *SerulianLangServerHandler) symbolInfoFromSymbol(symbol grok.Symbol) (protocol.SymbolInformation, bool) {
+	if len(symbol.SourceRanges) < 1 {
+		return protocol.SymbolInformation{}, false
+	}
+
+	var containerName *string
+	var symbolKind = protocol.SymbolFile
+
+	switch symbol.Kind {
+	case grok.TypeSymbol:
+		symbolKind = symbolKindForType(*symbol.Type)
+
+	case grok.MemberSymbol:
+		if containingType, hasContainingType := symbol.Member.ParentType(); hasContainingType {
+			typeName := containingType.Name()
+			containerName = &typeName
+		}
+
+		symbolKind = symbolKindForMember(*symbol.Member)
+
+	case grok.ModuleSymbol:
+		symbolKind = protocol.SymbolFile
+
+	default:
+		panic("Unknown kind of Grok symbol")
+	}
+
+	ranges := h.documentTracker.convertRanges(symbol.SourceRanges)
+	if len(ranges) == 0 {
+		return protocol.SymbolInformation{}, false
 	}
 
 	return protocol.SymbolInformation{
@@ -872,6 +1593,70 @@ func (h *SerulianLangServerHandler) symbolInfoFromSymbol(symbol grok.Symbol) (pr
 	}, true
 }
 
+// documentSymbolResult returns the result for the document symbol request, in the form supported by the client.
+func (h *SerulianLangServerHandler) documentSymbolResult(uri protocol.DocumentURI, symbols []protocol.DocumentSymbol) protocol.DocumentSymbolResult {
+	if h.supportsHierarchicalDocumentSymbols() {
+		return protocol.DocumentSymbolResult(symbols)
+	}
+
+	return protocol.DocumentSymbolResult(flattenDocumentSymbols(uri, symbols, nil))
+}
+
+// symbolKindForType returns the kind of symbol for the given type.
+func symbolKindForType(typeDecl typegraph.TGTypeDecl) protocol.SymbolKind {
+	switch typeDecl.TypeKind() {
+	case typegraph.StructType:
+		return protocol.SymbolStruct
+
+	case typegraph.AgentType:
+		fallthrough
+
+	case typegraph.ClassType:
+		return protocol.SymbolClass
+
+	case typegraph.NominalType:
+		return protocol.SymbolObject
+
+	case typegraph.ImplicitInterfaceType:
+		fallthrough
+
+	case typegraph.ExternalInternalType:
+		return protocol.SymbolInterface
+
+	case typegraph.AliasType:
+		fallthrough
+
+	case typegraph.GenericType:
+		return protocol.SymbolTypeParameter
+
+	default:
+		panic("Unknown kind of type")
+	}
+}
+
+// symbolKindForMember returns the kind of symbol for the given member.
+func symbolKindForMember(member typegraph.TGMember) protocol.SymbolKind {
+	_, isConstructor := member.ConstructorType()
+	_, hasReturnType := member.ReturnType()
+
+	switch {
+	case member.IsOperator():
+		return protocol.SymbolOperator
+
+	case isConstructor:
+		return protocol.SymbolConstructor
+
+	case member.IsField():
+		return protocol.SymbolField
+
+	case hasReturnType:
+		return protocol.SymbolFunction
+
+	default:
+		return protocol.SymbolProperty
+	}
+}
+
 // markdownContent returns the value wrapped into a MarkupContent indicating it is markdown.
 func markdownContent(value string) protocol.MarkupContent {
 	return protocol.MarkupContent{
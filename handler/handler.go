@@ -10,6 +10,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sync"
 
 	"github.com/serulian/serulian-langserver/protocol"
 
@@ -28,27 +29,57 @@ const (
 
 // SerulianLangServerHandler defines a JSON-RPC handler that implements the Serulian language server.
 type SerulianLangServerHandler struct {
-	// currentState holds the current state of the language server.
+	// currentState holds the current state of the language server. Must only be accessed via getState
+	// and setState, as it is read by the requests executed asynchronously.
 	currentState langServerState
 
+	// stateLock guards currentState.
+	stateLock sync.RWMutex
+
 	// entrypointSourceFile is, if specified, the entrypoint source file for the current workspace.
-	// If empty, the workspace's root will be used instead.
+	// If empty, the workspace's folders (or its root) will be used instead.
 	entrypointSourceFile string
 
+	// clientCapabilities holds the capabilities of the client, as reported on initialization.
+	clientCapabilities protocol.ClientCapabilities
+
 	// documentTracker defines a tracker for managing the state of all open documents (source files).
 	documentTracker *documentTracker
 
 	// cancelationHandles is a map from request ID to its associated cancelation handle.
 	cancelationHandles cmap.ConcurrentMap
+
+	// inFlight tracks the requests currently being executed asynchronously.
+	inFlight sync.WaitGroup
+
+	// synchronousLock serializes the calls which modify the document tracker, whether made by the client
+	// or by the file watcher.
+	synchronousLock sync.Mutex
+
+	// watchFiles indicates whether the server watches the workspace for changes to files on disk, if the
+	// client does not support doing so.
+	watchFiles bool
+
+	// fileWatchIgnorePatterns are the glob patterns of the files and directories not watched.
+	fileWatchIgnorePatterns []string
+
+	// fileWatcher is the server-side file watcher, if one was started. Must only be accessed with the
+	// synchronousLock held.
+	fileWatcher *fileWatcher
 }
 
 // NewHandler creates a Serulian language server handler.
-func NewHandler(entrypointSourceFile string, vcsDevelopmentDirectories []string) jsonrpc2.Handler {
+// If watchFiles is true, the server watches the workspace for changes to files on disk when the
+// client does not support doing so, skipping any files and directories which match one of the
+// ignore patterns, either by name or by path relative to their workspace folder.
+func NewHandler(entrypointSourceFile string, vcsDevelopmentDirectories []string, watchFiles bool, fileWatchIgnorePatterns []string) jsonrpc2.Handler {
 	return &SerulianLangServerHandler{
-		currentState:         statePreInitialized,
-		entrypointSourceFile: entrypointSourceFile,
-		documentTracker:      newDocumentTracker(vcsDevelopmentDirectories),
-		cancelationHandles:   cmap.New(),
+		currentState:            statePreInitialized,
+		entrypointSourceFile:    entrypointSourceFile,
+		documentTracker:         newDocumentTracker(vcsDevelopmentDirectories),
+		cancelationHandles:      cmap.New(),
+		watchFiles:              watchFiles,
+		fileWatchIgnorePatterns: fileWatchIgnorePatterns,
 	}
 }
 
@@ -69,33 +100,47 @@ func (h *SerulianLangServerHandler) Handle(ctx context.Context, conn *jsonrpc2.C
 		return
 	}
 
-	// If the call must be executed synchronously, do so directly.
-	if h.requiresSynchronousExecution(req.Method) {
+	// If the call must be executed synchronously, do so directly. Once shutting down, no new work is
+	// accepted, so calls are answered directly rather than being tracked as in-flight.
+	if h.requiresSynchronousExecution(req.Method) || h.getState() == stateShuttingDown {
+		h.synchronousLock.Lock()
+		defer h.synchronousLock.Unlock()
+
 		jsonrpc2.HandlerWithError(h.syncHandle).Handle(ctx, conn, req)
 		return
 	}
 
 	// Otherwise, execute the call via a goroutine so that it doesn't block other calls.
-	go jsonrpc2.HandlerWithError(h.syncHandle).Handle(ctx, conn, req)
+	h.inFlight.Add(1)
+	go func() {
+		defer h.inFlight.Done()
+		jsonrpc2.HandlerWithError(h.syncHandle).Handle(ctx, conn, req)
+	}()
 }
 
 // requiresSynchronousExecution returns if the given method must be executed synchronously, because it modifies
-// the document tracker. Idea based on isFileSystemRequest in https://github.com/sourcegraph/go-langserver.
+// the document tracker or the state of the server. Idea based on isFileSystemRequest in https://github.com/sourcegraph/go-langserver.
 func (h *SerulianLangServerHandler) requiresSynchronousExecution(method string) bool {
-	return method == protocol.DidOpenTextDocumentNotification ||
+	return method == protocol.ShutdownMethod ||
+		method == protocol.InitializedNotification ||
+		method == protocol.DidOpenTextDocumentNotification ||
 		method == protocol.DidChangeTextDocumentNotification ||
-		method == protocol.DidCloseTextDocumentNotification
+		method == protocol.DidCloseTextDocumentNotification ||
+		method == protocol.DidChangeWatchedFilesNotification ||
+		method == protocol.DidChangeWorkspaceFoldersNotification ||
+		method == protocol.DidChangeConfigurationNotification
 }
 
 // syncHandle is a synchronous handler for the language server requests.
 func (h *SerulianLangServerHandler) syncHandle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (result interface{}, err error) {
-	log.Printf("(%v) Got request: %v\n", h.currentState, req)
+	currentState := h.getState()
+	log.Printf("(%v) Got request: %v\n", currentState, req)
 
 	cancelationHandle := NewCancelationHandle(req.ID)
 	h.cancelationHandles.Set(req.ID.String(), cancelationHandle)
 	defer h.cancelationHandles.Remove(req.ID.String())
 
-	switch h.currentState {
+	switch currentState {
 	case statePreInitialized:
 		return h.handlePreInit(ctx, conn, req)
 
@@ -112,6 +157,20 @@ func (h *SerulianLangServerHandler) syncHandle(ctx context.Context, conn *jsonrp
 	return nil, fmt.Errorf("Missing handler for method %s", req.Method)
 }
 
+// getState returns the current state of the language server.
+func (h *SerulianLangServerHandler) getState() langServerState {
+	h.stateLock.RLock()
+	defer h.stateLock.RUnlock()
+	return h.currentState
+}
+
+// setState changes the current state of the language server.
+func (h *SerulianLangServerHandler) setState(state langServerState) {
+	h.stateLock.Lock()
+	defer h.stateLock.Unlock()
+	h.currentState = state
+}
+
 // decodeParameters attempts to decode the parameters of the request into the given struct. If it fails,
 // the proper JSON-RPC error is returned indicating the failure.
 func (h *SerulianLangServerHandler) decodeParameters(req *jsonrpc2.Request, paramsStruct interface{}) error {
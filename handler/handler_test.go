@@ -0,0 +1,325 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/serulian/serulian-langserver/protocol"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// testTimeout is the maximum duration waited by the tests for the server to answer a call or reach an expected
+// state. Building the first Grok handle of a workspace includes fetching the core library.
+const testTimeout = time.Minute
+
+// testServer runs a language server over an in-memory connection, acting as its client over a workspace
+// created in a temporary directory.
+type testServer struct {
+	t *testing.T
+
+	// handler is the handler of the server.
+	handler *SerulianLangServerHandler
+
+	// conn is the client's end of the connection to the server.
+	conn *jsonrpc2.Conn
+
+	// workspaceDirectory is the root directory of the workspace.
+	workspaceDirectory string
+
+	// diagnosticsLock guards diagnostics.
+	diagnosticsLock sync.Mutex
+
+	// diagnostics holds the diagnostics last published for each URI.
+	diagnostics map[string][]protocol.Diagnostic
+
+	// diagnosticsPublished receives a value each time diagnostics are published.
+	diagnosticsPublished chan struct{}
+
+	// registrations receives the parameters of each request made by the server to register capabilities.
+	registrations chan protocol.RegistrationParams
+}
+
+// newTestServer creates a workspace containing the given files, keyed by their path relative to the root of
+// the workspace, and starts a language server over it.
+func newTestServer(t *testing.T, files map[string]string) *testServer {
+	return startTestServer(t, files, protocol.InitializeParams{})
+}
+
+// startTestServer creates a workspace containing the given files, keyed by their path relative to the root of
+// the workspace, and starts a language server over it, initialized with the given parameters. The root of the
+// workspace is added to the parameters, and the URIs of any workspace folders, given by their path relative to
+// the root of the workspace, are resolved.
+func startTestServer(t *testing.T, files map[string]string, params protocol.InitializeParams) *testServer {
+	directory, err := ioutil.TempDir("", "serulian-langserver-test")
+	if err != nil {
+		t.Fatalf("Could not create workspace directory: %v", err)
+	}
+
+	// Resolve any symlinks in the temporary directory, so that paths match those reported by the server.
+	directory, err = filepath.EvalSymlinks(directory)
+	if err != nil {
+		t.Fatalf("Could not resolve workspace directory: %v", err)
+	}
+
+	ts := &testServer{
+		t:                    t,
+		handler:              NewHandler("", []string{}, false, []string{}).(*SerulianLangServerHandler),
+		workspaceDirectory:   directory,
+		diagnostics:          map[string][]protocol.Diagnostic{},
+		diagnosticsPublished: make(chan struct{}, 1),
+		registrations:        make(chan protocol.RegistrationParams, 4),
+	}
+
+	for name, contents := range files {
+		ts.writeFile(name, contents)
+	}
+
+	serverStream, clientStream := net.Pipe()
+	jsonrpc2.NewConn(context.Background(), jsonrpc2.NewBufferedStream(serverStream, jsonrpc2.VSCodeObjectCodec{}), ts.handler)
+	ts.conn = jsonrpc2.NewConn(context.Background(), jsonrpc2.NewBufferedStream(clientStream, jsonrpc2.VSCodeObjectCodec{}), jsonrpc2.HandlerWithError(ts.handleServerCall))
+
+	params.RootURI = protocol.DocumentURI(ts.uri(""))
+	for index, folder := range params.WorkspaceFolders {
+		params.WorkspaceFolders[index].URI = protocol.DocumentURI(ts.uri(folder.URI.String()))
+	}
+
+	ts.call(protocol.InitializeMethod, params, &protocol.InitializeResult{})
+	ts.notify(protocol.InitializedNotification, struct{}{})
+
+	// The server handles the initialized notification asynchronously, so wait for it to answer requests.
+	deadline := time.Now().Add(testTimeout)
+	for ts.conn.Call(context.Background(), "test/ping", struct{}{}, nil) != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the server to be initialized")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return ts
+}
+
+// handleServerCall handles the requests and notifications sent by the server to the client.
+func (ts *testServer) handleServerCall(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (interface{}, error) {
+	if req.Params == nil {
+		return nil, nil
+	}
+
+	if req.Method == protocol.RegisterCapabilityRequest {
+		params := protocol.RegistrationParams{}
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+
+		select {
+		case ts.registrations <- params:
+		default:
+		}
+		return nil, nil
+	}
+
+	if req.Method != protocol.PublicDiagonsticsNotification {
+		return nil, nil
+	}
+
+	params := protocol.PublishDiagnosticsParams{}
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return nil, err
+	}
+
+	ts.diagnosticsLock.Lock()
+	ts.diagnostics[params.URI.String()] = params.Diagnostics
+	ts.diagnosticsLock.Unlock()
+
+	select {
+	case ts.diagnosticsPublished <- struct{}{}:
+	default:
+	}
+
+	return nil, nil
+}
+
+// close stops the server and removes the workspace.
+func (ts *testServer) close() {
+	ts.conn.Close()
+	os.RemoveAll(ts.workspaceDirectory)
+}
+
+// path returns the path of the file with the given path relative to the root of the workspace.
+func (ts *testServer) path(name string) string {
+	return filepath.Join(ts.workspaceDirectory, name)
+}
+
+// uri returns the URI of the file with the given path relative to the root of the workspace.
+func (ts *testServer) uri(name string) string {
+	return "file://" + ts.path(name)
+}
+
+// writeFile writes the given contents to the file with the given path relative to the root of the workspace.
+func (ts *testServer) writeFile(name string, contents string) {
+	filePath := ts.path(name)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		ts.t.Fatalf("Could not create directory for %s: %v", name, err)
+	}
+
+	if err := ioutil.WriteFile(filePath, []byte(contents), 0644); err != nil {
+		ts.t.Fatalf("Could not write %s: %v", name, err)
+	}
+}
+
+// open opens the document with the given path relative to the root of the workspace, with its contents on disk.
+func (ts *testServer) open(name string) {
+	contents := ts.readFile(name)
+	ts.notify(protocol.DidOpenTextDocumentNotification, protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{
+			URI:        protocol.DocumentURI(ts.uri(name)),
+			LanguageID: "serulian",
+			Version:    1,
+			Text:       contents,
+		},
+	})
+}
+
+// readFile returns the contents of the file with the given path relative to the root of the workspace.
+func (ts *testServer) readFile(name string) string {
+	contents, err := ioutil.ReadFile(ts.path(name))
+	if err != nil {
+		ts.t.Fatalf("Could not read %s: %v", name, err)
+	}
+
+	return string(contents)
+}
+
+// position returns the position of the given occurrence (starting at zero) of the given text in the file with
+// the given path relative to the root of the workspace, adding the given offset in bytes.
+func (ts *testServer) position(name string, text string, occurrence int, offset int) protocol.TextDocumentPositionParams {
+	contents := ts.readFile(name)
+	index := nthIndex(contents, text, occurrence)
+	if index < 0 {
+		ts.t.Fatalf("Could not find occurrence %v of %q in %s", occurrence, text, name)
+	}
+
+	return protocol.TextDocumentPositionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(ts.uri(name))},
+		Position:     positionForOffset(contents, index+offset, protocol.PositionEncodingUTF16),
+	}
+}
+
+// nthIndex returns the byte offset of the given occurrence (starting at zero) of the given text in the contents,
+// or -1 if none.
+func nthIndex(contents string, text string, occurrence int) int {
+	index := -1
+	for i := 0; i <= occurrence; i++ {
+		found := strings.Index(contents[index+1:], text)
+		if found < 0 {
+			return -1
+		}
+		index = index + 1 + found
+	}
+	return index
+}
+
+// describeLocation describes the given location as the path of its document relative to the root of the
+// workspace, followed by its start position.
+func (ts *testServer) describeLocation(uri protocol.DocumentURI, documentRange protocol.Range) string {
+	name := strings.TrimPrefix(strings.TrimPrefix(uri.String(), ts.uri("")), "/")
+	return fmt.Sprintf("%s:%v:%v", name, documentRange.Start.Line, documentRange.Start.Column)
+}
+
+// describeLocations describes the given locations, sorted.
+func (ts *testServer) describeLocations(locations []protocol.Location) []string {
+	described := make([]string, 0, len(locations))
+	for _, location := range locations {
+		described = append(described, ts.describeLocation(location.URI, location.Range))
+	}
+
+	sort.Strings(described)
+	return described
+}
+
+// change replaces the contents of the open document with the given path relative to the root of the workspace,
+// at the given version.
+func (ts *testServer) change(name string, version int, contents string) {
+	ts.notify(protocol.DidChangeTextDocumentNotification, protocol.DidChangeTextDocumentParams{
+		TextDocument: protocol.VersionedTextDocumentIdentifier{
+			TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(ts.uri(name))},
+			Version:                version,
+		},
+		ContentChanges: []protocol.TextDocumentContentChangeEvent{{Text: contents}},
+	})
+}
+
+// closeDocument closes the open document with the given path relative to the root of the workspace.
+func (ts *testServer) closeDocument(name string) {
+	ts.notify(protocol.DidCloseTextDocumentNotification, protocol.DidCloseTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{
+			URI:        protocol.DocumentURI(ts.uri(name)),
+			LanguageID: "serulian",
+		},
+	})
+}
+
+// call invokes the given method on the server, decoding its result into the given result.
+func (ts *testServer) call(method string, params interface{}, result interface{}) {
+	if err := ts.tryCall(method, params, result); err != nil {
+		ts.t.Fatalf("Call to %s failed: %v", method, err)
+	}
+}
+
+// tryCall invokes the given method on the server, decoding its result into the given result, and returns the
+// error answered by the server, if any.
+func (ts *testServer) tryCall(method string, params interface{}, result interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	return ts.conn.Call(ctx, method, params, result)
+}
+
+// notify sends the given notification to the server.
+func (ts *testServer) notify(method string, params interface{}) {
+	if err := ts.conn.Notify(context.Background(), method, params); err != nil {
+		ts.t.Fatalf("Notification %s failed: %v", method, err)
+	}
+}
+
+// sync waits until the server has handled all notifications previously sent to it. The server handles the
+// notifications which modify the document tracker before reading any later message.
+func (ts *testServer) sync() {
+	ts.call("test/ping", struct{}{}, nil)
+}
+
+// waitForDiagnostics waits until the diagnostics last published for the given URI satisfy the given predicate,
+// returning them.
+func (ts *testServer) waitForDiagnostics(uri string, predicate func(diagnostics []protocol.Diagnostic, published bool) bool) []protocol.Diagnostic {
+	deadline := time.After(testTimeout)
+	for {
+		ts.diagnosticsLock.Lock()
+		diagnostics, published := ts.diagnostics[uri]
+		ts.diagnosticsLock.Unlock()
+
+		if predicate(diagnostics, published) {
+			return diagnostics
+		}
+
+		select {
+		case <-ts.diagnosticsPublished:
+		case <-deadline:
+			ts.t.Fatalf("Timed out waiting for diagnostics of %s; last published: %v", uri, diagnostics)
+		}
+	}
+}
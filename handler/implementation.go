@@ -0,0 +1,93 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"github.com/serulian/compiler/compilercommon"
+	"github.com/serulian/compiler/graphs/typegraph"
+	"github.com/serulian/compiler/grok"
+)
+
+// findImplementations returns the source ranges of the implementations of the implicit interface or interface
+// member found by the given range information, which was looked up in the document with the given URI. As
+// interfaces are implicit, the implementations are all types in the workspace which structurally satisfy the
+// interface, along with their matching member.
+func (dt *documentTracker) findImplementations(uri string, target grok.RangeInformation, cancelationHandle *CancelationHandle) ([]compilercommon.SourceRange, error) {
+	if len(target.SourceRanges) == 0 {
+		return []compilercommon.SourceRange{}, nil
+	}
+
+	handle, err := dt.hierarchyHandle(uri, target.SourceRanges[0].Source())
+	if err != nil {
+		return []compilercommon.SourceRange{}, err
+	}
+
+	interfaceDecl, member, isMember, found := implementationTarget(handle, target)
+	if !found {
+		return []compilercommon.SourceRange{}, nil
+	}
+
+	sourceRanges := []compilercommon.SourceRange{}
+	for _, typeDecl := range hierarchyTypes(handle) {
+		if cancelationHandle.WasCanceled() {
+			return []compilercommon.SourceRange{}, cancelationHandle.Error()
+		}
+
+		if typeDecl.TypeKind() == typegraph.ImplicitInterfaceType || !satisfiesInterface(typeDecl, interfaceDecl) {
+			continue
+		}
+
+		implementationRanges := typeDecl.SourceRanges()
+		if isMember {
+			implementingMember, found := typeDecl.GetMemberOrOperator(member.Name())
+			if !found {
+				continue
+			}
+
+			implementationRanges = implementingMember.SourceRanges()
+		}
+
+		for _, sourceRange := range implementationRanges {
+			if dt.isWorkspaceSource(sourceRange.Source()) {
+				sourceRanges = append(sourceRanges, sourceRange)
+			}
+		}
+	}
+
+	return sourceRanges, nil
+}
+
+// implementationTarget returns the implicit interface referred to by the given range information, either
+// directly or via one of its members. If a member is referred to, it is returned as well.
+func implementationTarget(handle grok.Handle, rangeInfo grok.RangeInformation) (typegraph.TGTypeDecl, typegraph.TGMember, bool, bool) {
+	if typeDecl, isType := typeForRange(handle, rangeInfo); isType {
+		return typeDecl, typegraph.TGMember{}, false, typeDecl.TypeKind() == typegraph.ImplicitInterfaceType
+	}
+
+	if rangeInfo.Kind != grok.NamedReference {
+		return typegraph.TGTypeDecl{}, typegraph.TGMember{}, false, false
+	}
+
+	member, isMember := rangeInfo.NamedReference.Member()
+	if !isMember {
+		// A lookup on a declaration refers to its definition in source, so find the member declared there.
+		startRune, err := rangeInfo.SourceRanges[0].Start().RunePosition()
+		if err != nil {
+			return typegraph.TGTypeDecl{}, typegraph.TGMember{}, false, false
+		}
+
+		member, isMember = memberDeclaredAt(handle, rangeInfo.SourceRanges[0].Source(), startRune)
+		if !isMember {
+			return typegraph.TGTypeDecl{}, typegraph.TGMember{}, false, false
+		}
+	}
+
+	parentType, hasParentType := member.ParentType()
+	if !hasParentType || parentType.TypeKind() != typegraph.ImplicitInterfaceType {
+		return typegraph.TGTypeDecl{}, typegraph.TGMember{}, false, false
+	}
+
+	return parentType, member, true, true
+}
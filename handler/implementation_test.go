@@ -0,0 +1,64 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+var implementationFiles = map[string]string{
+	"main.seru": `interface Greeter {
+	function Greet() string
+}
+
+class Person {
+	function Greet() string {
+		return 'hello'
+	}
+}
+
+class Rock {
+	function Roll() {}
+}
+`,
+	"other.seru": `class Robot {
+	function Greet() string {
+		return 'beep'
+	}
+}
+`,
+}
+
+func TestFindImplementations(t *testing.T) {
+	ts := newTestServer(t, implementationFiles)
+	defer ts.close()
+	ts.open("main.seru")
+
+	tests := []struct {
+		name       string
+		text       string
+		occurrence int
+		expected   []string
+	}{
+		{"interface", "Greeter", 0, []string{"main.seru:4:0", "other.seru:0:0"}},
+		{"interface member", "Greet", 1, []string{"main.seru:5:1", "other.seru:1:1"}},
+		{"class", "Person", 0, []string{}},
+		{"class member", "Roll", 0, []string{}},
+	}
+
+	for _, test := range tests {
+		locations := []protocol.Location{}
+		ts.call(protocol.ImplementationRequest, protocol.ImplementationParams{
+			TextDocumentPositionParams: ts.position("main.seru", test.text, test.occurrence, 0),
+		}, &locations)
+
+		if described := ts.describeLocations(locations); !reflect.DeepEqual(described, test.expected) {
+			t.Errorf("%s: expected implementations %v, found %v", test.name, test.expected, described)
+		}
+	}
+}
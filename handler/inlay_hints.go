@@ -0,0 +1,427 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/serulian/compiler/compilercommon"
+	"github.com/serulian/compiler/graphs/typegraph"
+	"github.com/serulian/compiler/grok"
+	"github.com/serulian/compiler/sourceshape"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+// inlayHintSettings defines which kinds of inlay hints are shown.
+type inlayHintSettings struct {
+	// variableTypes indicates whether the inferred types of variables are shown.
+	variableTypes bool
+
+	// parameterNames indicates whether the names of parameters are shown for literal arguments.
+	parameterNames bool
+}
+
+// defaultInlayHintSettings defines the inlay hint settings used until the client provides its own.
+var defaultInlayHintSettings = inlayHintSettings{
+	variableTypes:  true,
+	parameterNames: true,
+}
+
+// withOverrides returns a copy of the settings with those specified by the client applied.
+func (s inlayHintSettings) withOverrides(overrides *protocol.InlayHintSettings) inlayHintSettings {
+	if overrides == nil {
+		return s
+	}
+
+	if overrides.VariableTypes != nil {
+		s.variableTypes = *overrides.VariableTypes
+	}
+
+	if overrides.ParameterNames != nil {
+		s.parameterNames = *overrides.ParameterNames
+	}
+
+	return s
+}
+
+// literalArgumentTypes defines the types of argument nodes for which parameter name hints are shown.
+var literalArgumentTypes = map[sourceshape.NodeType]bool{
+	sourceshape.NodeNumericLiteralExpression: true,
+	sourceshape.NodeStringLiteralExpression:  true,
+	sourceshape.NodeBooleanLiteralExpression: true,
+	sourceshape.NodeNullLiteralExpression:    true,
+	sourceshape.NodeTypeTemplateString:       true,
+}
+
+// inlayHints returns the inlay hints for the given range of the document with the given URI.
+func (dt *documentTracker) inlayHints(uri string, documentRange protocol.Range, cancelationHandle *CancelationHandle) ([]protocol.InlayHint, error) {
+	settings := dt.documentSettings(uri).inlayHints
+	if !settings.variableTypes && !settings.parameterNames {
+		return []protocol.InlayHint{}, nil
+	}
+
+	handle, document, err := dt.getGrokHandleAndDocument(uri, grok.HandleMustBeFresh)
+	if err != nil {
+		return []protocol.InlayHint{}, err
+	}
+
+	startOffset, err := offsetForPosition(document.contents, documentRange.Start, dt.positionEncoding)
+	if err != nil {
+		return []protocol.InlayHint{}, err
+	}
+
+	endOffset, err := offsetForPosition(document.contents, documentRange.End, dt.positionEncoding)
+	if err != nil {
+		return []protocol.InlayHint{}, err
+	}
+
+	source := compilercommon.InputSource(document.path)
+	rootNode := dt.documentParseTree(document)
+	builder := &inlayHintBuilder{
+		dt:          dt,
+		uri:         uri,
+		handle:      handle,
+		source:      source,
+		contents:    document.contents,
+		mapper:      compilercommon.CreateSourcePositionMapper([]byte(document.contents)),
+		startOffset: startOffset,
+		endOffset:   endOffset,
+		hints:       []protocol.InlayHint{},
+	}
+
+	rootNode.walk(func(node *parseNode) bool {
+		if cancelationHandle.WasCanceled() {
+			return false
+		}
+
+		startRune, endRune, ok := node.runeRange()
+		if ok && (endRune < startOffset || startRune > endOffset) {
+			return false
+		}
+
+		switch node.nodeType {
+		case sourceshape.NodeTypeVariableStatement:
+			if settings.variableTypes {
+				builder.addVariableStatementHint(node)
+			}
+
+		case sourceshape.NodeTypeLoopStatement:
+			if settings.variableTypes {
+				if namedValue, ok := node.tryGetChild(sourceshape.NodeStatementNamedValue); ok {
+					builder.addNamedValueHint(namedValue)
+				}
+			}
+
+		case sourceshape.NodeTypeLoopExpression:
+			if settings.variableTypes {
+				if namedValue, ok := node.tryGetChild(sourceshape.NodeLoopExpressionNamedValue); ok {
+					builder.addNamedValueHint(namedValue)
+				}
+			}
+
+		case sourceshape.NodeFunctionCallExpression:
+			if settings.parameterNames {
+				builder.addParameterNameHints(node)
+			}
+		}
+
+		return true
+	})
+
+	if cancelationHandle.WasCanceled() {
+		return []protocol.InlayHint{}, cancelationHandle.Error()
+	}
+
+	return builder.hints, nil
+}
+
+// resolveInlayHint attaches the hover documentation for the position recorded in the inlay hint's
+// data, if any.
+func (dt *documentTracker) resolveInlayHint(hint protocol.InlayHint) protocol.InlayHint {
+	dataMap, ok := hint.Data.(map[string]interface{})
+	if !ok {
+		return hint
+	}
+
+	uri, hasURI := dataMap["uri"].(string)
+	path, hasPath := dataMap["path"].(string)
+	line, hasLine := dataMap["line"].(float64)
+	column, hasColumn := dataMap["column"].(float64)
+	if !hasURI || !hasPath || !hasLine || !hasColumn {
+		return hint
+	}
+
+	handle, err := dt.getGrokHandle(uri, grok.HandleAllowStale)
+	if err != nil {
+		return hint
+	}
+
+	rangeInfo, err := handle.LookupPosition(compilercommon.InputSource(path), int(line), int(column))
+	if err != nil || rangeInfo.Kind == grok.NotFound {
+		return hint
+	}
+
+	pieces := []string{}
+	for _, hr := range rangeInfo.HumanReadable() {
+		if hr.Kind == grok.SerulianCodeText {
+			pieces = append(pieces, fmt.Sprintf("```serulian\n%s\n```", hr.Value))
+		} else {
+			pieces = append(pieces, hr.Value)
+		}
+	}
+
+	if len(pieces) == 0 {
+		return hint
+	}
+
+	tooltip := markdownContent(strings.Join(pieces, "\n\n"))
+	hint.Tooltip = &tooltip
+	return hint
+}
+
+// inlayHintBuilder collects the inlay hints for a document.
+type inlayHintBuilder struct {
+	dt          *documentTracker
+	uri         string
+	handle      grok.Handle
+	source      compilercommon.InputSource
+	contents    string
+	mapper      compilercommon.SourcePositionMapper
+	startOffset int
+	endOffset   int
+	hints       []protocol.InlayHint
+}
+
+// addVariableStatementHint adds a hint with the inferred type of the given variable statement, if it
+// has no declared type.
+func (b *inlayHintBuilder) addVariableStatementHint(node *parseNode) {
+	if _, hasDeclaredType := node.tryGetChild(sourceshape.NodeVariableStatementDeclaredType); hasDeclaredType {
+		return
+	}
+
+	name, hasName := node.getProperty(sourceshape.NodeVariableStatementName)
+	startRune, endRune, ok := node.runeRange()
+	if !hasName || !ok {
+		return
+	}
+
+	// The name follows the `var` or `const` keyword.
+	for _, nameRange := range identifierRanges(b.contents[startRune : endRune+1]) {
+		if b.contents[startRune+nameRange[0]:startRune+nameRange[1]] == name {
+			b.addVariableTypeHint(startRune+nameRange[0], startRune+nameRange[1])
+			return
+		}
+	}
+}
+
+// addNamedValueHint adds a hint with the inferred type of the given named value, such as a loop variable.
+func (b *inlayHintBuilder) addNamedValueHint(node *parseNode) {
+	startRune, endRune, ok := node.runeRange()
+	if !ok {
+		return
+	}
+
+	b.addVariableTypeHint(startRune, endRune+1)
+}
+
+// addVariableTypeHint adds a hint with the type of the variable whose name is declared at the given name
+// offset, placed at the given hint offset. The type is found by looking up the declared name.
+func (b *inlayHintBuilder) addVariableTypeHint(nameOffset int, hintOffset int) {
+	if hintOffset < b.startOffset || hintOffset > b.endOffset {
+		return
+	}
+
+	lineNumber, colPosition, err := b.mapper.RunePositionToLineAndCol(nameOffset)
+	if err != nil {
+		return
+	}
+
+	rangeInfo, err := b.handle.LookupPosition(b.source, lineNumber, colPosition)
+	if err != nil || rangeInfo.Kind == grok.NotFound {
+		return
+	}
+
+	typeRef, hasType := b.dt.typeOfRange(b.handle, rangeInfo)
+	if !hasType {
+		return
+	}
+
+	// Point the resolve at the declaration of the type, if any, so its documentation is shown.
+	var data interface{}
+	if typeRef.IsNormal() {
+		typeRanges := typeRef.ReferredType().SourceRanges()
+		if len(typeRanges) > 0 {
+			if typeLine, typeCol, err := typeRanges[0].Start().LineAndColumn(); err == nil {
+				data = b.hintData(typeRanges[0].Source(), typeLine, typeCol)
+			}
+		}
+	}
+
+	b.hints = append(b.hints, protocol.InlayHint{
+		Position: positionForOffset(b.contents, hintOffset, b.dt.positionEncoding),
+		Label:    ": " + typeRef.String(),
+		Kind:     protocol.InlayHintType,
+		Data:     data,
+	})
+}
+
+// addParameterNameHints adds hints with the names of the parameters for each literal argument of the given
+// call expression.
+func (b *inlayHintBuilder) addParameterNameHints(node *parseNode) {
+	arguments := node.getChildren(sourceshape.NodeFunctionCallArgument)
+	hasLiteral := false
+	for _, argument := range arguments {
+		hasLiteral = hasLiteral || literalArgumentTypes[argument.nodeType]
+	}
+
+	if !hasLiteral {
+		return
+	}
+
+	childExpr, hasChildExpr := node.tryGetChild(sourceshape.NodeFunctionCallExpressionChildExpr)
+	if !hasChildExpr {
+		return
+	}
+
+	_, calleeEnd, ok := childExpr.runeRange()
+	if !ok {
+		return
+	}
+
+	lineNumber, colPosition, err := b.mapper.RunePositionToLineAndCol(calleeEnd)
+	if err != nil {
+		return
+	}
+
+	rangeInfo, err := b.handle.LookupPosition(b.source, lineNumber, colPosition)
+	if err != nil || rangeInfo.Kind != grok.NamedReference {
+		return
+	}
+
+	member, isMember := rangeInfo.NamedReference.Member()
+	if !isMember {
+		return
+	}
+
+	parameters := member.Parameters()
+	for index, argument := range arguments {
+		if index >= len(parameters) || !literalArgumentTypes[argument.nodeType] {
+			continue
+		}
+
+		parameterName, hasName := parameters[index].Name()
+		argumentStart, _, ok := argument.runeRange()
+		if !hasName || !ok || argumentStart < b.startOffset || argumentStart > b.endOffset {
+			continue
+		}
+
+		b.hints = append(b.hints, protocol.InlayHint{
+			Position:     positionForOffset(b.contents, argumentStart, b.dt.positionEncoding),
+			Label:        parameterName + ":",
+			Kind:         protocol.InlayHintParameter,
+			PaddingRight: true,
+			Data:         b.hintData(b.source, lineNumber, colPosition),
+		})
+	}
+}
+
+// hintData returns the data for a hint whose resolve shows the documentation found at the given position.
+func (b *inlayHintBuilder) hintData(source compilercommon.InputSource, lineNumber int, colPosition int) interface{} {
+	return map[string]interface{}{
+		"uri":    b.uri,
+		"path":   string(source),
+		"line":   lineNumber,
+		"column": colPosition,
+	}
+}
+
+// typeOfRange returns the type of the entity or expression found by the given range information, if any.
+func (dt *documentTracker) typeOfRange(handle grok.Handle, rangeInfo grok.RangeInformation) (typegraph.TypeReference, bool) {
+	switch rangeInfo.Kind {
+	case grok.TypeRef, grok.LocalValue:
+		return rangeInfo.TypeReference, !rangeInfo.TypeReference.IsVoid()
+
+	case grok.NamedReference:
+		// Named references found in expressions have the type of the expression.
+		if !rangeInfo.TypeReference.IsVoid() {
+			return rangeInfo.TypeReference, true
+		}
+
+		member, isMember := rangeInfo.NamedReference.Member()
+		if !isMember && len(rangeInfo.SourceRanges) > 0 {
+			// A lookup on a declaration refers to its definition in source, so find the member declared there.
+			startRune, err := rangeInfo.SourceRanges[0].Start().RunePosition()
+			if err != nil {
+				return typegraph.TypeReference{}, false
+			}
+
+			member, isMember = memberDeclaredAt(handle, rangeInfo.SourceRanges[0].Source(), startRune)
+			if !isMember {
+				return dt.declaredParameterType(handle, rangeInfo.SourceRanges[0])
+			}
+		}
+
+		if !isMember {
+			return typegraph.TypeReference{}, false
+		}
+
+		// The type of a function is that which it returns.
+		if returnType, hasReturnType := member.ReturnType(); hasReturnType {
+			return returnType, !returnType.IsVoid()
+		}
+
+		memberType := member.MemberType()
+		return memberType, !memberType.IsVoid()
+	}
+
+	return typegraph.TypeReference{}, false
+}
+
+// declaredParameterType returns the declared type of the parameter whose declaration is found at the given
+// source range, if any, by looking up its type reference in source.
+func (dt *documentTracker) declaredParameterType(handle grok.Handle, sourceRange compilercommon.SourceRange) (typegraph.TypeReference, bool) {
+	startRune, err := sourceRange.Start().RunePosition()
+	if err != nil {
+		return typegraph.TypeReference{}, false
+	}
+
+	source := sourceRange.Source()
+	rootNode, contents, err := dt.sourceParseTree(source)
+	if err != nil {
+		return typegraph.TypeReference{}, false
+	}
+
+	for _, node := range rootNode.enclosingNodes(startRune) {
+		if node.nodeType != sourceshape.NodeTypeParameter {
+			continue
+		}
+
+		typeNode, hasType := node.tryGetChild(sourceshape.NodeParameterType)
+		if !hasType {
+			return typegraph.TypeReference{}, false
+		}
+
+		typeStart, _, ok := typeNode.runeRange()
+		if !ok {
+			return typegraph.TypeReference{}, false
+		}
+
+		lineNumber, colPosition, err := compilercommon.CreateSourcePositionMapper(contents).RunePositionToLineAndCol(typeStart)
+		if err != nil {
+			return typegraph.TypeReference{}, false
+		}
+
+		rangeInfo, err := handle.LookupPosition(source, lineNumber, colPosition)
+		if err != nil || rangeInfo.Kind != grok.TypeRef {
+			return typegraph.TypeReference{}, false
+		}
+
+		return rangeInfo.TypeReference, true
+	}
+
+	return typegraph.TypeReference{}, false
+}
@@ -0,0 +1,212 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/serulian/compiler/formatter"
+	"github.com/serulian/compiler/sourceshape"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+// onTypeFormattingTriggerCharacters are the characters which, when typed, trigger on-type formatting.
+var onTypeFormattingTriggerCharacters = []string{"}", ";", "\n"}
+
+// formatDocumentOnType formats the statement or block just completed by typing the given character at the
+// given position in the document with the given URI, returning the edits to apply. Only the text of the
+// completed statement or block is formatted, so that syntax errors elsewhere in the document, which are to be
+// expected while typing, do not prevent it from being formatted.
+func (dt *documentTracker) formatDocumentOnType(uri string, position protocol.Position, ch string) []protocol.TextEdit {
+	rootNode, _, contents, err := dt.parseDocument(uri)
+	if err != nil {
+		return []protocol.TextEdit{}
+	}
+
+	completedNode, parentNode, ok := completedNodeForCharacter(rootNode, string(contents), position, ch, dt.positionEncoding)
+	if !ok {
+		return []protocol.TextEdit{}
+	}
+
+	return formatCompletedNode(completedNode, parentNode, string(contents), dt.positionEncoding)
+}
+
+// completedNodeForCharacter returns the statement or block just completed by typing the given character, with
+// the cursor now at the given position, along with its parent node. This is the outermost node ending at the
+// typed character or, for a newline, at the end of the line just ended.
+func completedNodeForCharacter(rootNode *parseNode, contents string, position protocol.Position, ch string, encoding protocol.PositionEncodingKind) (*parseNode, *parseNode, bool) {
+	var characterOffset int
+	if ch == "\n" {
+		if position.Line < 1 {
+			return nil, nil, false
+		}
+
+		lineStart, err := offsetForPosition(contents, protocol.Position{Line: position.Line - 1, Column: 0}, encoding)
+		if err != nil {
+			return nil, nil, false
+		}
+
+		lineText := contents[lineStart:]
+		if lineEnd := strings.Index(lineText, "\n"); lineEnd >= 0 {
+			lineText = lineText[0:lineEnd]
+		}
+
+		lastCharacter := strings.LastIndexFunc(lineText, func(r rune) bool { return !unicode.IsSpace(r) })
+		if lastCharacter < 0 {
+			return nil, nil, false
+		}
+
+		characterOffset = lineStart + lastCharacter
+	} else {
+		offset, err := offsetForPosition(contents, position, encoding)
+		if err != nil || offset < 1 || contents[offset-1:offset] != ch {
+			return nil, nil, false
+		}
+
+		characterOffset = offset - 1
+	}
+
+	return outermostNodeEndingAt(rootNode, characterOffset)
+}
+
+// outermostNodeEndingAt returns the outermost descendant of the given node which ends at the given byte
+// position, along with its parent node.
+func outermostNodeEndingAt(node *parseNode, position int) (*parseNode, *parseNode, bool) {
+	for _, child := range node.allChildren() {
+		if child.nodeType == sourceshape.NodeTypeComment || !child.containsPosition(position) {
+			continue
+		}
+
+		if _, endRune, _ := child.runeRange(); endRune == position {
+			return child, node, true
+		}
+
+		return outermostNodeEndingAt(child, position)
+	}
+
+	return nil, nil, false
+}
+
+// formatCompletedNode returns the edits which format the text of the given completed node, found under the
+// given parent node in the given contents.
+func formatCompletedNode(completedNode *parseNode, parentNode *parseNode, contents string, encoding protocol.PositionEncodingKind) []protocol.TextEdit {
+	startRune, endRune, ok := completedNode.runeRange()
+	if !ok || endRune >= len(contents) {
+		return []protocol.TextEdit{}
+	}
+
+	nodeText := contents[startRune : endRune+1]
+	formatted, ok := formatNodeText(nodeText, parentNode.nodeType)
+	if !ok {
+		return []protocol.TextEdit{}
+	}
+
+	// Indent the lines following the first to the indentation of the line on which the node starts.
+	lineStart := strings.LastIndex(contents[0:startRune], "\n") + 1
+	linePrefix := contents[lineStart:startRune]
+	indentation := linePrefix[0 : len(linePrefix)-len(strings.TrimLeft(linePrefix, " \t"))]
+
+	formattedLines := strings.Split(formatted, "\n")
+	for index := 1; index < len(formattedLines); index++ {
+		if formattedLines[index] != "" {
+			formattedLines[index] = indentation + formattedLines[index]
+		}
+	}
+
+	terminator := "\n"
+	if strings.Contains(nodeText, "\r\n") {
+		terminator = "\r\n"
+	}
+
+	formatted = strings.Join(formattedLines, terminator)
+	if formatted == nodeText {
+		return []protocol.TextEdit{}
+	}
+
+	// Compute the edits over the text of the node, and then move them to where the node is found in the
+	// document.
+	nodeStart := positionForOffset(contents, startRune, encoding)
+	edits := computeTextEdits(nodeText, formatted, encoding)
+	for index := range edits {
+		edits[index].Range.Start = offsetNodePosition(edits[index].Range.Start, nodeStart)
+		edits[index].Range.End = offsetNodePosition(edits[index].Range.End, nodeStart)
+	}
+
+	return edits
+}
+
+// offsetNodePosition returns the position in the document of the given position in the text of a node
+// starting at the given position.
+func offsetNodePosition(position protocol.Position, nodeStart protocol.Position) protocol.Position {
+	if position.Line == 0 {
+		return protocol.Position{Line: nodeStart.Line, Column: nodeStart.Column + position.Column}
+	}
+
+	return protocol.Position{Line: nodeStart.Line + position.Line, Column: position.Column}
+}
+
+// formatNodeText formats the given text of a node found under a node of the given type. As the formatter only
+// formats full source files, the text is formatted within a declaration in which the node can be found, such as
+// a function for statements, with the declaration then removed.
+func formatNodeText(nodeText string, parentType sourceshape.NodeType) (string, bool) {
+	var declarationStart string
+	switch parentType {
+	case sourceshape.NodeTypeFile:
+		formatted, err := formatter.FormatSource(nodeText)
+		if err != nil {
+			return "", false
+		}
+
+		return strings.TrimRight(formatted, "\n"), true
+
+	case sourceshape.NodeTypeStatementBlock:
+		declarationStart = "function formatted() {"
+
+	case sourceshape.NodeTypeClass, sourceshape.NodeTypeAgent, sourceshape.NodeTypeNominal:
+		declarationStart = "class formatted {"
+
+	case sourceshape.NodeTypeInterface:
+		declarationStart = "interface formatted {"
+
+	case sourceshape.NodeTypeStruct:
+		declarationStart = "struct formatted {"
+
+	default:
+		return "", false
+	}
+
+	formatted, err := formatter.FormatSource(declarationStart + "\n" + nodeText + "\n}\n")
+	if err != nil {
+		return "", false
+	}
+
+	// Remove the lines of the declaration, along with the indentation of the text within it.
+	lines := strings.Split(strings.TrimRight(formatted, "\n"), "\n")
+	if len(lines) < 3 {
+		return "", false
+	}
+
+	lines = lines[1 : len(lines)-1]
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[0 : len(lines)-1]
+	}
+
+	if len(lines) == 0 {
+		return "", false
+	}
+
+	indentation := lines[0][0 : len(lines[0])-len(strings.TrimLeft(lines[0], " \t"))]
+	for index, line := range lines {
+		lines[index] = strings.TrimPrefix(line, indentation)
+	}
+
+	return strings.Join(lines, "\n"), true
+}
@@ -0,0 +1,156 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/serulian/compiler/formatter"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+type onTypeFormattingTest struct {
+	name string
+
+	// formatted is the source of the statement or block completed, once formatted.
+	formatted string
+
+	// unformatted and replacement are the text found in the formatted source and the text replacing it to
+	// make it unformatted.
+	unformatted string
+	replacement string
+
+	// ch is the character typed to complete the statement or block, found at the end of the formatted source.
+	ch string
+
+	// following is the text following the statement or block in the document.
+	following string
+}
+
+var onTypeFormattingTests = []onTypeFormattingTest{
+	{"completed function", "function First() {\n\tvar x = 1\n}", "x = 1", "x   =   1", "}", "\n"},
+
+	{"completed function with syntax error elsewhere", "function First() {\n\tvar x = 1\n}", "x = 1", "x   =   1", "}",
+		"\n\nfunction Second() {\n\tvar y = \n"},
+
+	{"completed class", "class SomeClass {\n\tfunction First() {\n\t\tFirst()\n\t}\n}", "First()\n", "First(   )\n", "}",
+		"\n\nfunction Second() {\n"},
+}
+
+func TestFormatDocumentOnType(t *testing.T) {
+	for _, test := range onTypeFormattingTests {
+		formatted, err := formatter.FormatSource(test.formatted)
+		if err != nil {
+			t.Fatalf("%s: could not format source: %v", test.name, err)
+		}
+
+		formatted = strings.TrimRight(formatted, "\n")
+		if !strings.Contains(formatted, test.unformatted) {
+			t.Fatalf("%s: expected formatted source %q to contain %q", test.name, formatted, test.unformatted)
+		}
+
+		unformatted := strings.Replace(formatted, test.unformatted, test.replacement, 1)
+		contents := unformatted + test.following
+
+		ts := newTestServer(t, map[string]string{"main.seru": contents})
+		ts.open("main.seru")
+
+		edits := []protocol.TextEdit{}
+		ts.call(protocol.DocumentOnTypeFormattingRequest, protocol.DocumentOnTypeFormattingParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(ts.uri("main.seru"))},
+				Position:     positionForOffset(contents, len(unformatted), protocol.PositionEncodingUTF16),
+			},
+			Ch: test.ch,
+		}, &edits)
+		ts.close()
+
+		expected := formatted + test.following
+		if updated := applyTextEdits(t, contents, edits); updated != expected {
+			t.Errorf("%s: expected on-type formatting to produce %q, found %q", test.name, expected, updated)
+		}
+	}
+}
+
+func TestFormatDocumentOnTypeStatement(t *testing.T) {
+	formatted, err := formatter.FormatSource("function First() {\n\tif true {\n\t\tFirst()\n\t}\n}\n")
+	if err != nil {
+		t.Fatalf("Could not format source: %v", err)
+	}
+
+	// Unformat the statement in the function, as well as the function itself, and complete the statement by
+	// typing its closing brace, followed by a newline. Only the statement is expected to be formatted.
+	contents := strings.Replace(strings.Replace(formatted, "if true {", "if   true   {", 1), "First() {", "First(  ) {", 1)
+	statementEnd := strings.LastIndex(contents, "}\n}") + 1
+	expected := strings.Replace(formatted, "First() {", "First(  ) {", 1)
+
+	for _, ch := range []string{"}", "\n"} {
+		ts := newTestServer(t, map[string]string{"main.seru": contents})
+		ts.open("main.seru")
+
+		position := positionForOffset(contents, statementEnd, protocol.PositionEncodingUTF16)
+		if ch == "\n" {
+			position = protocol.Position{Line: position.Line + 1, Column: 0}
+		}
+
+		edits := []protocol.TextEdit{}
+		ts.call(protocol.DocumentOnTypeFormattingRequest, protocol.DocumentOnTypeFormattingParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(ts.uri("main.seru"))},
+				Position:     position,
+			},
+			Ch: ch,
+		}, &edits)
+		ts.close()
+
+		if updated := applyTextEdits(t, contents, edits); updated != expected {
+			t.Errorf("Expected on-type formatting for %q to produce %q, found %q", ch, expected, updated)
+		}
+	}
+}
+
+func TestFormatDocumentOnTypeWithoutCompletedNode(t *testing.T) {
+	contents := "function First() {\n\tvar x = (1 +\n}\n"
+	ts := newTestServer(t, map[string]string{"main.seru": contents})
+	defer ts.close()
+	ts.open("main.seru")
+
+	edits := []protocol.TextEdit{}
+	ts.call(protocol.DocumentOnTypeFormattingRequest, protocol.DocumentOnTypeFormattingParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(ts.uri("main.seru"))},
+			Position:     protocol.Position{Line: 2, Column: 0},
+		},
+		Ch: "\n",
+	}, &edits)
+
+	if len(edits) != 0 {
+		t.Errorf("Expected no edits for an incomplete statement, found %v", edits)
+	}
+}
+
+type offsetNodePositionTest struct {
+	name      string
+	position  protocol.Position
+	nodeStart protocol.Position
+	expected  protocol.Position
+}
+
+var offsetNodePositionTests = []offsetNodePositionTest{
+	{"start of node", protocol.Position{Line: 0, Column: 0}, protocol.Position{Line: 3, Column: 4}, protocol.Position{Line: 3, Column: 4}},
+	{"first line of node", protocol.Position{Line: 0, Column: 5}, protocol.Position{Line: 3, Column: 4}, protocol.Position{Line: 3, Column: 9}},
+	{"later line of node", protocol.Position{Line: 2, Column: 5}, protocol.Position{Line: 3, Column: 4}, protocol.Position{Line: 5, Column: 5}},
+}
+
+func TestOffsetNodePosition(t *testing.T) {
+	for _, test := range offsetNodePositionTests {
+		position := offsetNodePosition(test.position, test.nodeStart)
+		if position != test.expected {
+			t.Errorf("%s: expected position %v, found %v", test.name, test.expected, position)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/serulian/compiler/compilercommon"
+	"github.com/serulian/compiler/parser"
+	"github.com/serulian/compiler/parser/shared"
+	"github.com/serulian/compiler/sourceshape"
+)
+
+// parseNode defines a fully in-memory node for parser output. We don't use the SRG here as
+// it requires building the full graph, which is not needed for source-level features such as
+// highlighting and folding.
+type parseNode struct {
+	nodeType   sourceshape.NodeType
+	properties map[string]string
+	children   map[string][]*parseNode
+}
+
+// parseSource parses the given Serulian source code and returns the root node of its parse tree.
+// Note that parsing always produces a tree; any syntax errors are represented as error nodes.
+func parseSource(source compilercommon.InputSource, contents string) *parseNode {
+	return parser.Parse(newParseNode, nil, source, contents).(*parseNode)
+}
+
+// parsedDocument holds the parse tree of a tracked document at a specific version.
+type parsedDocument struct {
+	// version is the version of the document parsed.
+	version int
+
+	// rootNode is the root node of the parse tree.
+	rootNode *parseNode
+}
+
+// parseDocument parses the contents of the document with the given URI. If the document is being tracked, its
+// parse tree is shared with all other calls made at the same version of the document.
+func (dt *documentTracker) parseDocument(uri string) (*parseNode, compilercommon.InputSource, []byte, error) {
+	path, err := dt.uriToPath(uri)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	source := compilercommon.InputSource(path)
+	rootNode, contents, err := dt.sourceParseTree(source)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rootNode, source, contents, nil
+}
+
+// sourceParseTree returns the parse tree and the contents of the given source. If the source is a tracked
+// document, its parse tree is shared with all other calls made at the same version of the document.
+func (dt *documentTracker) sourceParseTree(source compilercommon.InputSource) (*parseNode, []byte, error) {
+	if currentValue, exists := dt.documents.Get(string(source)); exists {
+		current := currentValue.(document)
+		return dt.documentParseTree(current), []byte(current.contents), nil
+	}
+
+	contents, err := dt.LoadSourceFile(string(source))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return parseSource(source, string(contents)), contents, nil
+}
+
+// documentParseTree returns the parse tree of the given tracked document, parsing it only if it was not already
+// parsed at its current version. Parse trees are never modified once built, so they can be shared by the
+// features working over the parse tree, such as folding, highlighting and selection ranges.
+func (dt *documentTracker) documentParseTree(current document) *parseNode {
+	if cachedValue, exists := dt.parsedDocuments.Get(current.path); exists {
+		if cached := cachedValue.(parsedDocument); cached.version == current.version {
+			return cached.rootNode
+		}
+	}
+
+	rootNode := parseSource(compilercommon.InputSource(current.path), current.contents)
+	dt.parsedDocuments.Set(current.path, parsedDocument{current.version, rootNode})
+	return rootNode
+}
+
+func newParseNode(source compilercommon.InputSource, kind sourceshape.NodeType) shared.AstNode {
+	return &parseNode{
+		nodeType:   kind,
+		properties: map[string]string{},
+		children:   map[string][]*parseNode{},
+	}
+}
+
+func (pn *parseNode) Connect(predicate string, other shared.AstNode) shared.AstNode {
+	pn.children[predicate] = append(pn.children[predicate], other.(*parseNode))
+	return pn
+}
+
+func (pn *parseNode) Decorate(property string, value string) shared.AstNode {
+	pn.properties[property] = value
+	return pn
+}
+
+func (pn *parseNode) DecorateWithInt(property string, value int) shared.AstNode {
+	return pn.Decorate(property, strconv.Itoa(value))
+}
+
+// getProperty returns the value of the given property on the node, if any.
+func (pn *parseNode) getProperty(name string) (string, bool) {
+	value, ok := pn.properties[name]
+	return value, ok
+}
+
+// intProperty returns the integer value of the given property on the node, if any.
+func (pn *parseNode) intProperty(name string) (int, bool) {
+	value, ok := pn.properties[name]
+	if !ok {
+		return 0, false
+	}
+
+	intValue, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+
+	return intValue, true
+}
+
+// runeRange returns the start and end (inclusive) byte positions of the node in source, if any.
+func (pn *parseNode) runeRange() (int, int, bool) {
+	startRune, hasStart := pn.intProperty(sourceshape.NodePredicateStartRune)
+	endRune, hasEnd := pn.intProperty(sourceshape.NodePredicateEndRune)
+	return startRune, endRune, hasStart && hasEnd
+}
+
+// containsPosition returns true if the node's range contains the given byte position.
+func (pn *parseNode) containsPosition(position int) bool {
+	startRune, endRune, ok := pn.runeRange()
+	return ok && startRune <= position && position <= endRune
+}
+
+// getChildren returns the children of the node under the given predicate(s).
+func (pn *parseNode) getChildren(predicates ...string) []*parseNode {
+	children := []*parseNode{}
+	for _, predicate := range predicates {
+		children = append(children, pn.children[predicate]...)
+	}
+	return children
+}
+
+// tryGetChild returns the first child of the node under the given predicate, if any.
+func (pn *parseNode) tryGetChild(predicate string) (*parseNode, bool) {
+	children := pn.children[predicate]
+	if len(children) == 0 {
+		return nil, false
+	}
+
+	return children[0], true
+}
+
+// allChildren returns all children of the node, ordered by their position in source.
+func (pn *parseNode) allChildren() []*parseNode {
+	children := []*parseNode{}
+	for _, childList := range pn.children {
+		children = append(children, childList...)
+	}
+
+	sort.SliceStable(children, func(i, j int) bool {
+		first, _ := children[i].intProperty(sourceshape.NodePredicateStartRune)
+		second, _ := children[j].intProperty(sourceshape.NodePredicateStartRune)
+		return first < second
+	})
+	return children
+}
+
+// enclosingNodes returns the chain of nodes enclosing the given byte position, from this node down
+// to the innermost node. A position just past the end of a node, such as the cursor following an
+// identifier, is considered enclosed by that node if no other child contains the position. Comments
+// are not included.
+func (pn *parseNode) enclosingNodes(position int) []*parseNode {
+	if !pn.containsPosition(position) && !pn.containsPosition(position-1) {
+		return []*parseNode{}
+	}
+
+	chain := []*parseNode{pn}
+	current := pn
+	for {
+		var next *parseNode
+		for _, child := range current.allChildren() {
+			if child.nodeType == sourceshape.NodeTypeComment {
+				continue
+			}
+
+			if child.containsPosition(position) {
+				next = child
+				break
+			}
+
+			if next == nil && child.containsPosition(position-1) {
+				next = child
+			}
+		}
+
+		if next == nil {
+			return chain
+		}
+
+		chain = append(chain, next)
+		current = next
+	}
+}
+
+// walk invokes the given function for this node and all of its descendants, in source order. If
+// the function returns false, the descendants of the node are skipped.
+func (pn *parseNode) walk(visitor func(node *parseNode) bool) {
+	if !visitor(pn) {
+		return
+	}
+
+	for _, child := range pn.allChildren() {
+		child.walk(visitor)
+	}
+}
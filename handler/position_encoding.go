@@ -0,0 +1,185 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/serulian/compiler/compilercommon"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+// The compiler reports and accepts column positions as byte (UTF-8 code unit) offsets into a line,
+// while the client counts columns in the position encoding negotiated at initialization (UTF-16 by
+// default). The functions below convert between the two.
+
+// negotiatePositionEncoding returns the position encoding to use, given the encodings supported by the
+// client. UTF-8 is preferred, as it matches the compiler's positions and requires no conversion.
+func negotiatePositionEncoding(capabilities protocol.ClientCapabilities) protocol.PositionEncodingKind {
+	if capabilities.General == nil {
+		return protocol.PositionEncodingUTF16
+	}
+
+	preferred := []protocol.PositionEncodingKind{protocol.PositionEncodingUTF8, protocol.PositionEncodingUTF32}
+	for _, encoding := range preferred {
+		for _, supported := range capabilities.General.PositionEncodings {
+			if encoding == supported {
+				return encoding
+			}
+		}
+	}
+
+	return protocol.PositionEncodingUTF16
+}
+
+// encodedLength returns the number of code units in the given encoding needed to represent the rune.
+func encodedLength(r rune, encoding protocol.PositionEncodingKind) int {
+	switch encoding {
+	case protocol.PositionEncodingUTF8:
+		return utf8.RuneLen(r)
+
+	case protocol.PositionEncodingUTF32:
+		return 1
+
+	default:
+		if r >= 0x10000 {
+			return 2
+		}
+		return 1
+	}
+}
+
+// encodedColumn converts the given byte column in the line text into a column in the given encoding.
+func encodedColumn(lineText string, colPosition int, encoding protocol.PositionEncodingKind) int {
+	if encoding == protocol.PositionEncodingUTF8 {
+		return colPosition
+	}
+
+	if colPosition > len(lineText) {
+		colPosition = len(lineText)
+	}
+
+	column := 0
+	for _, r := range lineText[0:colPosition] {
+		column += encodedLength(r, encoding)
+	}
+	return column
+}
+
+// byteColumn converts the given column in the given encoding into a byte column in the line text. A column
+// past the end of the line refers to the end of the line, while a column found in the middle of a
+// character refers to the start of that character.
+func byteColumn(lineText string, colPosition int, encoding protocol.PositionEncodingKind) int {
+	if encoding == protocol.PositionEncodingUTF8 {
+		if colPosition > len(lineText) {
+			return len(lineText)
+		}
+		return colPosition
+	}
+
+	column := 0
+	for index, r := range lineText {
+		column += encodedLength(r, encoding)
+		if column > colPosition {
+			return index
+		}
+	}
+	return len(lineText)
+}
+
+// sourceLines holds the lines of a source at a specific revision.
+type sourceLines struct {
+	// revision is the revision of the source split into lines.
+	revision sourceRevision
+
+	// lines are the lines of the source, without their line terminators.
+	lines []string
+}
+
+// newSourceLines splits the given contents of a source at the given revision into lines.
+func newSourceLines(revision sourceRevision, contents string) sourceLines {
+	lines := strings.Split(contents, "\n")
+	for index, line := range lines {
+		lines[index] = strings.TrimSuffix(line, "\r")
+	}
+
+	return sourceLines{revision, lines}
+}
+
+// getSourceLines returns the lines of the given source at its current revision, splitting its contents only if
+// they were not already split at that revision.
+func (dt *documentTracker) getSourceLines(source compilercommon.InputSource) (sourceLines, error) {
+	path := string(source)
+	revision, current, tracked, err := dt.currentRevision(path)
+	if err != nil {
+		return sourceLines{}, err
+	}
+
+	if cachedValue, exists := dt.sourceLines.Get(path); exists {
+		if cached := cachedValue.(sourceLines); cached.revision == revision {
+			return cached, nil
+		}
+	}
+
+	contents := current.contents
+	if !tracked {
+		localContents, err := dt.localPathLoader.LoadSourceFile(path)
+		if err != nil {
+			return sourceLines{}, err
+		}
+		contents = string(localContents)
+	}
+
+	lines := newSourceLines(revision, contents)
+	dt.sourceLines.Set(path, lines)
+	return lines, nil
+}
+
+// sourceLineText returns the text of the given line in the given source, without its line terminator.
+func (dt *documentTracker) sourceLineText(source compilercommon.InputSource, lineNumber int) (string, error) {
+	lines, err := dt.getSourceLines(source)
+	if err != nil {
+		return "", err
+	}
+
+	if lineNumber < 0 || lineNumber >= len(lines.lines) {
+		return "", fmt.Errorf("Invalid line %v for path %s", lineNumber, source)
+	}
+
+	return lines.lines[lineNumber], nil
+}
+
+// documentPosition converts the given line and byte column in the given source into a document position,
+// in the negotiated position encoding.
+func (dt *documentTracker) documentPosition(source compilercommon.InputSource, lineNumber int, colPosition int) (protocol.Position, error) {
+	if dt.positionEncoding == protocol.PositionEncodingUTF8 {
+		return protocol.Position{Line: lineNumber, Column: colPosition}, nil
+	}
+
+	lineText, err := dt.sourceLineText(source, lineNumber)
+	if err != nil {
+		return protocol.Position{}, err
+	}
+
+	return protocol.Position{Line: lineNumber, Column: encodedColumn(lineText, colPosition, dt.positionEncoding)}, nil
+}
+
+// compilerColumn converts the column of the given document position in the given source, in the negotiated
+// position encoding, into the byte column used by the compiler.
+func (dt *documentTracker) compilerColumn(source compilercommon.InputSource, position protocol.Position) (int, error) {
+	if dt.positionEncoding == protocol.PositionEncodingUTF8 {
+		return position.Column, nil
+	}
+
+	lineText, err := dt.sourceLineText(source, position.Line)
+	if err != nil {
+		return 0, err
+	}
+
+	return byteColumn(lineText, position.Column, dt.positionEncoding), nil
+}
@@ -0,0 +1,164 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/serulian/compiler/compilercommon"
+
+	"github.com/serulian/serulian-langserver/protocol"
+
+	cmap "github.com/streamrail/concurrent-map"
+)
+
+type columnTest struct {
+	name          string
+	lineText      string
+	byteColumn    int
+	encodedColumn int
+	encoding      protocol.PositionEncodingKind
+}
+
+var columnTests = []columnTest{
+	{"ascii in utf-16", "var a = 1", 4, 4, protocol.PositionEncodingUTF16},
+	{"ascii in utf-32", "var a = 1", 4, 4, protocol.PositionEncodingUTF32},
+	{"ascii in utf-8", "var a = 1", 4, 4, protocol.PositionEncodingUTF8},
+
+	{"start of line", "é😀x", 0, 0, protocol.PositionEncodingUTF16},
+	{"after two byte character in utf-16", "é😀x", 2, 1, protocol.PositionEncodingUTF16},
+	{"after surrogate pair in utf-16", "é😀x", 6, 3, protocol.PositionEncodingUTF16},
+	{"end of line in utf-16", "é😀x", 7, 4, protocol.PositionEncodingUTF16},
+
+	{"after two byte character in utf-32", "é😀x", 2, 1, protocol.PositionEncodingUTF32},
+	{"after four byte character in utf-32", "é😀x", 6, 2, protocol.PositionEncodingUTF32},
+	{"end of line in utf-32", "é😀x", 7, 3, protocol.PositionEncodingUTF32},
+
+	{"after four byte character in utf-8", "é😀x", 6, 6, protocol.PositionEncodingUTF8},
+}
+
+func TestEncodedColumn(t *testing.T) {
+	for _, test := range columnTests {
+		column := encodedColumn(test.lineText, test.byteColumn, test.encoding)
+		if column != test.encodedColumn {
+			t.Errorf("%s: expected encoded column %v, found %v", test.name, test.encodedColumn, column)
+		}
+	}
+}
+
+func TestByteColumn(t *testing.T) {
+	for _, test := range columnTests {
+		column := byteColumn(test.lineText, test.encodedColumn, test.encoding)
+		if column != test.byteColumn {
+			t.Errorf("%s: expected byte column %v, found %v", test.name, test.byteColumn, column)
+		}
+	}
+}
+
+type clampedColumnTest struct {
+	name     string
+	lineText string
+	column   int
+	expected int
+	encoding protocol.PositionEncodingKind
+}
+
+var byteColumnClampTests = []clampedColumnTest{
+	{"past end of line in utf-16", "ab", 10, 2, protocol.PositionEncodingUTF16},
+	{"past end of line in utf-32", "ab", 10, 2, protocol.PositionEncodingUTF32},
+	{"past end of line in utf-8", "ab", 10, 2, protocol.PositionEncodingUTF8},
+	{"middle of surrogate pair", "a😀b", 2, 1, protocol.PositionEncodingUTF16},
+}
+
+func TestByteColumnClamping(t *testing.T) {
+	for _, test := range byteColumnClampTests {
+		column := byteColumn(test.lineText, test.column, test.encoding)
+		if column != test.expected {
+			t.Errorf("%s: expected byte column %v, found %v", test.name, test.expected, column)
+		}
+	}
+}
+
+func TestEncodedColumnPastEnd(t *testing.T) {
+	if column := encodedColumn("a😀", 100, protocol.PositionEncodingUTF16); column != 3 {
+		t.Errorf("Expected encoded column 3, found %v", column)
+	}
+}
+
+type sourceLinesTest struct {
+	name     string
+	contents string
+	expected []string
+}
+
+var sourceLinesTests = []sourceLinesTest{
+	{"empty", "", []string{""}},
+	{"single line", "var a = 1", []string{"var a = 1"}},
+	{"trailing newline", "var a = 1\n", []string{"var a = 1", ""}},
+	{"lf", "a\nb\nc", []string{"a", "b", "c"}},
+	{"crlf", "a\r\nb\r\nc", []string{"a", "b", "c"}},
+	{"mixed", "a\r\nb\nc\r\n", []string{"a", "b", "c", ""}},
+}
+
+func TestNewSourceLines(t *testing.T) {
+	for _, test := range sourceLinesTests {
+		lines := newSourceLines(sourceRevision{true, 42}, test.contents)
+		if lines.revision != (sourceRevision{true, 42}) {
+			t.Errorf("%s: expected revision 42, found %v", test.name, lines.revision)
+		}
+
+		if !reflect.DeepEqual(lines.lines, test.expected) {
+			t.Errorf("%s: expected lines %q, found %q", test.name, test.expected, lines.lines)
+		}
+	}
+}
+
+func TestSourceLinesReusedPerVersion(t *testing.T) {
+	dt := newDocumentTracker([]string{})
+	source := compilercommon.InputSource("/tmp/main.seru")
+	setContents := func(contents string, version int) {
+		dt.documents.Set(string(source), document{
+			path:                 string(source),
+			contents:             contents,
+			version:              version,
+			codeContextOrActions: cmap.New(),
+		})
+	}
+
+	setContents("var a = 1\r\nvar b = '😀c'\n", 1)
+	first, err := dt.getSourceLines(source)
+	if err != nil {
+		t.Fatalf("Could not get source lines: %v", err)
+	}
+
+	position, err := dt.documentPosition(source, 1, 13)
+	if err != nil {
+		t.Fatalf("Could not convert position: %v", err)
+	}
+
+	if position != (protocol.Position{Line: 1, Column: 11}) {
+		t.Errorf("Expected position 1:11, found %v", position)
+	}
+
+	second, err := dt.getSourceLines(source)
+	if err != nil {
+		t.Fatalf("Could not get source lines: %v", err)
+	}
+
+	if &first.lines[0] != &second.lines[0] {
+		t.Errorf("Expected the lines to be reused at the same version")
+	}
+
+	setContents("var c = 2\n", 2)
+	lineText, err := dt.sourceLineText(source, 0)
+	if err != nil {
+		t.Fatalf("Could not get line text: %v", err)
+	}
+
+	if lineText != "var c = 2" {
+		t.Errorf("Expected the lines to be split again at a new version, found line %q", lineText)
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/serulian/compiler/builder"
+	"github.com/serulian/compiler/grok"
+	"github.com/serulian/compiler/packageloader"
+
+	"github.com/serulian/serulian-langserver/protocol"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// ProjectConfigFileName is the name of the project configuration file, found at the root of a workspace folder.
+const ProjectConfigFileName = "serulian-langserver.json"
+
+// projectConfig defines the contents of a project configuration file, which holds the settings of a workspace
+// folder checked in alongside its sources. Its settings layer over the defaults specified on the command line,
+// and under those specified by the client.
+type projectConfig struct {
+	// Entrypoints are the paths of the entrypoint source files or directories of the folder, relative to its
+	// root. If empty, the root of the folder is used.
+	Entrypoints []string `json:"entrypoints,omitempty"`
+
+	// Libraries are the libraries loaded in addition to the core library.
+	Libraries []projectLibrary `json:"libraries,omitempty"`
+
+	// Include are the glob patterns of the paths, relative to the root of the folder, of the sources that are
+	// part of the workspace. If empty, all sources are included.
+	Include []string `json:"include,omitempty"`
+
+	// Exclude are the glob patterns of the paths, relative to the root of the folder, of the sources that are
+	// not part of the workspace.
+	Exclude []string `json:"exclude,omitempty"`
+
+	// Settings are the settings of the server, in the same form as those found under the SettingsSection of
+	// the client's configuration. These include the formatting preferences (`formatOnSave` and
+	// `features.onTypeFormatting`) and the severity of the warnings produced by the compiler (`lint.warnings`).
+	protocol.Settings
+}
+
+// projectLibrary defines a library loaded for a workspace folder.
+type projectLibrary struct {
+	// Location is the path, relative to the root of the folder, or the VCS URL of the library's package.
+	Location string `json:"location"`
+
+	// IsVCS indicates whether the location is a VCS URL.
+	IsVCS bool `json:"isVCS,omitempty"`
+
+	// Kind is the kind of the library: empty for Serulian and `webidl` for WebIDL.
+	Kind string `json:"kind,omitempty"`
+
+	// Alias is the import alias of the library, if any.
+	Alias string `json:"alias,omitempty"`
+}
+
+// configPath returns the path of the project configuration file of the folder.
+func (wf workspaceFolder) configPath() string {
+	return path.Join(wf.rootDirectory, ProjectConfigFileName)
+}
+
+// resolvePath returns the given path, resolved against the root directory of the folder if relative.
+func (wf workspaceFolder) resolvePath(filePath string) string {
+	if path.IsAbs(filePath) {
+		return path.Clean(filePath)
+	}
+
+	return path.Join(wf.rootDirectory, filePath)
+}
+
+// entrypointPaths returns the paths of the entrypoints of the folder.
+func (wf workspaceFolder) entrypointPaths() []string {
+	if len(wf.config.Entrypoints) == 0 {
+		return []string{wf.rootPath}
+	}
+
+	entrypointPaths := make([]string, 0, len(wf.config.Entrypoints))
+	for _, entrypoint := range wf.config.Entrypoints {
+		entrypointPaths = append(entrypointPaths, wf.resolvePath(entrypoint))
+	}
+	return entrypointPaths
+}
+
+// libraries returns the libraries to load for the folder, which always include the core library.
+func (wf workspaceFolder) libraries() []packageloader.Library {
+	libraries := []packageloader.Library{builder.CORE_LIBRARY}
+	for _, library := range wf.config.Libraries {
+		location := library.Location
+		if !library.IsVCS {
+			location = wf.resolvePath(location)
+		}
+
+		libraries = append(libraries, packageloader.Library{
+			PathOrURL: location,
+			IsSCM:     library.IsVCS,
+			Kind:      library.Kind,
+			Alias:     library.Alias,
+		})
+	}
+	return libraries
+}
+
+// includes returns true if the given path, found in the folder, is included by its project configuration.
+func (wf workspaceFolder) includes(filePath string) bool {
+	relativePath := strings.TrimPrefix(filePath, wf.rootDirectory+"/")
+	if len(wf.config.Include) > 0 && !matchesAnyPattern(wf.config.Include, relativePath) {
+		return false
+	}
+
+	return !matchesAnyPattern(wf.config.Exclude, relativePath)
+}
+
+// matchesAnyPattern returns true if the given relative path, or any of the directories containing it, matches
+// any of the given glob patterns.
+func matchesAnyPattern(patterns []string, relativePath string) bool {
+	for _, pattern := range patterns {
+		for current := relativePath; current != "." && current != "/"; current = path.Dir(current) {
+			if matched, _ := path.Match(pattern, current); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// getPackageLibraries returns the libraries to load when creating a Groker with the given entrypoint path, as
+// configured for the workspace folder containing it.
+func (dt *documentTracker) getPackageLibraries(entrypointPath string) []packageloader.Library {
+	if folder, found := dt.workspaceFolderFor(entrypointPath); found {
+		return folder.libraries()
+	}
+
+	return []packageloader.Library{builder.CORE_LIBRARY}
+}
+
+// loadProjectConfig loads the project configuration file of the given workspace folder, returning an empty
+// configuration if there is none. If the file is invalid, the current configuration of the folder is returned
+// along with false, and the errors found are returned as diagnostics on the file. Unknown fields are considered
+// errors, so that misspelled settings are reported rather than silently ignored.
+func (dt *documentTracker) loadProjectConfig(folder workspaceFolder) (projectConfig, []protocol.Diagnostic, bool) {
+	configPath := folder.configPath()
+	contents, err := ioutil.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return projectConfig{}, []protocol.Diagnostic{}, true
+	}
+
+	if err != nil {
+		log.Printf("Could not read project configuration file %s: %v", configPath, err)
+		return folder.config, folder.configIssues, false
+	}
+
+	config := projectConfig{}
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.DisallowUnknownFields()
+
+	err = decoder.Decode(&config)
+	if err != nil {
+		log.Printf("Invalid project configuration file %s: %v", configPath, err)
+
+		issue := protocol.Diagnostic{
+			Severity: protocol.DiagnosticError,
+			Message:  fmt.Sprintf("Invalid project configuration: %v", err),
+			Range:    dt.offsetRange(string(contents), projectConfigErrorOffset(string(contents), err)),
+		}
+
+		return folder.config, []protocol.Diagnostic{issue}, false
+	}
+
+	log.Printf("Loaded project configuration file %s", configPath)
+	return config, []protocol.Diagnostic{}, true
+}
+
+// reportProjectConfigIssues publishes the issues found in the project configuration file of the given workspace
+// folder when last loaded, clearing those previously published if there are none.
+func (dt *documentTracker) reportProjectConfigIssues(ctx context.Context, conn *jsonrpc2.Conn, folder workspaceFolder) {
+	configPath := folder.configPath()
+	if len(folder.configIssues) == 0 {
+		dt.clearDiagnostics(ctx, conn, configPath, true)
+		return
+	}
+
+	err := dt.publishDiagnostics(ctx, conn, configPath, folder.configIssues, true)
+	if err != nil {
+		log.Printf("Could not report errors for project configuration file %s: %v", configPath, err)
+	}
+}
+
+// unknownFieldErrorPrefix is the prefix of the message of the error returned when decoding an unknown field.
+const unknownFieldErrorPrefix = "json: unknown field "
+
+// projectConfigErrorOffset returns the byte offset in the given contents of the project configuration file at
+// which the given error occurred, or zero if unknown.
+func projectConfigErrorOffset(contents string, err error) int {
+	switch typedErr := err.(type) {
+	case *json.SyntaxError:
+		return int(typedErr.Offset)
+
+	case *json.UnmarshalTypeError:
+		return int(typedErr.Offset)
+
+	default:
+		// Unknown fields are reported with their (quoted) name only, so find its first occurrence.
+		if !strings.HasPrefix(err.Error(), unknownFieldErrorPrefix) {
+			return 0
+		}
+
+		offset := strings.Index(contents, strings.TrimPrefix(err.Error(), unknownFieldErrorPrefix))
+		if offset < 0 {
+			return 0
+		}
+		return offset
+	}
+}
+
+// offsetRange returns the empty range found at the given byte offset in the given contents.
+func (dt *documentTracker) offsetRange(contents string, offset int) protocol.Range {
+	if offset > len(contents) {
+		offset = len(contents)
+	}
+
+	lineNumber := strings.Count(contents[0:offset], "\n")
+	lineStart := strings.LastIndex(contents[0:offset], "\n") + 1
+	lineEnd := strings.Index(contents[lineStart:], "\n")
+	if lineEnd < 0 {
+		lineEnd = len(contents) - lineStart
+	}
+
+	lineText := contents[lineStart : lineStart+lineEnd]
+	position := protocol.Position{Line: lineNumber, Column: encodedColumn(lineText, offset-lineStart, dt.positionEncoding)}
+	return protocol.Range{Start: position, End: position}
+}
+
+// projectConfigFolder returns the workspace folder whose project configuration file is found at the given path,
+// if any.
+func (dt *documentTracker) projectConfigFolder(filePath string) (workspaceFolder, bool) {
+	if path.Base(filePath) != ProjectConfigFileName {
+		return workspaceFolder{}, false
+	}
+
+	for _, folder := range dt.getWorkspaceFolders() {
+		if folder.configPath() == filePath {
+			return folder, true
+		}
+	}
+
+	return workspaceFolder{}, false
+}
+
+// projectConfigChanged reloads the project configuration file of the given workspace folder. If it is valid,
+// its settings are applied and the Grokers of the folder and its documents are replaced, so that they are
+// configured with its entrypoints, libraries and settings. Otherwise, the last valid configuration remains in use.
+func (dt *documentTracker) projectConfigChanged(ctx context.Context, conn *jsonrpc2.Conn, folder workspaceFolder) {
+	config, issues, ok := dt.loadProjectConfig(folder)
+	folder.configIssues = issues
+	dt.reportProjectConfigIssues(ctx, conn, folder)
+	if !ok {
+		dt.workspaceFolders.Set(folder.rootPath, folder)
+		return
+	}
+
+	// Clear the diagnostics of any documents no longer included in the workspace.
+	previousDocuments := dt.workspaceFolderDocuments(folder)
+
+	folder.config = config
+	folder.settings = dt.folderSettings(config)
+	affected := dt.folderGrokers(folder)
+	dt.workspaceFolders.Set(folder.rootPath, folder)
+
+	included := map[string]bool{}
+	for _, documentPath := range dt.workspaceFolderDocuments(folder) {
+		included[documentPath] = true
+	}
+
+	for _, documentPath := range previousDocuments {
+		if !included[documentPath] {
+			dt.clearDiagnostics(ctx, conn, documentPath, true)
+		}
+	}
+
+	dt.replaceGrokers(ctx, conn, func(groker *grok.Groker) bool { return affected[groker] })
+}
@@ -0,0 +1,149 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+type matchesAnyPatternTest struct {
+	name         string
+	patterns     []string
+	relativePath string
+	expected     bool
+}
+
+var matchesAnyPatternTests = []matchesAnyPatternTest{
+	{"no patterns", []string{}, "src/main.seru", false},
+	{"matching file", []string{"*.seru"}, "main.seru", true},
+	{"non-matching file", []string{"*.seru"}, "main.go", false},
+	{"matching directory", []string{"vendor"}, "vendor/lib/lib.seru", true},
+	{"matching nested directory", []string{"src/generated"}, "src/generated/types.seru", true},
+	{"pattern does not span directories", []string{"*.seru"}, "src/main.seru", false},
+	{"matching directory glob", []string{"test*"}, "tests/main.seru", true},
+	{"second pattern matches", []string{"vendor", "*.seru"}, "main.seru", true},
+	{"partial directory name", []string{"vend"}, "vendor/lib.seru", false},
+}
+
+func TestMatchesAnyPattern(t *testing.T) {
+	for _, test := range matchesAnyPatternTests {
+		matched := matchesAnyPattern(test.patterns, test.relativePath)
+		if matched != test.expected {
+			t.Errorf("%s: expected %v, found %v", test.name, test.expected, matched)
+		}
+	}
+}
+
+type projectConfigErrorOffsetTest struct {
+	name     string
+	contents string
+	expected int
+}
+
+var projectConfigErrorOffsetTests = []projectConfigErrorOffsetTest{
+	{"syntax error", "{\n  \"entrypoints\": [\"main.seru\",]\n}", 33},
+	{"type error", "{\n  \"buildTimeout\": \"fast\"\n}", 26},
+	{"unknown field", "{\n  \"entrypoints\": [],\n  \"bildTimeout\": 100\n}", 25},
+	{"unknown field in nested object", "{\n  \"features\": {\"codeLenses\": true}\n}", 17},
+}
+
+func TestProjectConfigErrorOffset(t *testing.T) {
+	for _, test := range projectConfigErrorOffsetTests {
+		decoder := json.NewDecoder(strings.NewReader(test.contents))
+		decoder.DisallowUnknownFields()
+
+		config := projectConfig{}
+		err := decoder.Decode(&config)
+		if err == nil {
+			t.Errorf("%s: expected error", test.name)
+			continue
+		}
+
+		offset := projectConfigErrorOffset(test.contents, err)
+		if offset != test.expected {
+			t.Errorf("%s: expected offset %v, found %v for error %v", test.name, test.expected, offset, err)
+		}
+	}
+}
+
+func TestProjectConfigErrorOffsetUnknownError(t *testing.T) {
+	if offset := projectConfigErrorOffset("{}", errors.New("some error")); offset != 0 {
+		t.Errorf("Expected offset 0 for unknown error, found %v", offset)
+	}
+
+	if offset := projectConfigErrorOffset("{}", errors.New(unknownFieldErrorPrefix+"\"missing\"")); offset != 0 {
+		t.Errorf("Expected offset 0 for unknown field not found in contents, found %v", offset)
+	}
+}
+
+// changeProjectConfig writes the given contents to the project configuration file of the test server's workspace
+// and notifies the server of the change, waiting for it to be handled.
+func changeProjectConfig(ts *testServer, contents string) {
+	ts.writeFile(ProjectConfigFileName, contents)
+	ts.notify(protocol.DidChangeWatchedFilesNotification, protocol.DidChangeWatchedFilesParams{
+		Changes: []protocol.FileEvent{
+			{URI: protocol.DocumentURI(ts.uri(ProjectConfigFileName)), Type: protocol.FileChanged},
+		},
+	})
+	ts.sync()
+}
+
+func TestProjectConfigChangedReloadsSettings(t *testing.T) {
+	ts := newTestServer(t, map[string]string{
+		ProjectConfigFileName: `{"formatOnSave": "always", "lint": {"warnings": "off"}}`,
+		"main.seru":           "function First() {}\n",
+	})
+	defer ts.close()
+
+	settings := ts.handler.documentTracker.settingsFor(ts.path("main.seru"))
+	if settings.formatOnSave != protocol.FormatOnSaveAlways || settings.warningSeverity != protocol.LintSeverityOff {
+		t.Fatalf("Expected the initial project configuration to be applied, found %+v", settings)
+	}
+
+	changeProjectConfig(ts, `{"lint": {"warnings": "error"}}`)
+
+	settings = ts.handler.documentTracker.settingsFor(ts.path("main.seru"))
+	if settings.formatOnSave != protocol.FormatOnSaveManual || settings.warningSeverity != protocol.LintSeverityError {
+		t.Errorf("Expected the reloaded project configuration to be applied, found %+v", settings)
+	}
+}
+
+func TestProjectConfigChangedReportsErrors(t *testing.T) {
+	ts := newTestServer(t, map[string]string{
+		ProjectConfigFileName: `{"formatOnSave": "always"}`,
+		"main.seru":           "function First() {}\n",
+	})
+	defer ts.close()
+
+	configURI := ts.uri(ProjectConfigFileName)
+	changeProjectConfig(ts, "{\n  \"formatOnSav\": \"off\"\n}")
+
+	diagnostics := ts.waitForDiagnostics(configURI, func(diagnostics []protocol.Diagnostic, published bool) bool {
+		return len(diagnostics) > 0
+	})
+
+	if !strings.Contains(diagnostics[0].Message, "formatOnSav") || diagnostics[0].Range.Start.Line != 1 {
+		t.Errorf("Expected an unknown field error on line 1, found %v", diagnostics)
+	}
+
+	// The last valid configuration remains in use until the file is fixed.
+	if settings := ts.handler.documentTracker.settingsFor(ts.path("main.seru")); settings.formatOnSave != protocol.FormatOnSaveAlways {
+		t.Errorf("Expected the last valid project configuration to remain applied, found %+v", settings)
+	}
+
+	changeProjectConfig(ts, `{"formatOnSave": "off"}`)
+	ts.waitForDiagnostics(configURI, func(diagnostics []protocol.Diagnostic, published bool) bool {
+		return published && len(diagnostics) == 0
+	})
+
+	if settings := ts.handler.documentTracker.settingsFor(ts.path("main.seru")); settings.formatOnSave != protocol.FormatOnSaveOff {
+		t.Errorf("Expected the fixed project configuration to be applied, found %+v", settings)
+	}
+}
@@ -0,0 +1,386 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/serulian/compiler/compilercommon"
+	"github.com/serulian/compiler/grok"
+	"github.com/serulian/compiler/parser/shared"
+	"github.com/serulian/compiler/sourceshape"
+)
+
+// webIDLImportKind defines the kind of import used for WebIDL files.
+const webIDLImportKind = "webidl"
+
+// reference represents a single reference to a named entity, found in source.
+type reference struct {
+	// sourceRange is the range of the name of the entity at the reference.
+	sourceRange compilercommon.SourceRange
+
+	// isDeclaration indicates whether the reference is the declaration of the entity.
+	isDeclaration bool
+}
+
+// findReferences finds all references to the entity described by the given range information, which
+// was looked up in the document with the given URI. Local values and parameters are only searched for
+// within the document itself, while all other entities are searched for across the workspace.
+func (dt *documentTracker) findReferences(uri string, target grok.RangeInformation, cancelationHandle *CancelationHandle) ([]reference, error) {
+	handle, sources, err := dt.referenceSearchScope(uri, target)
+	if err != nil {
+		return []reference{}, err
+	}
+
+	return dt.findReferencesInSources(handle, sources, target, cancelationHandle)
+}
+
+// findReferencesInSources finds all references to the entity described by the given range information
+// within the given sources, using the given Grok handle to resolve each occurrence of the entity's name.
+func (dt *documentTracker) findReferencesInSources(handle grok.Handle, sources []compilercommon.InputSource, target grok.RangeInformation, cancelationHandle *CancelationHandle) ([]reference, error) {
+	name, hasName := target.Name()
+	if !hasName || name == "" || len(target.SourceRanges) == 0 {
+		return []reference{}, nil
+	}
+
+	// Collect the keys of the ranges defining the target entity. Any name found in source whose lookup
+	// resolves to one of these ranges is a reference to the entity.
+	targetKeys := map[string]bool{}
+	for _, sourceRange := range target.SourceRanges {
+		key, ok := sourceRangeKey(sourceRange)
+		if ok {
+			targetKeys[key] = true
+		}
+	}
+
+	// Entities defined at the module level can only be referenced by name from sources which can see their
+	// module, so other sources need not be searched.
+	moduleSource, isModuleScoped := moduleScopedSource(target)
+
+	references := []reference{}
+	for _, source := range sources {
+		if cancelationHandle.WasCanceled() {
+			return []reference{}, cancelationHandle.Error()
+		}
+
+		contents, err := dt.LoadSourceFile(string(source))
+		if err != nil {
+			continue
+		}
+
+		offsets := identifierOffsets(string(contents), name)
+		if len(offsets) == 0 {
+			continue
+		}
+
+		if isModuleScoped && !dt.canSeeModule(source, moduleSource) {
+			continue
+		}
+
+		// Find the offsets of the declaration(s) of the entity in this source. The declaration is the first
+		// occurrence of the entity's name found within its defining range.
+		declarationOffsets := map[int]bool{}
+		for _, sourceRange := range target.SourceRanges {
+			if sourceRange.Source() != source {
+				continue
+			}
+
+			startRune, err := sourceRange.Start().RunePosition()
+			if err != nil {
+				continue
+			}
+
+			endRune, err := sourceRange.End().RunePosition()
+			if err != nil {
+				continue
+			}
+
+			if offset, found := declarationOffset(offsets, startRune, endRune); found {
+				declarationOffsets[offset] = true
+			}
+		}
+
+		mapper := compilercommon.CreateSourcePositionMapper(contents)
+		for _, offset := range offsets {
+			if cancelationHandle.WasCanceled() {
+				return []reference{}, cancelationHandle.Error()
+			}
+
+			lineNumber, colPosition, err := mapper.RunePositionToLineAndCol(offset)
+			if err != nil {
+				continue
+			}
+
+			rangeInfo, err := handle.LookupPosition(source, lineNumber, colPosition)
+			if err != nil || rangeInfo.Kind == grok.NotFound {
+				continue
+			}
+
+			if !matchesAnyKey(rangeInfo.SourceRanges, targetKeys) {
+				continue
+			}
+
+			references = append(references, reference{
+				sourceRange:   source.RangeForRunePositions(offset, offset+len(name)-1, dt),
+				isDeclaration: declarationOffsets[offset],
+			})
+		}
+	}
+
+	return references, nil
+}
+
+// declarationOffset returns the first of the given (ordered) offsets of an entity's name found within the
+// entity's defining range, from the given start to the given end (inclusive), if any.
+func declarationOffset(offsets []int, startRune int, endRune int) (int, bool) {
+	for _, offset := range offsets {
+		if offset > endRune {
+			break
+		}
+
+		if offset >= startRune {
+			return offset, true
+		}
+	}
+
+	return 0, false
+}
+
+// moduleScopedSource returns the source of the module defining the entity described by the given range
+// information, if the entity is defined at the module level (a type or a module member), and can therefore
+// only be referenced by name from sources which can see its module.
+func moduleScopedSource(target grok.RangeInformation) (compilercommon.InputSource, bool) {
+	if len(target.SourceRanges) == 0 {
+		return "", false
+	}
+
+	switch target.Kind {
+	case grok.TypeRef:
+		break
+
+	case grok.NamedReference:
+		if _, isType := target.NamedReference.Type(); isType {
+			break
+		}
+
+		member, isMember := target.NamedReference.Member()
+		if !isMember {
+			return "", false
+		}
+
+		// Members of types can be referenced via any value of the type, without naming the type.
+		if _, hasParentType := member.ParentType(); hasParentType {
+			return "", false
+		}
+
+	default:
+		return "", false
+	}
+
+	source := target.SourceRanges[0].Source()
+	if !strings.HasSuffix(string(source), sourceshape.SerulianFileExtension) {
+		return "", false
+	}
+
+	return source, true
+}
+
+// canSeeModule returns true if the given source may reference by name the entities defined in the module with
+// the given source: if it is found in the same package (directory) or imports the module or its package. Imports
+// that cannot be resolved to a local path, such as VCS and library imports, are assumed to refer to the module.
+func (dt *documentTracker) canSeeModule(source compilercommon.InputSource, moduleSource compilercommon.InputSource) bool {
+	packageDirectory := path.Dir(string(moduleSource))
+	if path.Dir(string(source)) == packageDirectory {
+		return true
+	}
+
+	imports, err := dt.getSourceImports(source)
+	if err != nil {
+		return false
+	}
+
+	if imports.hasNonLocalImport {
+		return true
+	}
+
+	modulePath := strings.TrimSuffix(string(moduleSource), sourceshape.SerulianFileExtension)
+	for _, importedPath := range imports.localPaths {
+		if importedPath == modulePath || importedPath == packageDirectory {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sourceImports holds the imports of a source at a specific revision.
+type sourceImports struct {
+	// revision is the revision of the source whose imports are held.
+	revision sourceRevision
+
+	// localPaths are the paths of the modules and packages imported from the local file system.
+	localPaths []string
+
+	// hasNonLocalImport indicates whether the source imports any module or package which cannot be resolved
+	// to a local path, such as a VCS or library import.
+	hasNonLocalImport bool
+}
+
+// getSourceImports returns the imports of the given source at its current revision, parsing the source only if
+// its imports were not already collected at that revision.
+func (dt *documentTracker) getSourceImports(source compilercommon.InputSource) (sourceImports, error) {
+	sourcePath := string(source)
+	revision, current, tracked, err := dt.currentRevision(sourcePath)
+	if err != nil {
+		return sourceImports{}, err
+	}
+
+	if cachedValue, exists := dt.sourceImports.Get(sourcePath); exists {
+		if cached := cachedValue.(sourceImports); cached.revision == revision {
+			return cached, nil
+		}
+	}
+
+	// Share the parse tree of tracked documents with the other features working over it.
+	var rootNode *parseNode
+	if tracked {
+		rootNode = dt.documentParseTree(current)
+	} else {
+		contents, err := dt.localPathLoader.LoadSourceFile(sourcePath)
+		if err != nil {
+			return sourceImports{}, err
+		}
+		rootNode = parseSource(source, string(contents))
+	}
+
+	imports := collectImports(source, rootNode)
+	imports.revision = revision
+	dt.sourceImports.Set(sourcePath, imports)
+	return imports, nil
+}
+
+// collectImports returns the imports found in the parse tree of the given source. WebIDL imports are skipped, as
+// they never refer to Serulian modules.
+func collectImports(source compilercommon.InputSource, rootNode *parseNode) sourceImports {
+	imports := sourceImports{localPaths: []string{}}
+	sourceDirectory := path.Dir(string(source))
+	for _, importNode := range rootNode.getChildren(sourceshape.NodePredicateChild) {
+		if importNode.nodeType != sourceshape.NodeTypeImport {
+			continue
+		}
+
+		if importKind, _ := importNode.getProperty(sourceshape.NodeImportPredicateKind); importKind == webIDLImportKind {
+			continue
+		}
+
+		importSource, hasSource := importNode.getProperty(sourceshape.NodeImportPredicateSource)
+		if !hasSource {
+			continue
+		}
+
+		importPath, importType, err := shared.ParseImportValue(importSource)
+		if err != nil || importType != shared.ParsedImportTypeLocal {
+			imports.hasNonLocalImport = true
+			continue
+		}
+
+		imports.localPaths = append(imports.localPaths, path.Join(sourceDirectory, importPath))
+	}
+
+	return imports
+}
+
+// referenceSearchScope returns the Grok handle and the sources over which references to the given target
+// entity, looked up in the document with the given URI, should be searched.
+func (dt *documentTracker) referenceSearchScope(uri string, target grok.RangeInformation) (grok.Handle, []compilercommon.InputSource, error) {
+	path, err := dt.uriToPath(uri)
+	if err != nil {
+		return grok.Handle{}, []compilercommon.InputSource{}, err
+	}
+
+	source := compilercommon.InputSource(path)
+	isLocal := target.Kind == grok.LocalValue || (target.Kind == grok.NamedReference && target.NamedReference.IsParameter())
+
+	// If the entity can be referenced outside of the document, use the Grok of its workspace folder (if any).
+	if !isLocal {
+		if handle, found := dt.workspaceHandle(uri, source); found {
+			sources, err := dt.workspaceSources(handle)
+			return handle, sources, err
+		}
+	}
+
+	handle, err := dt.getGrokHandle(uri, grok.HandleMustBeFresh)
+	if err != nil {
+		return grok.Handle{}, []compilercommon.InputSource{}, err
+	}
+
+	if isLocal {
+		return handle, []compilercommon.InputSource{source}, nil
+	}
+
+	sources, err := dt.workspaceSources(handle)
+	return handle, sources, err
+}
+
+// sourceRangeKey returns a key uniquely identifying the start of the given source range.
+func sourceRangeKey(sourceRange compilercommon.SourceRange) (string, bool) {
+	startRune, err := sourceRange.Start().RunePosition()
+	if err != nil {
+		return "", false
+	}
+
+	return fmt.Sprintf("%s:%v", sourceRange.Source(), startRune), true
+}
+
+// matchesAnyKey returns true if any of the given source ranges has a key found in the keys map.
+func matchesAnyKey(sourceRanges []compilercommon.SourceRange, keys map[string]bool) bool {
+	for _, sourceRange := range sourceRanges {
+		key, ok := sourceRangeKey(sourceRange)
+		if ok && keys[key] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isIdentifierRune returns true if the given rune can be found in an identifier.
+func isIdentifierRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// identifierOffsets returns the byte offsets of all occurrences of the given name in the contents,
+// where the occurrence is not part of a larger identifier.
+func identifierOffsets(contents string, name string) []int {
+	offsets := []int{}
+	if name == "" {
+		return offsets
+	}
+
+	var index = 0
+	for index < len(contents) {
+		found := strings.Index(contents[index:], name)
+		if found < 0 {
+			break
+		}
+
+		found = found + index
+		end := found + len(name)
+		before, _ := utf8.DecodeLastRuneInString(contents[0:found])
+		after, _ := utf8.DecodeRuneInString(contents[end:])
+
+		if (found == 0 || !isIdentifierRune(before)) && (end == len(contents) || !isIdentifierRune(after)) {
+			offsets = append(offsets, found)
+		}
+
+		index = end
+	}
+
+	return offsets
+}
@@ -0,0 +1,193 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/serulian/compiler/compilercommon"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+type identifierOffsetsTest struct {
+	name     string
+	contents string
+	search   string
+	expected []int
+}
+
+var identifierOffsetsTests = []identifierOffsetsTest{
+	{"empty contents", "", "foo", []int{}},
+	{"empty name", "foo bar", "", []int{}},
+	{"no occurrence", "var bar = baz", "foo", []int{}},
+	{"single occurrence", "var foo = 1", "foo", []int{4}},
+	{"whole contents", "foo", "foo", []int{0}},
+	{"multiple occurrences", "foo(foo.bar, foo)", "foo", []int{0, 4, 13}},
+	{"part of larger identifier", "foobar barfoo foo_1 _foo", "foo", []int{}},
+	{"digits continue identifiers", "foo1 1foo foo", "foo", []int{10}},
+	{"punctuation ends identifiers", "this.foo[foo]", "foo", []int{5, 9}},
+	{"adjacent to unicode letter", "éfoo fooé foo", "foo", []int{12}},
+	{"unicode name", "var café = café + 1", "café", []int{4, 12}},
+	{"overlapping candidates", "aaa aa", "aa", []int{4}},
+}
+
+func TestIdentifierOffsets(t *testing.T) {
+	for _, test := range identifierOffsetsTests {
+		offsets := identifierOffsets(test.contents, test.search)
+		if !reflect.DeepEqual(offsets, test.expected) {
+			t.Errorf("%s: expected offsets %v, found %v", test.name, test.expected, offsets)
+		}
+	}
+}
+
+type declarationOffsetTest struct {
+	name           string
+	offsets        []int
+	startRune      int
+	endRune        int
+	expectedOffset int
+	expectedFound  bool
+}
+
+var declarationOffsetTests = []declarationOffsetTest{
+	{"no offsets", []int{}, 0, 10, 0, false},
+	{"first in range", []int{2, 8, 14}, 5, 20, 8, true},
+	{"offset at range start", []int{2, 5, 14}, 5, 20, 5, true},
+	{"offset at range end", []int{2, 20}, 5, 20, 20, true},
+	{"all before range", []int{1, 2, 3}, 5, 20, 0, false},
+	{"all after range", []int{25, 30}, 5, 20, 0, false},
+}
+
+func TestDeclarationOffset(t *testing.T) {
+	for _, test := range declarationOffsetTests {
+		offset, found := declarationOffset(test.offsets, test.startRune, test.endRune)
+		if found != test.expectedFound || offset != test.expectedOffset {
+			t.Errorf("%s: expected (%v, %v), found (%v, %v)", test.name, test.expectedOffset, test.expectedFound, offset, found)
+		}
+	}
+}
+
+type collectImportsTest struct {
+	name              string
+	contents          string
+	localPaths        []string
+	hasNonLocalImport bool
+}
+
+var collectImportsTests = []collectImportsTest{
+	{"no imports", "function First() {}\n", []string{}, false},
+	{"local module import", "import other\n", []string{"/workspace/package/other"}, false},
+	{"local member import", "from other import Second\n", []string{"/workspace/package/other"}, false},
+	{"vcs import", "from \"github.com/some/package\" import Second\n", []string{}, true},
+	{"webidl import", "from webidl`dom` import Document\n", []string{}, false},
+}
+
+func TestCollectImports(t *testing.T) {
+	source := compilercommon.InputSource("/workspace/package/main.seru")
+	for _, test := range collectImportsTests {
+		imports := collectImports(source, parseSource(source, test.contents))
+		if !reflect.DeepEqual(imports.localPaths, test.localPaths) {
+			t.Errorf("%s: expected local paths %v, found %v", test.name, test.localPaths, imports.localPaths)
+		}
+
+		if imports.hasNonLocalImport != test.hasNonLocalImport {
+			t.Errorf("%s: expected non-local import %v, found %v", test.name, test.hasNonLocalImport, imports.hasNonLocalImport)
+		}
+	}
+}
+
+func TestSourceImportsReusedPerVersion(t *testing.T) {
+	dt := newDocumentTracker([]string{})
+	source := compilercommon.InputSource("/workspace/package/main.seru")
+	dt.documents.Set(string(source), document{path: string(source), contents: "import other\n", version: 1})
+
+	if !dt.canSeeModule(source, "/workspace/package/other.seru") {
+		t.Errorf("Expected the module to be visible through its import")
+	}
+
+	if dt.canSeeModule(source, "/workspace/another/third.seru") {
+		t.Errorf("Expected the module to not be visible without an import")
+	}
+
+	// Changing the contents without changing the version must reuse the imports collected at the version.
+	dt.documents.Set(string(source), document{path: string(source), contents: "function First() {}\n", version: 1})
+	if !dt.canSeeModule(source, "/workspace/package/other.seru") {
+		t.Errorf("Expected the imports to be reused at the same version")
+	}
+
+	dt.documents.Set(string(source), document{path: string(source), contents: "function First() {}\n", version: 2})
+	if dt.canSeeModule(source, "/workspace/package/other.seru") {
+		t.Errorf("Expected the imports to be collected again at a new version")
+	}
+}
+
+const referencesModuleSource = `function First() {}
+
+function Second() {
+	First()
+	First()
+}
+`
+
+const referencesSiblingSource = `function Third() {
+	First()
+}
+`
+
+const referencesUnrelatedSource = `function First() {}
+
+function Fourth() {
+	First()
+}
+`
+
+func TestFindReferences(t *testing.T) {
+	ts := newTestServer(t, map[string]string{
+		"main.seru":           referencesModuleSource,
+		"sibling.seru":        referencesSiblingSource,
+		"unrelated/main.seru": referencesUnrelatedSource,
+	})
+	defer ts.close()
+	ts.open("main.seru")
+
+	for _, includeDeclaration := range []bool{true, false} {
+		locations := []protocol.Location{}
+		ts.call(protocol.ReferencesRequest, protocol.ReferenceParams{
+			TextDocumentPositionParams: ts.position("main.seru", "First", 1, 0),
+			Context:                    protocol.ReferenceContext{IncludeDeclaration: includeDeclaration},
+		}, &locations)
+
+		expected := []string{"main.seru:3:1", "main.seru:4:1", "sibling.seru:1:1"}
+		if includeDeclaration {
+			expected = append([]string{"main.seru:0:9"}, expected...)
+		}
+
+		if found := ts.describeLocations(locations); !reflect.DeepEqual(found, expected) {
+			t.Errorf("Expected references %v with includeDeclaration %v, found %v", expected, includeDeclaration, found)
+		}
+	}
+}
+
+func TestFindReferencesToLocalValue(t *testing.T) {
+	ts := newTestServer(t, map[string]string{
+		"main.seru":    "function First() {\n\tvar value = 1\n\tvalue = value + 1\n}\n",
+		"sibling.seru": "function Second() {\n\tvar value = 2\n}\n",
+	})
+	defer ts.close()
+	ts.open("main.seru")
+
+	locations := []protocol.Location{}
+	ts.call(protocol.ReferencesRequest, protocol.ReferenceParams{
+		TextDocumentPositionParams: ts.position("main.seru", "value", 0, 0),
+		Context:                    protocol.ReferenceContext{IncludeDeclaration: true},
+	}, &locations)
+
+	expected := []string{"main.seru:1:5", "main.seru:2:1", "main.seru:2:9"}
+	if found := ts.describeLocations(locations); !reflect.DeepEqual(found, expected) {
+		t.Errorf("Expected references %v, found %v", expected, found)
+	}
+}
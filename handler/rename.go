@@ -0,0 +1,181 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/serulian/compiler/builder"
+	"github.com/serulian/compiler/compilercommon"
+	"github.com/serulian/compiler/grok"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+// checkRenameable returns an error if the entity described by the given range information cannot be
+// renamed. Entities defined in the core library or in VCS packages cannot be renamed, as their
+// source is not part of the workspace.
+func (dt *documentTracker) checkRenameable(target grok.RangeInformation) error {
+	switch target.Kind {
+	case grok.LocalValue:
+		fallthrough
+
+	case grok.TypeRef:
+		fallthrough
+
+	case grok.NamedReference:
+		break
+
+	default:
+		return fmt.Errorf("The element under the cursor cannot be renamed")
+	}
+
+	name, hasName := target.Name()
+	if !hasName || name == "" || len(target.SourceRanges) == 0 {
+		return fmt.Errorf("The element under the cursor cannot be renamed")
+	}
+
+	for _, sourceRange := range target.SourceRanges {
+		source := sourceRange.Source()
+		if isCoreLibrarySource(source) {
+			return fmt.Errorf("`%s` is defined in the core library and cannot be renamed", name)
+		}
+
+		if !dt.isWorkspaceSource(source) {
+			return fmt.Errorf("`%s` is defined in a VCS package and cannot be renamed", name)
+		}
+	}
+
+	return nil
+}
+
+// isCoreLibrarySource returns true if the given source is found under the Serulian core library.
+func isCoreLibrarySource(source compilercommon.InputSource) bool {
+	coreLibraryPath := builder.CORE_LIBRARY.PathOrURL
+	if index := strings.IndexAny(coreLibraryPath, ":@"); index >= 0 {
+		coreLibraryPath = coreLibraryPath[0:index]
+	}
+
+	return strings.Contains(string(source), "/"+coreLibraryPath+"/")
+}
+
+// isValidIdentifier returns true if the given name is a valid Serulian identifier.
+func isValidIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for index, r := range name {
+		if !isIdentifierRune(r) || (index == 0 && unicode.IsDigit(r)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// nameRangeAtPosition returns the range of the given name found under the given position in the
+// document with the given URI, if any.
+func (dt *documentTracker) nameRangeAtPosition(uri string, name string, position protocol.Position) (protocol.Range, bool) {
+	path, err := dt.uriToPath(uri)
+	if err != nil {
+		return protocol.Range{}, false
+	}
+
+	contents, err := dt.LoadSourceFile(path)
+	if err != nil {
+		return protocol.Range{}, false
+	}
+
+	source := compilercommon.InputSource(path)
+	column, err := dt.compilerColumn(source, position)
+	if err != nil {
+		return protocol.Range{}, false
+	}
+
+	mapper := compilercommon.CreateSourcePositionMapper(contents)
+	positionOffset, err := mapper.LineAndColToRunePosition(position.Line, column)
+	if err != nil {
+		return protocol.Range{}, false
+	}
+
+	for _, offset := range identifierOffsets(string(contents), name) {
+		if positionOffset < offset || positionOffset > offset+len(name) {
+			continue
+		}
+
+		documentRange, err := dt.convertRange(source.RangeForRunePositions(offset, offset+len(name)-1, dt))
+		if err != nil {
+			return protocol.Range{}, false
+		}
+
+		return documentRange, true
+	}
+
+	return protocol.Range{}, false
+}
+
+// buildRenameEdit returns a workspace edit which renames all the given references to the new name. If
+// useDocumentChanges is true, the edits are versioned against the documents being tracked, so that
+// edits computed against an open document's (unsaved) contents are applied to that same version.
+func (dt *documentTracker) buildRenameEdit(references []reference, newName string, useDocumentChanges bool) protocol.WorkspaceEdit {
+	editsByURI := map[protocol.DocumentURI][]protocol.TextEdit{}
+	versionsByURI := map[protocol.DocumentURI]*int{}
+
+	for _, reference := range references {
+		uri, ok := dt.sourceToURI(reference.sourceRange.Source())
+		if !ok {
+			continue
+		}
+
+		documentRange, err := dt.convertRange(reference.sourceRange)
+		if err != nil {
+			continue
+		}
+
+		if _, exists := editsByURI[uri]; !exists {
+			var version *int
+			currentValue, isTracked := dt.documents.Get(string(reference.sourceRange.Source()))
+			if isTracked {
+				documentVersion := currentValue.(document).version
+				version = &documentVersion
+			}
+
+			versionsByURI[uri] = version
+		}
+
+		editsByURI[uri] = append(editsByURI[uri], protocol.TextEdit{
+			Range:   documentRange,
+			NewText: newName,
+		})
+	}
+
+	if !useDocumentChanges {
+		return protocol.WorkspaceEdit{Changes: editsByURI}
+	}
+
+	uris := make([]string, 0, len(editsByURI))
+	for uri := range editsByURI {
+		uris = append(uris, uri.String())
+	}
+	sort.Strings(uris)
+
+	documentChanges := make([]protocol.TextDocumentEdit, 0, len(uris))
+	for _, uriString := range uris {
+		uri := protocol.DocumentURI(uriString)
+		documentChanges = append(documentChanges, protocol.TextDocumentEdit{
+			TextDocument: protocol.OptionalVersionedTextDocumentIdentifier{
+				TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri},
+				Version:                versionsByURI[uri],
+			},
+			Edits: editsByURI[uri],
+		})
+	}
+
+	return protocol.WorkspaceEdit{DocumentChanges: documentChanges}
+}
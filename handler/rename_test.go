@@ -0,0 +1,178 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+type isValidIdentifierTest struct {
+	name     string
+	expected bool
+}
+
+var isValidIdentifierTests = []isValidIdentifierTest{
+	{"", false},
+	{"foo", true},
+	{"_foo1", true},
+	{"café", true},
+	{"1foo", false},
+	{"foo bar", false},
+	{"foo.bar", false},
+	{"foo-bar", false},
+}
+
+func TestIsValidIdentifier(t *testing.T) {
+	for _, test := range isValidIdentifierTests {
+		if valid := isValidIdentifier(test.name); valid != test.expected {
+			t.Errorf("Expected %q to be valid: %v, found %v", test.name, test.expected, valid)
+		}
+	}
+}
+
+const renameModuleSource = `function First() {}
+
+function Second() {
+	First()
+}
+`
+
+const renameSiblingSource = `function Third() {
+	First()
+}
+`
+
+// describeTextEdits describes the given edits made to the document with the given URI, sorted.
+func describeTextEdits(ts *testServer, uri protocol.DocumentURI, edits []protocol.TextEdit) []string {
+	described := make([]string, 0, len(edits))
+	for _, edit := range edits {
+		described = append(described, ts.describeLocation(uri, edit.Range)+" "+edit.NewText)
+	}
+
+	sort.Strings(described)
+	return described
+}
+
+func TestRenameAcrossDocuments(t *testing.T) {
+	ts := newTestServer(t, map[string]string{
+		"main.seru":    renameModuleSource,
+		"sibling.seru": renameSiblingSource,
+	})
+	defer ts.close()
+
+	// Open the module with contents differing from those on disk: the edits must be made against the open
+	// document.
+	ts.writeFile("main.seru", "\n\n"+renameModuleSource)
+	ts.open("main.seru")
+	position := ts.position("main.seru", "First", 0, 0)
+	ts.writeFile("main.seru", renameModuleSource)
+
+	edit := protocol.WorkspaceEdit{}
+	ts.call(protocol.RenameRequest, protocol.RenameParams{
+		TextDocumentPositionParams: position,
+		NewName:                    "Renamed",
+	}, &edit)
+
+	found := []string{}
+	for uri, edits := range edit.Changes {
+		found = append(found, describeTextEdits(ts, uri, edits)...)
+	}
+	sort.Strings(found)
+
+	expected := []string{"main.seru:2:9 Renamed", "main.seru:5:1 Renamed", "sibling.seru:1:1 Renamed"}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Expected rename edits %v, found %v", expected, found)
+	}
+}
+
+func TestRenameWithDocumentChanges(t *testing.T) {
+	documentChanges := true
+	ts := startTestServer(t, map[string]string{
+		"main.seru":    renameModuleSource,
+		"sibling.seru": renameSiblingSource,
+	}, protocol.InitializeParams{
+		Capabilities: protocol.ClientCapabilities{
+			Workspace: &protocol.WorkspaceClientCapabilities{
+				WorkspaceEdit: &protocol.WorkspaceEditClientCapabilities{DocumentChanges: &documentChanges},
+			},
+		},
+	})
+	defer ts.close()
+	ts.open("main.seru")
+
+	edit := protocol.WorkspaceEdit{}
+	ts.call(protocol.RenameRequest, protocol.RenameParams{
+		TextDocumentPositionParams: ts.position("main.seru", "First", 1, 0),
+		NewName:                    "Renamed",
+	}, &edit)
+
+	if len(edit.Changes) != 0 || len(edit.DocumentChanges) != 2 {
+		t.Fatalf("Expected versioned changes to two documents, found %+v", edit)
+	}
+
+	for _, documentChange := range edit.DocumentChanges {
+		uri := documentChange.TextDocument.URI.String()
+		switch {
+		case strings.HasSuffix(uri, "/main.seru"):
+			if documentChange.TextDocument.Version == nil || *documentChange.TextDocument.Version != 1 {
+				t.Errorf("Expected the edits to the open document to be versioned, found %v", documentChange.TextDocument.Version)
+			}
+
+		case strings.HasSuffix(uri, "/sibling.seru"):
+			if documentChange.TextDocument.Version != nil {
+				t.Errorf("Expected the edits to the document on disk to not be versioned, found %v", *documentChange.TextDocument.Version)
+			}
+
+		default:
+			t.Errorf("Unexpected edits to %s", uri)
+		}
+	}
+}
+
+func TestPrepareRename(t *testing.T) {
+	ts := newTestServer(t, map[string]string{"main.seru": renameModuleSource})
+	defer ts.close()
+	ts.open("main.seru")
+
+	result := protocol.PrepareRenameResult{}
+	ts.call(protocol.PrepareRenameRequest, protocol.PrepareRenameParams{
+		TextDocumentPositionParams: ts.position("main.seru", "First", 1, 2),
+	}, &result)
+
+	expectedRange := protocol.Range{Start: protocol.Position{Line: 3, Column: 1}, End: protocol.Position{Line: 3, Column: 6}}
+	if result.Placeholder != "First" || result.Range != expectedRange {
+		t.Errorf("Expected to rename `First` at %v, found %+v", expectedRange, result)
+	}
+}
+
+func TestRenameRejected(t *testing.T) {
+	ts := newTestServer(t, map[string]string{
+		"main.seru": "function First() {\n\tvar value string = 'hello'\n}\n",
+	})
+	defer ts.close()
+	ts.open("main.seru")
+
+	// Types of the core library cannot be renamed.
+	err := ts.tryCall(protocol.PrepareRenameRequest, protocol.PrepareRenameParams{
+		TextDocumentPositionParams: ts.position("main.seru", "string", 0, 0),
+	}, &protocol.PrepareRenameResult{})
+	if err == nil || !strings.Contains(err.Error(), "core library") {
+		t.Errorf("Expected renaming a core library type to be rejected, found %v", err)
+	}
+
+	// New names must be valid identifiers.
+	err = ts.tryCall(protocol.RenameRequest, protocol.RenameParams{
+		TextDocumentPositionParams: ts.position("main.seru", "value", 0, 0),
+		NewName:                    "not valid",
+	}, &protocol.WorkspaceEdit{})
+	if err == nil || !strings.Contains(err.Error(), "not a valid name") {
+		t.Errorf("Expected renaming to an invalid name to be rejected, found %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+// selectionRanges returns the selection range for each of the given positions in the document with the
+// given URI. Each selection range is the innermost parse node enclosing the position, with its parents
+// being the successively larger enclosing nodes (identifier, member access, call, statement, block,
+// member, type and so on). The nodes are found in the parse tree of the document shared with the other
+// source-level features, as the Grok handle does not expose the parse nodes of its sources.
+func (dt *documentTracker) selectionRanges(uri string, positions []protocol.Position) ([]protocol.SelectionRange, error) {
+	rootNode, _, contents, err := dt.parseDocument(uri)
+	if err != nil {
+		return []protocol.SelectionRange{}, err
+	}
+
+	selectionRanges := make([]protocol.SelectionRange, 0, len(positions))
+	for _, position := range positions {
+		offset, err := offsetForPosition(string(contents), position, dt.positionEncoding)
+		if err != nil {
+			return []protocol.SelectionRange{}, err
+		}
+
+		selectionRanges = append(selectionRanges, selectionRangeForOffset(rootNode, string(contents), offset, dt.positionEncoding))
+	}
+
+	return selectionRanges, nil
+}
+
+// selectionRangeForOffset returns the selection range for the given byte offset in the parse tree of the
+// given contents. If no node encloses the offset, returns an empty range at the offset.
+func selectionRangeForOffset(rootNode *parseNode, contents string, offset int, encoding protocol.PositionEncodingKind) protocol.SelectionRange {
+	var current *protocol.SelectionRange
+	addRange := func(startOffset int, endOffset int) {
+		selectionRange := protocol.Range{
+			Start: positionForOffset(contents, startOffset, encoding),
+			End:   positionForOffset(contents, endOffset, encoding),
+		}
+
+		// Skip ranges matching their parent, as they would not change the selection.
+		if current != nil && current.Range == selectionRange {
+			return
+		}
+
+		current = &protocol.SelectionRange{
+			Range:  selectionRange,
+			Parent: current,
+		}
+	}
+
+	innermostStart, innermostEnd := 0, len(contents)
+	for _, node := range rootNode.enclosingNodes(offset) {
+		// Nodes ending in a block include the statement terminator that follows it, so trim any trailing
+		// whitespace.
+		startRune, endRune, _ := node.runeRange()
+		endOffset := endRune + 1
+		if endOffset > len(contents) {
+			endOffset = len(contents)
+		}
+
+		for endOffset > startRune+1 && strings.ContainsAny(contents[endOffset-1:endOffset], " \t\r\n") {
+			endOffset--
+		}
+
+		addRange(startRune, endOffset)
+		innermostStart, innermostEnd = startRune, endOffset
+	}
+
+	// Names, such as the member in a member access expression, are not nodes of their own, so add the
+	// identifier under the offset as the innermost range.
+	if startOffset, endOffset, ok := identifierRangeAt(contents, offset); ok && startOffset >= innermostStart && endOffset <= innermostEnd {
+		addRange(startOffset, endOffset)
+	}
+
+	if current == nil {
+		position := positionForOffset(contents, offset, encoding)
+		return protocol.SelectionRange{
+			Range: protocol.Range{Start: position, End: position},
+		}
+	}
+
+	return *current
+}
+
+// identifierRangeAt returns the start and end (exclusive) byte offsets of the identifier found at, or
+// ending at, the given byte offset in the contents, if any.
+func identifierRangeAt(contents string, offset int) (int, int, bool) {
+	startOffset := offset
+	for startOffset > 0 {
+		r, size := utf8.DecodeLastRuneInString(contents[0:startOffset])
+		if !isIdentifierRune(r) {
+			break
+		}
+		startOffset -= size
+	}
+
+	endOffset := offset
+	for endOffset < len(contents) {
+		r, size := utf8.DecodeRuneInString(contents[endOffset:])
+		if !isIdentifierRune(r) {
+			break
+		}
+		endOffset += size
+	}
+
+	return startOffset, endOffset, endOffset > startOffset
+}
@@ -0,0 +1,133 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/serulian/compiler/compilercommon"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+type identifierRangeAtTest struct {
+	name     string
+	contents string
+	offset   int
+	expected string
+	found    bool
+}
+
+var identifierRangeAtTests = []identifierRangeAtTest{
+	{"start of identifier", "foo.bar", 4, "bar", true},
+	{"middle of identifier", "foo.bar", 5, "bar", true},
+	{"end of identifier", "foo.bar", 3, "foo", true},
+	{"end of contents", "foo.bar", 7, "bar", true},
+	{"unicode identifier", "a + café", 6, "café", true},
+	{"between punctuation", "a + (b)", 2, "", false},
+	{"empty contents", "", 0, "", false},
+}
+
+func TestIdentifierRangeAt(t *testing.T) {
+	for _, test := range identifierRangeAtTests {
+		startOffset, endOffset, found := identifierRangeAt(test.contents, test.offset)
+		if found != test.found {
+			t.Errorf("%s: expected found %v, found %v", test.name, test.found, found)
+			continue
+		}
+
+		if found && test.contents[startOffset:endOffset] != test.expected {
+			t.Errorf("%s: expected identifier %q, found %q", test.name, test.expected, test.contents[startOffset:endOffset])
+		}
+	}
+}
+
+const selectionRangesSource = `function First() {
+	var x = someObject.someMember(1)
+}
+`
+
+// selectionRangeTexts returns the text of the given selection range and of each of its parents, in order.
+func selectionRangeTexts(t *testing.T, contents string, selectionRange *protocol.SelectionRange) []string {
+	texts := []string{}
+	for current := selectionRange; current != nil; current = current.Parent {
+		startOffset, startErr := offsetForPosition(contents, current.Range.Start, protocol.PositionEncodingUTF16)
+		endOffset, endErr := offsetForPosition(contents, current.Range.End, protocol.PositionEncodingUTF16)
+		if startErr != nil || endErr != nil {
+			t.Fatalf("Invalid selection range %v", current.Range)
+		}
+
+		texts = append(texts, contents[startOffset:endOffset])
+	}
+	return texts
+}
+
+// expectSelectionChain fails the test if the given texts do not contain the expected texts, in order.
+func expectSelectionChain(t *testing.T, texts []string, expected []string) {
+	remaining := expected
+	for _, text := range texts {
+		if len(remaining) > 0 && text == remaining[0] {
+			remaining = remaining[1:]
+		}
+	}
+
+	if len(remaining) > 0 || texts[0] != expected[0] {
+		t.Errorf("Expected selection ranges to expand through %q, found %q", expected, texts)
+	}
+}
+
+// selectionRangesExpected are the selection ranges expected when expanding from the name of the member
+// accessed in selectionRangesSource.
+var selectionRangesExpected = []string{
+	"someMember",
+	"someObject.someMember",
+	"someObject.someMember(1)",
+	"var x = someObject.someMember(1)",
+	"{\n\tvar x = someObject.someMember(1)\n}",
+	"function First() {\n\tvar x = someObject.someMember(1)\n}",
+}
+
+func TestSelectionRangeForOffset(t *testing.T) {
+	source := compilercommon.InputSource("/main.seru")
+	rootNode := parseSource(source, selectionRangesSource)
+
+	offset := strings.Index(selectionRangesSource, "someMember") + 2
+	selectionRange := selectionRangeForOffset(rootNode, selectionRangesSource, offset, protocol.PositionEncodingUTF16)
+	expectSelectionChain(t, selectionRangeTexts(t, selectionRangesSource, &selectionRange), selectionRangesExpected)
+}
+
+func TestSelectionRangeForOffsetOutsideNodes(t *testing.T) {
+	contents := "\n\n"
+	source := compilercommon.InputSource("/main.seru")
+	selectionRange := selectionRangeForOffset(parseSource(source, contents), contents, 1, protocol.PositionEncodingUTF16)
+
+	expected := protocol.Position{Line: 1, Column: 0}
+	if selectionRange.Range.Start != expected || selectionRange.Range.End != expected || selectionRange.Parent != nil {
+		t.Errorf("Expected an empty selection range at %v, found %+v", expected, selectionRange)
+	}
+}
+
+func TestSelectionRangesRequest(t *testing.T) {
+	ts := newTestServer(t, map[string]string{"main.seru": selectionRangesSource})
+	defer ts.close()
+	ts.open("main.seru")
+
+	memberPosition := ts.position("main.seru", "someMember", 0, 2).Position
+	statementPosition := ts.position("main.seru", "var", 0, 0).Position
+
+	selectionRanges := []protocol.SelectionRange{}
+	ts.call(protocol.SelectionRangeRequest, protocol.SelectionRangeParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(ts.uri("main.seru"))},
+		Positions:    []protocol.Position{memberPosition, statementPosition},
+	}, &selectionRanges)
+
+	if len(selectionRanges) != 2 {
+		t.Fatalf("Expected a selection range for each position, found %v", selectionRanges)
+	}
+
+	expectSelectionChain(t, selectionRangeTexts(t, selectionRangesSource, &selectionRanges[0]), selectionRangesExpected)
+	expectSelectionChain(t, selectionRangeTexts(t, selectionRangesSource, &selectionRanges[1]), selectionRangesExpected[3:])
+}
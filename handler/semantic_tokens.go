@@ -0,0 +1,532 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/serulian/compiler/compilercommon"
+	"github.com/serulian/compiler/graphs/typegraph"
+	"github.com/serulian/compiler/grok"
+	"github.com/serulian/compiler/sourceshape"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+// semanticTokenType is the type of a semantic token, as an index into semanticTokenTypes.
+type semanticTokenType int
+
+const (
+	semanticTokenNamespace semanticTokenType = iota
+	semanticTokenClass
+	semanticTokenAgent
+	semanticTokenInterface
+	semanticTokenStruct
+	semanticTokenNominal
+	semanticTokenTypeParameter
+	semanticTokenParameter
+	semanticTokenVariable
+	semanticTokenField
+	semanticTokenProperty
+	semanticTokenFunction
+	semanticTokenMethod
+	semanticTokenOperator
+)
+
+// semanticTokenTypes are the names of the semantic token types, in the order of the legend. Agents,
+// nominal types and fields have no standard token type, so they are reported under their own types
+// to allow them to be distinguished from classes, structs and properties.
+var semanticTokenTypes = []string{
+	"namespace",
+	"class",
+	"agent",
+	"interface",
+	"struct",
+	"nominal",
+	"typeParameter",
+	"parameter",
+	"variable",
+	"field",
+	"property",
+	"function",
+	"method",
+	"operator",
+}
+
+// semanticTokenModifier is a bit flag for a modifier of a semantic token.
+type semanticTokenModifier int
+
+const (
+	semanticTokenStatic semanticTokenModifier = 1 << iota
+	semanticTokenReadOnly
+	semanticTokenAsync
+	semanticTokenDeprecated
+)
+
+// semanticTokenModifiers are the names of the semantic token modifiers, in the order of their bit flags.
+var semanticTokenModifiers = []string{
+	"static",
+	"readonly",
+	"async",
+	"deprecated",
+}
+
+// semanticToken represents a single classified name found in source.
+type semanticToken struct {
+	// offset is the byte offset of the start of the name.
+	offset int
+
+	// length is the length of the name, in bytes.
+	length int
+
+	// tokenType is the type of the token.
+	tokenType semanticTokenType
+
+	// modifiers are the modifiers of the token.
+	modifiers semanticTokenModifier
+}
+
+// semanticTokensResult holds the data of the last full semantic tokens result sent for a document, against
+// which a later delta is computed.
+type semanticTokensResult struct {
+	resultID string
+	data     []int
+}
+
+// semanticTokens returns the semantic tokens of the full document with the given URI, remembering them
+// for a later delta request.
+func (dt *documentTracker) semanticTokens(uri string, cancelationHandle *CancelationHandle) (protocol.SemanticTokens, error) {
+	path, data, err := dt.encodedSemanticTokens(uri, nil, cancelationHandle)
+	if err != nil {
+		return protocol.SemanticTokens{Data: []int{}}, err
+	}
+
+	resultID := dt.rememberSemanticTokens(path, data)
+	return protocol.SemanticTokens{ResultID: resultID, Data: data}, nil
+}
+
+// semanticTokensDelta returns the changes to the semantic tokens of the document with the given URI since
+// the result with the given ID. If that result is no longer known, the full tokens are returned instead.
+func (dt *documentTracker) semanticTokensDelta(uri string, previousResultID string, cancelationHandle *CancelationHandle) (protocol.SemanticTokensDeltaResult, error) {
+	path, data, err := dt.encodedSemanticTokens(uri, nil, cancelationHandle)
+	if err != nil {
+		return protocol.SemanticTokens{Data: []int{}}, err
+	}
+
+	previousValue, hasPrevious := dt.semanticTokenResults.Get(path)
+	resultID := dt.rememberSemanticTokens(path, data)
+	if !hasPrevious || previousValue.(semanticTokensResult).resultID != previousResultID {
+		return protocol.SemanticTokens{ResultID: resultID, Data: data}, nil
+	}
+
+	return protocol.SemanticTokensDelta{
+		ResultID: resultID,
+		Edits:    semanticTokensEdits(previousValue.(semanticTokensResult).data, data),
+	}, nil
+}
+
+// semanticTokensRange returns the semantic tokens found in the given range of the document with the given URI.
+func (dt *documentTracker) semanticTokensRange(uri string, documentRange protocol.Range, cancelationHandle *CancelationHandle) (protocol.SemanticTokens, error) {
+	_, data, err := dt.encodedSemanticTokens(uri, &documentRange, cancelationHandle)
+	if err != nil {
+		return protocol.SemanticTokens{Data: []int{}}, err
+	}
+
+	return protocol.SemanticTokens{Data: data}, nil
+}
+
+// rememberSemanticTokens records the given data as the latest semantic tokens result for the given path,
+// returning the ID of the result.
+func (dt *documentTracker) rememberSemanticTokens(path string, data []int) string {
+	resultID := fmt.Sprintf("%v", atomic.AddUint64(&dt.lastSemanticTokensResultID, 1))
+	dt.semanticTokenResults.Set(path, semanticTokensResult{resultID, data})
+	return resultID
+}
+
+// encodedSemanticTokens returns the path of the document with the given URI and its semantic tokens, encoded
+// relative to one another as per the spec. If a range is given, only the tokens in that range are returned.
+func (dt *documentTracker) encodedSemanticTokens(uri string, documentRange *protocol.Range, cancelationHandle *CancelationHandle) (string, []int, error) {
+	handle, document, err := dt.getGrokHandleAndDocument(uri, grok.HandleMustBeFresh)
+	if err != nil {
+		return "", []int{}, err
+	}
+
+	// Only the names found in the range (if any) are looked up.
+	startOffset, endOffset := 0, len(document.contents)
+	if documentRange != nil {
+		startOffset, err = offsetForPosition(document.contents, documentRange.Start, dt.positionEncoding)
+		if err != nil {
+			return "", []int{}, err
+		}
+
+		endOffset, err = offsetForPosition(document.contents, documentRange.End, dt.positionEncoding)
+		if err != nil {
+			return "", []int{}, err
+		}
+	}
+
+	rootNode := dt.documentParseTree(document)
+	tokens, err := collectSemanticTokens(handle, rootNode, compilercommon.InputSource(document.path), document.contents, startOffset, endOffset, cancelationHandle)
+	if err != nil {
+		return "", []int{}, err
+	}
+
+	return document.path, encodeSemanticTokens(tokens, document.contents, dt.positionEncoding), nil
+}
+
+// collectSemanticTokens classifies each name found between the given start and end (exclusive) byte offsets of
+// the given contents of the given source, whose parse tree is given, using the Grok handle, returning the tokens
+// for those that could be classified, in order. Names found in comments, string literals or SML text, as well as
+// keywords, are skipped.
+func collectSemanticTokens(handle grok.Handle, rootNode *parseNode, source compilercommon.InputSource, contents string, startOffset int, endOffset int, cancelationHandle *CancelationHandle) ([]semanticToken, error) {
+	skippedRanges := [][2]int{}
+	rootNode.walk(func(node *parseNode) bool {
+		switch node.nodeType {
+		case sourceshape.NodeTypeComment, sourceshape.NodeStringLiteralExpression, sourceshape.NodeTypeSmlText:
+			if startRune, endRune, ok := node.runeRange(); ok {
+				skippedRanges = append(skippedRanges, [2]int{startRune, endRune})
+			}
+			return false
+		}
+
+		return true
+	})
+
+	isSkipped := func(offset int) bool {
+		for _, skippedRange := range skippedRanges {
+			if offset >= skippedRange[0] && offset <= skippedRange[1] {
+				return true
+			}
+		}
+		return false
+	}
+
+	declarations, err := moduleDeclarationTokens(handle, source)
+	if err != nil {
+		return []semanticToken{}, err
+	}
+
+	mapper := compilercommon.CreateSourcePositionMapper([]byte(contents))
+	tokens := []semanticToken{}
+	for _, nameRange := range identifierRanges(contents) {
+		if cancelationHandle.WasCanceled() {
+			return []semanticToken{}, cancelationHandle.Error()
+		}
+
+		if nameRange[0] < startOffset || nameRange[1] > endOffset || isSkipped(nameRange[0]) {
+			continue
+		}
+
+		lineNumber, colPosition, err := mapper.RunePositionToLineAndCol(nameRange[0])
+		if err != nil {
+			continue
+		}
+
+		rangeInfo, err := handle.LookupPosition(source, lineNumber, colPosition)
+		if err != nil {
+			continue
+		}
+
+		name := contents[nameRange[0]:nameRange[1]]
+		if token, ok := classifySemanticToken(rangeInfo, name, declarations); ok {
+			token.offset = nameRange[0]
+			token.length = len(name)
+			tokens = append(tokens, token)
+		}
+	}
+
+	return tokens, nil
+}
+
+// identifierRanges returns the start and end (exclusive) byte offsets of each identifier found in the
+// given contents, in order. Numbers are not considered identifiers.
+func identifierRanges(contents string) [][2]int {
+	ranges := [][2]int{}
+	offset := 0
+	for offset < len(contents) {
+		r, size := utf8.DecodeRuneInString(contents[offset:])
+		if !isIdentifierRune(r) {
+			offset += size
+			continue
+		}
+
+		startOffset := offset
+		for offset < len(contents) {
+			r, size := utf8.DecodeRuneInString(contents[offset:])
+			if !isIdentifierRune(r) {
+				break
+			}
+			offset += size
+		}
+
+		if first, _ := utf8.DecodeRuneInString(contents[startOffset:]); !unicode.IsDigit(first) {
+			ranges = append(ranges, [2]int{startOffset, offset})
+		}
+	}
+
+	return ranges
+}
+
+// moduleDeclarationTokens returns the tokens for the types and members declared in the module defined by the
+// given source, keyed by the source range key of their declaration. A lookup on a declaration returns a named
+// reference to its definition in source rather than to the type graph, so these are used to classify it.
+func moduleDeclarationTokens(handle grok.Handle, source compilercommon.InputSource) (map[string]semanticToken, error) {
+	declarations := map[string]semanticToken{}
+	module, found, err := moduleForSource(handle, source)
+	if err != nil || !found {
+		return declarations, err
+	}
+
+	addDeclaration := func(sourceRanges []compilercommon.SourceRange, token semanticToken) {
+		for _, sourceRange := range sourceRanges {
+			if key, ok := sourceRangeKey(sourceRange); ok && sourceRange.Source() == source {
+				declarations[key] = token
+			}
+		}
+	}
+
+	for _, typeDecl := range module.Types() {
+		addDeclaration(typeDecl.SourceRanges(), typeSemanticToken(typeDecl))
+		for _, member := range typeDecl.MembersAndOperators() {
+			addDeclaration(member.SourceRanges(), memberSemanticToken(member))
+		}
+	}
+
+	for _, member := range module.Members() {
+		addDeclaration(member.SourceRanges(), memberSemanticToken(member))
+	}
+
+	return declarations, nil
+}
+
+// classifySemanticToken returns the token for the given name, based on the range information found by looking
+// up its position. Returns false if the range information does not refer to the name itself (such as for
+// keywords) or cannot be classified.
+func classifySemanticToken(rangeInfo grok.RangeInformation, name string, declarations map[string]semanticToken) (semanticToken, bool) {
+	switch rangeInfo.Kind {
+	case grok.TypeRef:
+		if !rangeInfo.TypeReference.IsNormal() || rangeInfo.TypeReference.ReferredType().Name() != name {
+			return semanticToken{}, false
+		}
+
+		return typeSemanticToken(rangeInfo.TypeReference.ReferredType()), true
+
+	case grok.NamedReference:
+		namedReference := rangeInfo.NamedReference
+		if referencedName, ok := namedReference.Name(); !ok || referencedName != name {
+			return semanticToken{}, false
+		}
+
+		if typeDecl, isType := namedReference.Type(); isType {
+			return typeSemanticToken(typeDecl), true
+		}
+
+		if member, isMember := namedReference.Member(); isMember {
+			return memberSemanticToken(member), true
+		}
+
+		if namedReference.IsParameter() {
+			return semanticToken{tokenType: semanticTokenParameter}, true
+		}
+
+		for _, sourceRange := range rangeInfo.SourceRanges {
+			if key, ok := sourceRangeKey(sourceRange); ok {
+				if token, isDeclaration := declarations[key]; isDeclaration {
+					return token, true
+				}
+			}
+		}
+
+		return semanticToken{tokenType: semanticTokenVariable}, true
+
+	case grok.LocalValue:
+		if rangeInfo.LocalName != name {
+			return semanticToken{}, false
+		}
+
+		return semanticToken{tokenType: semanticTokenVariable}, true
+
+	case grok.PackageOrModule:
+		// The range covers the full import statement, so only the names found in the path are modules.
+		pathNames := strings.FieldsFunc(rangeInfo.PackageOrModule, func(r rune) bool { return !isIdentifierRune(r) })
+		for _, pathName := range pathNames {
+			if pathName == name {
+				return semanticToken{tokenType: semanticTokenNamespace}, true
+			}
+		}
+
+		return semanticToken{}, false
+
+	default:
+		return semanticToken{}, false
+	}
+}
+
+// typeSemanticToken returns the token classifying a reference to the given type.
+func typeSemanticToken(typeDecl typegraph.TGTypeDecl) semanticToken {
+	var modifiers semanticTokenModifier
+	if documentation, hasDocumentation := typeDecl.Documentation(); hasDocumentation && isDeprecated(documentation) {
+		modifiers |= semanticTokenDeprecated
+	}
+
+	switch typeDecl.TypeKind() {
+	case typegraph.ClassType:
+		return semanticToken{tokenType: semanticTokenClass, modifiers: modifiers}
+
+	case typegraph.AgentType:
+		return semanticToken{tokenType: semanticTokenAgent, modifiers: modifiers}
+
+	case typegraph.StructType:
+		return semanticToken{tokenType: semanticTokenStruct, modifiers: modifiers}
+
+	case typegraph.NominalType:
+		return semanticToken{tokenType: semanticTokenNominal, modifiers: modifiers}
+
+	case typegraph.ImplicitInterfaceType:
+		fallthrough
+
+	case typegraph.ExternalInternalType:
+		return semanticToken{tokenType: semanticTokenInterface, modifiers: modifiers}
+
+	case typegraph.GenericType:
+		return semanticToken{tokenType: semanticTokenTypeParameter, modifiers: modifiers}
+
+	case typegraph.AliasType:
+		if aliasedType, hasAliasedType := typeDecl.AliasedType(); hasAliasedType {
+			aliasedToken := typeSemanticToken(aliasedType)
+			aliasedToken.modifiers |= modifiers
+			return aliasedToken
+		}
+
+		return semanticToken{tokenType: semanticTokenClass, modifiers: modifiers}
+
+	default:
+		// Classify any other kind of type as a class, rather than failing the request.
+		return semanticToken{tokenType: semanticTokenClass, modifiers: modifiers}
+	}
+}
+
+// memberSemanticToken returns the token classifying a reference to the given member.
+func memberSemanticToken(member typegraph.TGMember) semanticToken {
+	var modifiers semanticTokenModifier
+	if member.IsStatic() {
+		modifiers |= semanticTokenStatic
+	}
+
+	if member.IsReadOnly() {
+		modifiers |= semanticTokenReadOnly
+	}
+
+	if member.InvokesAsync() {
+		modifiers |= semanticTokenAsync
+	}
+
+	if documentation, hasDocumentation := member.Documentation(); hasDocumentation && isDeprecated(documentation) {
+		modifiers |= semanticTokenDeprecated
+	}
+
+	_, hasParentType := member.ParentType()
+
+	switch symbolKindForMember(member) {
+	case protocol.SymbolOperator:
+		return semanticToken{tokenType: semanticTokenOperator, modifiers: modifiers}
+
+	case protocol.SymbolConstructor:
+		return semanticToken{tokenType: semanticTokenMethod, modifiers: modifiers}
+
+	case protocol.SymbolField:
+		if !hasParentType {
+			return semanticToken{tokenType: semanticTokenVariable, modifiers: modifiers}
+		}
+
+		return semanticToken{tokenType: semanticTokenField, modifiers: modifiers}
+
+	case protocol.SymbolFunction:
+		if !hasParentType {
+			return semanticToken{tokenType: semanticTokenFunction, modifiers: modifiers}
+		}
+
+		return semanticToken{tokenType: semanticTokenMethod, modifiers: modifiers}
+
+	default:
+		return semanticToken{tokenType: semanticTokenProperty, modifiers: modifiers}
+	}
+}
+
+// isDeprecated returns true if the given documentation marks its entity as deprecated, via either a
+// paragraph starting with `Deprecated:` or a `@deprecated` tag.
+func isDeprecated(documentation string) bool {
+	for _, line := range strings.Split(documentation, "\n") {
+		trimmed := strings.TrimLeft(strings.TrimSpace(line), "*/ \t")
+		if strings.HasPrefix(trimmed, "Deprecated:") || strings.HasPrefix(trimmed, "@deprecated") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// encodeSemanticTokens encodes the given tokens, found in the given contents and in order, as per the spec:
+// each token's line is relative to the previous token, as is its start character if on the same line.
+// Characters and lengths are in the given position encoding.
+func encodeSemanticTokens(tokens []semanticToken, contents string, encoding protocol.PositionEncodingKind) []int {
+	lineStarts := []int{0}
+	for offset := 0; offset < len(contents); offset++ {
+		if contents[offset] == '\n' {
+			lineStarts = append(lineStarts, offset+1)
+		}
+	}
+
+	data := make([]int, 0, len(tokens)*5)
+	previousLine, previousColumn := 0, 0
+	for _, token := range tokens {
+		line := sort.SearchInts(lineStarts, token.offset+1) - 1
+		lineStart := lineStarts[line]
+		column := encodedColumn(contents[lineStart:token.offset], token.offset-lineStart, encoding)
+		length := encodedColumn(contents[token.offset:token.offset+token.length], token.length, encoding)
+
+		deltaColumn := column
+		if line == previousLine {
+			deltaColumn = column - previousColumn
+		}
+
+		data = append(data, line-previousLine, deltaColumn, length, int(token.tokenType), int(token.modifiers))
+		previousLine, previousColumn = line, column
+	}
+
+	return data
+}
+
+// semanticTokensEdits returns the edits transforming the previous semantic tokens data into the current
+// data: a single edit replacing the elements between their common prefix and suffix, if any.
+func semanticTokensEdits(previous []int, current []int) []protocol.SemanticTokensEdit {
+	prefix := 0
+	for prefix < len(previous) && prefix < len(current) && previous[prefix] == current[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(previous)-prefix && suffix < len(current)-prefix &&
+		previous[len(previous)-suffix-1] == current[len(current)-suffix-1] {
+		suffix++
+	}
+
+	if prefix == len(previous) && prefix == len(current) {
+		return []protocol.SemanticTokensEdit{}
+	}
+
+	return []protocol.SemanticTokensEdit{
+		{
+			Start:       prefix,
+			DeleteCount: len(previous) - prefix - suffix,
+			Data:        current[prefix : len(current)-suffix],
+		},
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+type semanticTokensEditsTest struct {
+	name     string
+	previous []int
+	current  []int
+	expected []protocol.SemanticTokensEdit
+}
+
+var semanticTokensEditsTests = []semanticTokensEditsTest{
+	{"equal", []int{0, 1, 3, 2, 0}, []int{0, 1, 3, 2, 0}, []protocol.SemanticTokensEdit{}},
+	{"both empty", []int{}, []int{}, []protocol.SemanticTokensEdit{}},
+
+	{"changed token", []int{0, 1, 3, 2, 0, 1, 0, 4, 1, 0}, []int{0, 1, 3, 2, 0, 1, 0, 5, 1, 0}, []protocol.SemanticTokensEdit{
+		{Start: 7, DeleteCount: 1, Data: []int{5}},
+	}},
+
+	{"appended token", []int{0, 1, 3, 2, 0}, []int{0, 1, 3, 2, 0, 1, 0, 4, 1, 0}, []protocol.SemanticTokensEdit{
+		{Start: 5, DeleteCount: 0, Data: []int{1, 0, 4, 1, 0}},
+	}},
+
+	{"removed token", []int{0, 1, 3, 2, 0, 1, 0, 4, 1, 0}, []int{1, 0, 4, 1, 0}, []protocol.SemanticTokensEdit{
+		{Start: 0, DeleteCount: 5, Data: []int{}},
+	}},
+
+	{"from empty", []int{}, []int{0, 1, 3, 2, 0}, []protocol.SemanticTokensEdit{
+		{Start: 0, DeleteCount: 0, Data: []int{0, 1, 3, 2, 0}},
+	}},
+
+	{"to empty", []int{0, 1, 3, 2, 0}, []int{}, []protocol.SemanticTokensEdit{
+		{Start: 0, DeleteCount: 5, Data: []int{}},
+	}},
+
+	{"repeated values", []int{1, 1, 1}, []int{1, 1, 1, 1}, []protocol.SemanticTokensEdit{
+		{Start: 3, DeleteCount: 0, Data: []int{1}},
+	}},
+}
+
+func TestSemanticTokensEdits(t *testing.T) {
+	for _, test := range semanticTokensEditsTests {
+		edits := semanticTokensEdits(test.previous, test.current)
+		if !reflect.DeepEqual(edits, test.expected) {
+			t.Errorf("%s: expected edits %v, found %v", test.name, test.expected, edits)
+			continue
+		}
+
+		// Ensure applying the edits to the previous data produces the current data.
+		updated := append([]int{}, test.previous...)
+		for _, edit := range edits {
+			updated = append(updated[0:edit.Start], append(append([]int{}, edit.Data...), updated[edit.Start+edit.DeleteCount:]...)...)
+		}
+
+		if !reflect.DeepEqual(updated, test.current) {
+			t.Errorf("%s: expected edits to produce %v, found %v", test.name, test.current, updated)
+		}
+	}
+}
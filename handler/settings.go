@@ -0,0 +1,273 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/serulian/compiler/grok"
+
+	"github.com/serulian/serulian-langserver/protocol"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// settingsRegistrationID is the ID under which the server registers for configuration changes with the client.
+const settingsRegistrationID = "serulian-settings"
+
+// serverSettings defines the settings of the server which can be changed at runtime by the client or the
+// project configuration files.
+type serverSettings struct {
+	// buildTimeout is the maximum duration to build a Grok handle before timing out.
+	buildTimeout time.Duration
+
+	// diagnoseDelay is the delay waited after a change before a document is diagnosed.
+	diagnoseDelay time.Duration
+
+	// vcsDevelopmentDirectories are the VCS development directories to be passed to Grok, if any.
+	vcsDevelopmentDirectories []string
+
+	// formatOnSave defines when documents are formatted before being saved.
+	formatOnSave protocol.FormatOnSaveSetting
+
+	// inlayHints defines which kinds of inlay hints are shown.
+	inlayHints inlayHintSettings
+
+	// warningSeverity is the severity with which the warnings produced by the compiler are reported.
+	warningSeverity protocol.LintSeverity
+
+	// codeLens indicates whether code lenses are provided.
+	codeLens bool
+
+	// documentLinks indicates whether links are provided for imports.
+	documentLinks bool
+
+	// semanticTokens indicates whether semantic tokens are provided.
+	semanticTokens bool
+
+	// onTypeFormatting indicates whether documents are formatted as they are typed.
+	onTypeFormatting bool
+}
+
+// defaultSettings returns the settings used until the client provides its own, with the given VCS development
+// directories as specified on the command line.
+func defaultSettings(vcsDevelopmentDirectories []string) serverSettings {
+	return serverSettings{
+		buildTimeout:              MaximumBuildDuration,
+		diagnoseDelay:             DiagnoseDelay,
+		vcsDevelopmentDirectories: vcsDevelopmentDirectories,
+		formatOnSave:              protocol.FormatOnSaveManual,
+		inlayHints:                defaultInlayHintSettings,
+		warningSeverity:           protocol.LintSeverityWarning,
+		codeLens:                  true,
+		documentLinks:             true,
+		semanticTokens:            true,
+		onTypeFormatting:          true,
+	}
+}
+
+// withOverrides returns a copy of the settings with those specified by the client applied. Invalid settings
+// are ignored.
+func (s serverSettings) withOverrides(overrides protocol.Settings) serverSettings {
+	if overrides.BuildTimeout != nil {
+		if *overrides.BuildTimeout > 0 {
+			s.buildTimeout = time.Duration(*overrides.BuildTimeout) * time.Millisecond
+		} else {
+			log.Printf("Ignoring invalid build timeout: %v", *overrides.BuildTimeout)
+		}
+	}
+
+	if overrides.DiagnoseDelay != nil {
+		if *overrides.DiagnoseDelay >= 0 {
+			s.diagnoseDelay = time.Duration(*overrides.DiagnoseDelay) * time.Millisecond
+		} else {
+			log.Printf("Ignoring invalid diagnose delay: %v", *overrides.DiagnoseDelay)
+		}
+	}
+
+	if overrides.VCSDevelopmentDirectories != nil {
+		s.vcsDevelopmentDirectories = overrides.VCSDevelopmentDirectories
+	}
+
+	if overrides.FormatOnSave != nil {
+		switch *overrides.FormatOnSave {
+		case protocol.FormatOnSaveOff, protocol.FormatOnSaveManual, protocol.FormatOnSaveAlways:
+			s.formatOnSave = *overrides.FormatOnSave
+
+		default:
+			log.Printf("Ignoring invalid format on save setting: %s", *overrides.FormatOnSave)
+		}
+	}
+
+	s.inlayHints = s.inlayHints.withOverrides(overrides.InlayHints)
+
+	if lint := overrides.Lint; lint != nil && lint.Warnings != nil {
+		switch *lint.Warnings {
+		case protocol.LintSeverityOff, protocol.LintSeverityHint, protocol.LintSeverityInformation, protocol.LintSeverityWarning, protocol.LintSeverityError:
+			s.warningSeverity = *lint.Warnings
+
+		default:
+			log.Printf("Ignoring invalid warning severity: %s", *lint.Warnings)
+		}
+	}
+
+	if features := overrides.Features; features != nil {
+		overrideBool(&s.codeLens, features.CodeLens)
+		overrideBool(&s.documentLinks, features.DocumentLinks)
+		overrideBool(&s.semanticTokens, features.SemanticTokens)
+		overrideBool(&s.onTypeFormatting, features.OnTypeFormatting)
+	}
+
+	return s
+}
+
+// requiresNewGrokers returns true if Grokers created under the given settings must be replaced under these
+// settings, as Grokers are configured on creation.
+func (s serverSettings) requiresNewGrokers(previous serverSettings) bool {
+	if s.buildTimeout != previous.buildTimeout || len(s.vcsDevelopmentDirectories) != len(previous.vcsDevelopmentDirectories) {
+		return true
+	}
+
+	for index, directory := range s.vcsDevelopmentDirectories {
+		if directory != previous.vcsDevelopmentDirectories[index] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// overrideBool sets the given value to the override, if specified.
+func overrideBool(value *bool, override *bool) {
+	if override != nil {
+		*value = *override
+	}
+}
+
+// settingsFor returns the current settings of the given path, which are those of the workspace folder
+// containing it, if any.
+func (dt *documentTracker) settingsFor(filePath string) serverSettings {
+	if folder, found := dt.workspaceFolderFor(filePath); found {
+		return folder.settings
+	}
+
+	dt.settingsLock.RLock()
+	defer dt.settingsLock.RUnlock()
+	return dt.settings
+}
+
+// documentSettings returns the current settings of the document with the given URI.
+func (dt *documentTracker) documentSettings(uri string) serverSettings {
+	path, err := dt.uriToPath(uri)
+	if err != nil {
+		return dt.settingsFor("")
+	}
+
+	return dt.settingsFor(path)
+}
+
+// folderSettings returns the settings of a workspace folder with the given project configuration, layering
+// its settings over the defaults specified on the command line, and under those specified by the client.
+func (dt *documentTracker) folderSettings(config projectConfig) serverSettings {
+	dt.settingsLock.RLock()
+	defer dt.settingsLock.RUnlock()
+	return dt.commandLineSettings.withOverrides(config.Settings).withOverrides(dt.clientSettings)
+}
+
+// applySettings applies the given settings specified by the client to the document tracker, replacing those
+// it previously specified.
+func (dt *documentTracker) applySettings(ctx context.Context, conn *jsonrpc2.Conn, clientSettings protocol.Settings) {
+	dt.settingsLock.Lock()
+	dt.clientSettings = clientSettings
+	previous := dt.settings
+	dt.settings = dt.commandLineSettings.withOverrides(clientSettings)
+	outsideAffected := dt.settings.requiresNewGrokers(previous)
+	dt.settingsLock.Unlock()
+
+	// Recompute the settings of each workspace folder, replacing the Grokers of those whose changed settings
+	// require it, along with the Grokers of their documents. Documents outside of the workspace folders are
+	// affected by changes to the settings of the tracker itself.
+	affectedFolders := map[string]bool{}
+	for _, folder := range dt.getWorkspaceFolders() {
+		folderPrevious := folder.settings
+		folder.settings = dt.folderSettings(folder.config)
+		dt.workspaceFolders.Set(folder.rootPath, folder)
+
+		if folder.settings.requiresNewGrokers(folderPrevious) {
+			affectedFolders[folder.rootPath] = true
+		}
+	}
+
+	if !outsideAffected && len(affectedFolders) == 0 {
+		return
+	}
+
+	affected := map[*grok.Groker]bool{}
+	for _, folder := range dt.getWorkspaceFolders() {
+		if affectedFolders[folder.rootPath] {
+			for groker := range dt.folderGrokers(folder) {
+				affected[groker] = true
+			}
+		}
+	}
+
+	for _, value := range dt.documents.Items() {
+		current := value.(document)
+		if _, found := dt.workspaceFolderFor(current.path); !found && outsideAffected {
+			affected[current.groker] = true
+		}
+	}
+
+	log.Printf("Replacing Grokers due to changed settings")
+	dt.replaceGrokers(ctx, conn, func(groker *grok.Groker) bool { return affected[groker] })
+}
+
+// applySettings applies the given settings specified by the client, serialized with the calls which modify
+// the document tracker. If holdsLock is true, the caller must already hold the synchronousLock. Settings are
+// ignored once the server is shutting down.
+func (h *SerulianLangServerHandler) applySettings(ctx context.Context, conn *jsonrpc2.Conn, overrides protocol.Settings, holdsLock bool) {
+	if !holdsLock {
+		h.synchronousLock.Lock()
+		defer h.synchronousLock.Unlock()
+	}
+
+	if h.getState() == stateShuttingDown {
+		return
+	}
+
+	log.Printf("Applying settings: %+v\n", overrides)
+	h.documentTracker.applySettings(ctx, conn, overrides)
+}
+
+// settingsRegistration returns the registration asking the client to notify the server of changes to its
+// configuration.
+func settingsRegistration() protocol.Registration {
+	return protocol.Registration{
+		ID:     settingsRegistrationID,
+		Method: protocol.DidChangeConfigurationNotification,
+	}
+}
+
+// pullSettings retrieves the Serulian settings from the client's configuration, and applies them.
+func (h *SerulianLangServerHandler) pullSettings(ctx context.Context, conn *jsonrpc2.Conn) {
+	results := []*protocol.Settings{}
+	err := conn.Call(ctx, protocol.ConfigurationRequest, protocol.ConfigurationParams{
+		Items: []protocol.ConfigurationItem{
+			{Section: protocol.SettingsSection},
+		},
+	}, &results)
+	if err != nil {
+		log.Printf("Could not retrieve settings from the client: %v", err)
+		return
+	}
+
+	if len(results) == 0 || results[0] == nil {
+		return
+	}
+
+	h.applySettings(ctx, conn, *results[0], false)
+}
@@ -0,0 +1,134 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+func intSetting(value int) *int {
+	return &value
+}
+
+func boolSetting(value bool) *bool {
+	return &value
+}
+
+func formatOnSaveSetting(value protocol.FormatOnSaveSetting) *protocol.FormatOnSaveSetting {
+	return &value
+}
+
+func lintSeveritySetting(value protocol.LintSeverity) *protocol.LintSeverity {
+	return &value
+}
+
+type withOverridesTest struct {
+	name      string
+	overrides protocol.Settings
+	expected  func(settings *serverSettings)
+}
+
+var withOverridesTests = []withOverridesTest{
+	{"no overrides", protocol.Settings{}, func(settings *serverSettings) {}},
+
+	{"build timeout", protocol.Settings{BuildTimeout: intSetting(2500)}, func(settings *serverSettings) {
+		settings.buildTimeout = 2500 * time.Millisecond
+	}},
+
+	{"zero build timeout", protocol.Settings{BuildTimeout: intSetting(0)}, func(settings *serverSettings) {}},
+	{"negative build timeout", protocol.Settings{BuildTimeout: intSetting(-1)}, func(settings *serverSettings) {}},
+
+	{"diagnose delay", protocol.Settings{DiagnoseDelay: intSetting(100)}, func(settings *serverSettings) {
+		settings.diagnoseDelay = 100 * time.Millisecond
+	}},
+
+	{"zero diagnose delay", protocol.Settings{DiagnoseDelay: intSetting(0)}, func(settings *serverSettings) {
+		settings.diagnoseDelay = 0
+	}},
+
+	{"negative diagnose delay", protocol.Settings{DiagnoseDelay: intSetting(-1)}, func(settings *serverSettings) {}},
+
+	{"vcs development directories", protocol.Settings{VCSDevelopmentDirectories: []string{"/some/dir"}}, func(settings *serverSettings) {
+		settings.vcsDevelopmentDirectories = []string{"/some/dir"}
+	}},
+
+	{"format on save", protocol.Settings{FormatOnSave: formatOnSaveSetting(protocol.FormatOnSaveAlways)}, func(settings *serverSettings) {
+		settings.formatOnSave = protocol.FormatOnSaveAlways
+	}},
+
+	{"invalid format on save", protocol.Settings{FormatOnSave: formatOnSaveSetting("sometimes")}, func(settings *serverSettings) {}},
+
+	{"inlay hints", protocol.Settings{InlayHints: &protocol.InlayHintSettings{VariableTypes: boolSetting(false)}}, func(settings *serverSettings) {
+		settings.inlayHints.variableTypes = false
+	}},
+
+	{"warning severity", protocol.Settings{Lint: &protocol.LintSettings{Warnings: lintSeveritySetting(protocol.LintSeverityError)}}, func(settings *serverSettings) {
+		settings.warningSeverity = protocol.LintSeverityError
+	}},
+
+	{"invalid warning severity", protocol.Settings{Lint: &protocol.LintSettings{Warnings: lintSeveritySetting("loud")}}, func(settings *serverSettings) {}},
+	{"lint without warnings", protocol.Settings{Lint: &protocol.LintSettings{}}, func(settings *serverSettings) {}},
+
+	{"features", protocol.Settings{Features: &protocol.FeatureSettings{CodeLens: boolSetting(false), SemanticTokens: boolSetting(false)}}, func(settings *serverSettings) {
+		settings.codeLens = false
+		settings.semanticTokens = false
+	}},
+}
+
+func TestWithOverrides(t *testing.T) {
+	for _, test := range withOverridesTests {
+		expected := defaultSettings([]string{"/dev/dir"})
+		test.expected(&expected)
+
+		settings := defaultSettings([]string{"/dev/dir"}).withOverrides(test.overrides)
+		if !reflect.DeepEqual(settings, expected) {
+			t.Errorf("%s: expected settings %+v, found %+v", test.name, expected, settings)
+		}
+	}
+}
+
+func TestWithOverridesLayering(t *testing.T) {
+	project := protocol.Settings{BuildTimeout: intSetting(1000), DiagnoseDelay: intSetting(50)}
+	client := protocol.Settings{DiagnoseDelay: intSetting(200)}
+
+	settings := defaultSettings(nil).withOverrides(project).withOverrides(client)
+	if settings.buildTimeout != 1000*time.Millisecond {
+		t.Errorf("Expected build timeout from the project configuration, found %v", settings.buildTimeout)
+	}
+
+	if settings.diagnoseDelay != 200*time.Millisecond {
+		t.Errorf("Expected diagnose delay from the client, found %v", settings.diagnoseDelay)
+	}
+}
+
+type requiresNewGrokersTest struct {
+	name      string
+	overrides protocol.Settings
+	expected  bool
+}
+
+var requiresNewGrokersTests = []requiresNewGrokersTest{
+	{"no changes", protocol.Settings{}, false},
+	{"changed diagnose delay", protocol.Settings{DiagnoseDelay: intSetting(100)}, false},
+	{"changed features", protocol.Settings{Features: &protocol.FeatureSettings{CodeLens: boolSetting(false)}}, false},
+	{"changed build timeout", protocol.Settings{BuildTimeout: intSetting(100)}, true},
+	{"same vcs development directories", protocol.Settings{VCSDevelopmentDirectories: []string{"/dev/dir"}}, false},
+	{"changed vcs development directory", protocol.Settings{VCSDevelopmentDirectories: []string{"/other/dir"}}, true},
+	{"added vcs development directory", protocol.Settings{VCSDevelopmentDirectories: []string{"/dev/dir", "/other/dir"}}, true},
+}
+
+func TestRequiresNewGrokers(t *testing.T) {
+	for _, test := range requiresNewGrokersTests {
+		previous := defaultSettings([]string{"/dev/dir"})
+		settings := previous.withOverrides(test.overrides)
+		if requiresNew := settings.requiresNewGrokers(previous); requiresNew != test.expected {
+			t.Errorf("%s: expected %v, found %v", test.name, test.expected, requiresNew)
+		}
+	}
+}
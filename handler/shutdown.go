@@ -0,0 +1,52 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"log"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// ShutdownDrainTimeout is the maximum duration to wait for in-flight requests to complete on shutdown.
+const ShutdownDrainTimeout = 5 * time.Second
+
+// shutdown moves the server into the shutting down state, after which no new work is accepted. Any
+// in-flight requests are canceled and given a chance to complete, and any pending diagnostics are
+// published, so that the server can exit cleanly on the `exit` notification.
+func (h *SerulianLangServerHandler) shutdown(id jsonrpc2.ID) {
+	h.setState(stateShuttingDown)
+
+	// Cancel all in-flight requests other than the shutdown request itself.
+	for requestID, handle := range h.cancelationHandles.Items() {
+		if requestID != id.String() {
+			handle.(*CancelationHandle).Cancel()
+		}
+	}
+
+	// Wait for the in-flight requests to complete.
+	drained := make(chan struct{})
+	go func() {
+		h.inFlight.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		break
+
+	case <-time.After(ShutdownDrainTimeout):
+		log.Printf("Timed out waiting for in-flight requests to complete on shutdown")
+	}
+
+	// Stop watching files.
+	if h.fileWatcher != nil {
+		h.fileWatcher.close()
+	}
+
+	// Publish any pending diagnostics.
+	h.documentTracker.diagnostics.flush()
+}
@@ -0,0 +1,373 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+// lineHunk represents a range of lines in the original text, [originalStart, originalEnd), replaced by a
+// range of lines in the updated text, [updatedStart, updatedEnd).
+type lineHunk struct {
+	originalStart int
+	originalEnd   int
+	updatedStart  int
+	updatedEnd    int
+}
+
+// computeTextEdits returns the minimal set of edits which transform the original text into the updated
+// text. Edits are computed over lines, with lines replaced one-for-one further narrowed to a separate
+// edit of only the characters that changed on each line. Columns are in the given position encoding.
+func computeTextEdits(original string, updated string, encoding protocol.PositionEncodingKind) []protocol.TextEdit {
+	originalLines := splitLinesWithTerminators(original)
+	updatedLines := splitLinesWithTerminators(updated)
+
+	edits := []protocol.TextEdit{}
+	for _, hunk := range diffLines(originalLines, updatedLines) {
+		if hunk.originalEnd-hunk.originalStart == hunk.updatedEnd-hunk.updatedStart {
+			if lineEdits, ok := lineTextEdits(hunk, originalLines, updatedLines, encoding); ok {
+				edits = append(edits, lineEdits...)
+				continue
+			}
+		}
+
+		edits = append(edits, protocol.TextEdit{
+			Range: protocol.Range{
+				Start: linePosition(originalLines, hunk.originalStart, encoding),
+				End:   linePosition(originalLines, hunk.originalEnd, encoding),
+			},
+			NewText: strings.Join(updatedLines[hunk.updatedStart:hunk.updatedEnd], ""),
+		})
+	}
+
+	return edits
+}
+
+// clipTextEdits returns the given edits of the given contents which intersect the given range, clipped to it.
+// Edits found entirely within the range are returned as is, while those crossing its boundaries are split into
+// edits of the individual characters changed, of which only those within the range are kept. Columns are in
+// the given position encoding.
+func clipTextEdits(contents string, edits []protocol.TextEdit, clipRange protocol.Range, encoding protocol.PositionEncodingKind) []protocol.TextEdit {
+	clipped := []protocol.TextEdit{}
+	rangeStart, err := offsetForPosition(contents, clipRange.Start, encoding)
+	if err != nil {
+		return clipped
+	}
+
+	rangeEnd, err := offsetForPosition(contents, clipRange.End, encoding)
+	if err != nil || rangeEnd < rangeStart {
+		return clipped
+	}
+
+	for _, edit := range edits {
+		editStart, err := offsetForPosition(contents, edit.Range.Start, encoding)
+		if err != nil {
+			continue
+		}
+
+		editEnd, err := offsetForPosition(contents, edit.Range.End, encoding)
+		if err != nil {
+			continue
+		}
+
+		if editStart >= rangeStart && editEnd <= rangeEnd {
+			clipped = append(clipped, edit)
+			continue
+		}
+
+		for _, change := range characterChanges(contents[editStart:editEnd], edit.NewText) {
+			changeStart := editStart + change.originalStart
+			changeEnd := editStart + change.originalEnd
+
+			// Insertions are kept if found within the range, including at its boundaries, while removals
+			// are kept only for the part of the text removed found within the range.
+			start, end := changeStart, changeEnd
+			if start < rangeStart {
+				start = rangeStart
+			}
+
+			if end > rangeEnd {
+				end = rangeEnd
+			}
+
+			if start > end || (start == end && changeStart != changeEnd) {
+				continue
+			}
+
+			clipped = append(clipped, protocol.TextEdit{
+				Range: protocol.Range{
+					Start: positionForOffset(contents, start, encoding),
+					End:   positionForOffset(contents, end, encoding),
+				},
+				NewText: change.updatedText,
+			})
+		}
+	}
+
+	return clipped
+}
+
+// characterChange represents the replacement of the bytes [originalStart, originalEnd) of an original text by
+// the updated text.
+type characterChange struct {
+	originalStart int
+	originalEnd   int
+	updatedText   string
+}
+
+// characterChanges returns the changes of the individual characters which transform the original text into
+// the updated text, in order.
+func characterChanges(original string, updated string) []characterChange {
+	originalCharacters, originalOffsets := splitCharacters(original)
+	updatedCharacters, updatedOffsets := splitCharacters(updated)
+
+	hunks := diffLines(originalCharacters, updatedCharacters)
+	changes := make([]characterChange, 0, len(hunks))
+	for _, hunk := range hunks {
+		changes = append(changes, characterChange{
+			originalStart: originalOffsets[hunk.originalStart],
+			originalEnd:   originalOffsets[hunk.originalEnd],
+			updatedText:   updated[updatedOffsets[hunk.updatedStart]:updatedOffsets[hunk.updatedEnd]],
+		})
+	}
+
+	return changes
+}
+
+// splitCharacters splits the given text into its characters, returning them along with the byte offset of each,
+// followed by the length of the text.
+func splitCharacters(text string) ([]string, []int) {
+	characters := make([]string, 0, len(text))
+	offsets := make([]int, 0, len(text)+1)
+	for offset, r := range text {
+		characters = append(characters, string(r))
+		offsets = append(offsets, offset)
+	}
+
+	return characters, append(offsets, len(text))
+}
+
+// lineTextEdits returns an edit for each of the lines of the given hunk, which must replace as many lines as
+// it removes, replacing only the changed characters of each line. Returns false if any of the lines differ in
+// their terminators.
+func lineTextEdits(hunk lineHunk, originalLines []string, updatedLines []string, encoding protocol.PositionEncodingKind) ([]protocol.TextEdit, bool) {
+	edits := make([]protocol.TextEdit, 0, hunk.originalEnd-hunk.originalStart)
+	for index := 0; index < hunk.originalEnd-hunk.originalStart; index++ {
+		originalLine := originalLines[hunk.originalStart+index]
+		updatedLine := updatedLines[hunk.updatedStart+index]
+		if originalLine == updatedLine {
+			continue
+		}
+
+		edit, ok := lineTextEdit(hunk.originalStart+index, originalLine, updatedLine, encoding)
+		if !ok {
+			return []protocol.TextEdit{}, false
+		}
+
+		edits = append(edits, edit)
+	}
+
+	return edits, true
+}
+
+// lineTextEdit returns an edit replacing only the changed characters of the original line, found at the
+// given line number, to produce the updated line. Returns false if the lines differ in their terminators.
+func lineTextEdit(lineNumber int, originalLine string, updatedLine string, encoding protocol.PositionEncodingKind) (protocol.TextEdit, bool) {
+	originalTerminator := lineTerminator(originalLine)
+	if originalTerminator != lineTerminator(updatedLine) {
+		return protocol.TextEdit{}, false
+	}
+
+	originalText := strings.TrimSuffix(originalLine, originalTerminator)
+	updatedText := strings.TrimSuffix(updatedLine, originalTerminator)
+
+	// Find the common prefix and suffix, making sure not to split any characters.
+	prefix := 0
+	for prefix < len(originalText) && prefix < len(updatedText) && originalText[prefix] == updatedText[prefix] {
+		prefix++
+	}
+
+	for prefix > 0 && prefix < len(originalText) && !utf8.RuneStart(originalText[prefix]) {
+		prefix--
+	}
+
+	suffix := 0
+	for suffix < len(originalText)-prefix && suffix < len(updatedText)-prefix &&
+		originalText[len(originalText)-suffix-1] == updatedText[len(updatedText)-suffix-1] {
+		suffix++
+	}
+
+	for suffix > 0 && !utf8.RuneStart(originalText[len(originalText)-suffix]) {
+		suffix--
+	}
+
+	return protocol.TextEdit{
+		Range: protocol.Range{
+			Start: protocol.Position{Line: lineNumber, Column: encodedColumn(originalText, prefix, encoding)},
+			End:   protocol.Position{Line: lineNumber, Column: encodedColumn(originalText, len(originalText)-suffix, encoding)},
+		},
+		NewText: updatedText[prefix : len(updatedText)-suffix],
+	}, true
+}
+
+// lineTerminator returns the terminator of the given line, if any. A carriage return before the newline
+// is part of the terminator, as clients do not consider it to be part of the line's text.
+func lineTerminator(line string) string {
+	if strings.HasSuffix(line, "\r\n") {
+		return "\r\n"
+	}
+
+	if strings.HasSuffix(line, "\n") {
+		return "\n"
+	}
+
+	return ""
+}
+
+// splitLinesWithTerminators splits the given text into lines, with each line retaining its terminator.
+func splitLinesWithTerminators(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[0 : len(lines)-1]
+	}
+	return lines
+}
+
+// linePosition returns the position of the start of the given line. If the line is past the last line,
+// returns the position of the end of the text.
+func linePosition(lines []string, lineNumber int, encoding protocol.PositionEncodingKind) protocol.Position {
+	if lineNumber < len(lines) || lineNumber == 0 {
+		return protocol.Position{Line: lineNumber, Column: 0}
+	}
+
+	lastLine := lines[len(lines)-1]
+	if strings.HasSuffix(lastLine, "\n") {
+		return protocol.Position{Line: len(lines), Column: 0}
+	}
+
+	return protocol.Position{Line: len(lines) - 1, Column: encodedColumn(lastLine, len(lastLine), encoding)}
+}
+
+// diffLines returns the hunks of lines which differ between the original and updated lines, in order.
+// Uses the Myers diff algorithm, after trimming the lines common to the start and end of both. Also used to
+// diff the characters of texts, with each character given as a line.
+func diffLines(original []string, updated []string) []lineHunk {
+	// Trim the common prefix and suffix.
+	start := 0
+	for start < len(original) && start < len(updated) && original[start] == updated[start] {
+		start++
+	}
+
+	originalEnd := len(original)
+	updatedEnd := len(updated)
+	for originalEnd > start && updatedEnd > start && original[originalEnd-1] == updated[updatedEnd-1] {
+		originalEnd--
+		updatedEnd--
+	}
+
+	a := original[start:originalEnd]
+	b := updated[start:updatedEnd]
+	if len(a) == 0 && len(b) == 0 {
+		return []lineHunk{}
+	}
+
+	// Run the forward pass, recording the furthest reaching x for each diagonal k at each edit distance d.
+	// trace[d] holds the state before step d, for diagonals [-(d+1), d+1], offset by d+1.
+	n, m := len(a), len(b)
+	max := n + m
+	v := make([]int, 2*max+3)
+	offset := max + 1
+	trace := [][]int{}
+
+	for d := 0; d <= max; d++ {
+		snapshot := make([]int, 2*d+3)
+		copy(snapshot, v[offset-d-1:offset+d+2])
+		trace = append(trace, snapshot)
+
+		found := false
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+
+		if found {
+			break
+		}
+	}
+
+	// Backtrack to find which lines of each side are kept, removed or inserted.
+	originalKept := make([]bool, n)
+	updatedKept := make([]bool, m)
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		snapshot := trace[d]
+		k := x - y
+
+		var previousK int
+		if k == -d || (k != d && snapshot[k-1+d+1] < snapshot[k+1+d+1]) {
+			previousK = k + 1
+		} else {
+			previousK = k - 1
+		}
+
+		previousX := snapshot[previousK+d+1]
+		previousY := previousX - previousK
+		for x > previousX && y > previousY {
+			originalKept[x-1] = true
+			updatedKept[y-1] = true
+			x--
+			y--
+		}
+
+		if d > 0 {
+			x, y = previousX, previousY
+		}
+	}
+
+	// Walk both sides in order, grouping the runs of differing lines into hunks.
+	hunks := []lineHunk{}
+	i, j := 0, 0
+	for i < n || j < m {
+		if i < n && originalKept[i] && j < m && updatedKept[j] {
+			i++
+			j++
+			continue
+		}
+
+		hunk := lineHunk{originalStart: start + i, updatedStart: start + j}
+		for i < n && !originalKept[i] {
+			i++
+		}
+
+		for j < m && !updatedKept[j] {
+			j++
+		}
+
+		hunk.originalEnd = start + i
+		hunk.updatedEnd = start + j
+		hunks = append(hunks, hunk)
+	}
+
+	return hunks
+}
@@ -0,0 +1,214 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+type textEditsTest struct {
+	name     string
+	original string
+	updated  string
+	expected []protocol.TextEdit
+}
+
+func textEdit(startLine int, startColumn int, endLine int, endColumn int, newText string) protocol.TextEdit {
+	return protocol.TextEdit{
+		Range: protocol.Range{
+			Start: protocol.Position{Line: startLine, Column: startColumn},
+			End:   protocol.Position{Line: endLine, Column: endColumn},
+		},
+		NewText: newText,
+	}
+}
+
+var textEditsTests = []textEditsTest{
+	{"no changes", "a\nb\nc\n", "a\nb\nc\n", []protocol.TextEdit{}},
+	{"both empty", "", "", []protocol.TextEdit{}},
+
+	{"changed characters in line", "var a = 1\nvar b = 2\n", "var a = 1\nvar bc = 2\n", []protocol.TextEdit{
+		textEdit(1, 5, 1, 5, "c"),
+	}},
+
+	{"appended to line", "a\nb\n", "a\nbc\n", []protocol.TextEdit{
+		textEdit(1, 1, 1, 1, "c"),
+	}},
+
+	{"removed from end of line", "a\nbc\n", "a\nb\n", []protocol.TextEdit{
+		textEdit(1, 1, 1, 2, ""),
+	}},
+
+	{"inserted line", "a\nc\n", "a\nb\nc\n", []protocol.TextEdit{
+		textEdit(1, 0, 1, 0, "b\n"),
+	}},
+
+	{"removed line", "a\nb\nc\n", "a\nc\n", []protocol.TextEdit{
+		textEdit(1, 0, 2, 0, ""),
+	}},
+
+	{"separate hunks", "a\nb\nc\nd\ne\n", "a\nB\nc\nd\nE\n", []protocol.TextEdit{
+		textEdit(1, 0, 1, 1, "B"),
+		textEdit(4, 0, 4, 1, "E"),
+	}},
+
+	{"appended to unterminated last line", "a\nb", "a\nb\nc", []protocol.TextEdit{
+		textEdit(1, 0, 1, 1, "b\nc"),
+	}},
+
+	{"removed trailing newline", "a\nb\n", "a\nb", []protocol.TextEdit{
+		textEdit(1, 0, 2, 0, "b"),
+	}},
+
+	{"from empty", "", "a\nb\n", []protocol.TextEdit{
+		textEdit(0, 0, 0, 0, "a\nb\n"),
+	}},
+
+	{"to empty", "a\nb\n", "", []protocol.TextEdit{
+		textEdit(0, 0, 2, 0, ""),
+	}},
+
+	{"changed character after surrogate pair", "'😀a'\n", "'😀b'\n", []protocol.TextEdit{
+		textEdit(0, 3, 0, 4, "b"),
+	}},
+
+	{"changed line terminator", "a\r\nb\r\n", "a\nb\r\n", []protocol.TextEdit{
+		textEdit(0, 0, 1, 0, "a\n"),
+	}},
+
+	{"changed character before carriage return", "ab\r\n", "ac\r\n", []protocol.TextEdit{
+		textEdit(0, 1, 0, 2, "c"),
+	}},
+
+	{"changed character sharing leading byte", "'é'\n", "'è'\n", []protocol.TextEdit{
+		textEdit(0, 1, 0, 2, "è"),
+	}},
+}
+
+// applyTextEdits applies the given non-overlapping, ordered edits to the given contents.
+func applyTextEdits(t *testing.T, contents string, edits []protocol.TextEdit) string {
+	changes := make([]protocol.TextDocumentContentChangeEvent, 0, len(edits))
+	for index := len(edits) - 1; index >= 0; index-- {
+		editRange := edits[index].Range
+		changes = append(changes, protocol.TextDocumentContentChangeEvent{
+			Range: &editRange,
+			Text:  edits[index].NewText,
+		})
+	}
+
+	updated, err := applyContentChanges(contents, changes, protocol.PositionEncodingUTF16)
+	if err != nil {
+		t.Fatalf("Could not apply edits: %v", err)
+	}
+	return updated
+}
+
+func TestComputeTextEdits(t *testing.T) {
+	for _, test := range textEditsTests {
+		edits := computeTextEdits(test.original, test.updated, protocol.PositionEncodingUTF16)
+		if !reflect.DeepEqual(edits, test.expected) {
+			t.Errorf("%s: expected edits %v, found %v", test.name, test.expected, edits)
+		}
+
+		if updated := applyTextEdits(t, test.original, edits); updated != test.updated {
+			t.Errorf("%s: expected edits to produce %q, found %q", test.name, test.updated, updated)
+		}
+	}
+}
+
+var textEditsRoundTripTests = []struct {
+	original string
+	updated  string
+}{
+	{"a\nb\nc\nd\n", "d\nc\nb\na\n"},
+	{"a\nb\na\nb\n", "b\na\nb\na\n"},
+	{"function f() {\n  return 1\n}\n", "function f() {\n\treturn 1;\n}\n\nfunction g() {}\n"},
+	{"x\r\ny\r\n", "x\ny\n"},
+	{"a\nb\nc\n", "a\nb\nc"},
+	{"one\ntwo\nthree\nfour\nfive\n", "zero\none\nthree\nfour!\nsix\n"},
+}
+
+func TestComputeTextEditsRoundTrip(t *testing.T) {
+	for _, test := range textEditsRoundTripTests {
+		edits := computeTextEdits(test.original, test.updated, protocol.PositionEncodingUTF16)
+		if updated := applyTextEdits(t, test.original, edits); updated != test.updated {
+			t.Errorf("Expected edits of %q to produce %q, found %q", test.original, test.updated, updated)
+		}
+	}
+}
+
+type diffLinesTest struct {
+	name     string
+	original []string
+	updated  []string
+	expected []lineHunk
+}
+
+var diffLinesTests = []diffLinesTest{
+	{"equal", []string{"a", "b"}, []string{"a", "b"}, []lineHunk{}},
+	{"insertion", []string{"a", "c"}, []string{"a", "b", "c"}, []lineHunk{{1, 1, 1, 2}}},
+	{"deletion", []string{"a", "b", "c"}, []string{"a", "c"}, []lineHunk{{1, 2, 1, 1}}},
+	{"replacement", []string{"a", "b", "c"}, []string{"a", "x", "c"}, []lineHunk{{1, 2, 1, 2}}},
+	{"minimal around moved line", []string{"a", "b", "c", "d"}, []string{"b", "c", "d", "a"}, []lineHunk{{0, 1, 0, 0}, {4, 4, 3, 4}}},
+}
+
+func TestDiffLines(t *testing.T) {
+	for _, test := range diffLinesTests {
+		hunks := diffLines(test.original, test.updated)
+		if !reflect.DeepEqual(hunks, test.expected) {
+			t.Errorf("%s: expected hunks %v, found %v", test.name, test.expected, hunks)
+		}
+	}
+}
+
+type clipTextEditsTest struct {
+	name      string
+	contents  string
+	updated   string
+	clipRange protocol.Range
+	expected  string
+}
+
+var clipTextEditsTests = []clipTextEditsTest{
+	{"edits within range", "a  =  1\nb  =  2\n", "a = 1\nb = 2\n", *changeRange(0, 0, 2, 0), "a = 1\nb = 2\n"},
+	{"edits outside range", "a  =  1\nb  =  2\n", "a = 1\nb = 2\n", *changeRange(1, 0, 2, 0), "a  =  1\nb = 2\n"},
+	{"edit crossing range start", "a  =  1\n", "a = 1\n", *changeRange(0, 3, 0, 7), "a  = 1\n"},
+	{"edit crossing range end", "a  =  1\n", "a = 1\n", *changeRange(0, 0, 0, 3), "a =  1\n"},
+	{"edit crossing both boundaries", "a  =  1  +  2\n", "a = 1 + 2\n", *changeRange(0, 4, 0, 9), "a  = 1 +  2\n"},
+	{"line hunk crossing range start", "if x {\n  a()\n  b()\n}\n", "if x {\n\ta()\n\tb()\n\tc()\n}\n", *changeRange(2, 0, 4, 0), "if x {\n  a()\n\tb()\n\tc()\n}\n"},
+	{"insertion at range boundary", "ab\n", "aXb\n", *changeRange(0, 1, 0, 2), "aXb\n"},
+	{"removal touching range boundary", "aXb\n", "ab\n", *changeRange(0, 2, 0, 3), "aXb\n"},
+	{"empty range", "a  =  1\n", "a = 1\n", *changeRange(0, 2, 0, 2), "a  =  1\n"},
+}
+
+func TestClipTextEdits(t *testing.T) {
+	for _, test := range clipTextEditsTests {
+		edits := computeTextEdits(test.contents, test.updated, protocol.PositionEncodingUTF16)
+		clipped := clipTextEdits(test.contents, edits, test.clipRange, protocol.PositionEncodingUTF16)
+
+		for _, edit := range clipped {
+			if comparePositions(edit.Range.Start, test.clipRange.Start) < 0 || comparePositions(edit.Range.End, test.clipRange.End) > 0 {
+				t.Errorf("%s: expected edit %v to be found within the range", test.name, edit)
+			}
+		}
+
+		if updated := applyTextEdits(t, test.contents, clipped); updated != test.expected {
+			t.Errorf("%s: expected clipped edits to produce %q, found %q", test.name, test.expected, updated)
+		}
+	}
+}
+
+// comparePositions returns a negative value if the first position is before the second, a positive value
+// if it is after the second and zero if they are the same position.
+func comparePositions(first protocol.Position, second protocol.Position) int {
+	if first.Line != second.Line {
+		return first.Line - second.Line
+	}
+
+	return first.Column - second.Column
+}
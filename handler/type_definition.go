@@ -0,0 +1,35 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"github.com/serulian/compiler/compilercommon"
+	"github.com/serulian/compiler/grok"
+)
+
+// typeDefinition returns the source ranges of the declaration of the type of the entity or expression found by
+// the given range information, which was looked up in the document with the given URI. Nullable types and
+// generic instantiations are unwrapped to their base type.
+func (dt *documentTracker) typeDefinition(uri string, target grok.RangeInformation) ([]compilercommon.SourceRange, error) {
+	handle, err := dt.getGrokHandle(uri, grok.HandleMustBeFresh)
+	if err != nil {
+		return []compilercommon.SourceRange{}, err
+	}
+
+	typeRef, found := dt.typeOfRange(handle, target)
+	if !found {
+		return []compilercommon.SourceRange{}, nil
+	}
+
+	if typeRef.IsNullable() {
+		typeRef = typeRef.AsNonNullable()
+	}
+
+	if !typeRef.IsNormal() {
+		return []compilercommon.SourceRange{}, nil
+	}
+
+	return typeRef.ReferredType().SourceRanges(), nil
+}
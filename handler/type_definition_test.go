@@ -0,0 +1,57 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+const typeDefinitionSource = `class Person {}
+
+class Box<T> {}
+
+function Make(person Person?, box Box<Person>) Person? {
+	var copy = person
+	var other = box
+	return copy
+}
+
+function Nothing() {}
+`
+
+func TestTypeDefinition(t *testing.T) {
+	ts := newTestServer(t, map[string]string{"main.seru": typeDefinitionSource})
+	defer ts.close()
+	ts.open("main.seru")
+
+	tests := []struct {
+		name       string
+		text       string
+		occurrence int
+		expected   []string
+	}{
+		{"function returning nullable type", "Make", 0, []string{"main.seru:0:0"}},
+		{"declared nullable parameter", "person", 0, []string{"main.seru:0:0"}},
+		{"declared generic parameter", "box", 0, []string{"main.seru:2:0"}},
+		{"inferred variable", "copy", 0, []string{"main.seru:0:0"}},
+		{"parameter in expression", "person", 1, []string{"main.seru:0:0"}},
+		{"generic parameter in expression", "box", 1, []string{"main.seru:2:0"}},
+		{"function without return type", "Nothing", 0, []string{}},
+	}
+
+	for _, test := range tests {
+		locations := []protocol.Location{}
+		ts.call(protocol.TypeDefinitionRequest, protocol.TypeDefinitionParams{
+			TextDocumentPositionParams: ts.position("main.seru", test.text, test.occurrence, 0),
+		}, &locations)
+
+		if described := ts.describeLocations(locations); !reflect.DeepEqual(described, test.expected) {
+			t.Errorf("%s: expected type definitions %v, found %v", test.name, test.expected, described)
+		}
+	}
+}
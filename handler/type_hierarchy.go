@@ -0,0 +1,273 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"github.com/serulian/compiler/compilercommon"
+	"github.com/serulian/compiler/graphs/typegraph"
+	"github.com/serulian/compiler/grok"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+// prepareTypeHierarchy returns the type hierarchy item for the type found by the given range information, which
+// was looked up in the document with the given URI. Returns false if the range does not refer to a type.
+func (dt *documentTracker) prepareTypeHierarchy(uri string, target grok.RangeInformation) (protocol.TypeHierarchyItem, bool, error) {
+	if len(target.SourceRanges) == 0 {
+		return protocol.TypeHierarchyItem{}, false, nil
+	}
+
+	handle, err := dt.hierarchyHandle(uri, target.SourceRanges[0].Source())
+	if err != nil {
+		return protocol.TypeHierarchyItem{}, false, err
+	}
+
+	typeDecl, found := typeForRange(handle, target)
+	if !found {
+		return protocol.TypeHierarchyItem{}, false, nil
+	}
+
+	return dt.typeHierarchyItem(uri, typeDecl)
+}
+
+// supertypes returns the types from which the type represented by the given type hierarchy item derives: the
+// types on which a nominal type is based, the agents composed by the type and the implicit interfaces which the
+// type structurally satisfies.
+func (dt *documentTracker) supertypes(item protocol.TypeHierarchyItem, cancelationHandle *CancelationHandle) ([]protocol.TypeHierarchyItem, error) {
+	uri, handle, typeDecl, found, err := dt.typeForHierarchyItem(item)
+	if err != nil || !found {
+		return []protocol.TypeHierarchyItem{}, err
+	}
+
+	supertypes := []typegraph.TGTypeDecl{}
+	for _, parentType := range typeDecl.ParentTypes() {
+		if parentType.IsNormal() {
+			supertypes = append(supertypes, parentType.ReferredType())
+		}
+	}
+
+	for _, agent := range typeDecl.ComposedAgents() {
+		if agentType := agent.AgentType(); agentType.IsNormal() {
+			supertypes = append(supertypes, agentType.ReferredType())
+		}
+	}
+
+	for _, otherType := range hierarchyTypes(handle) {
+		if cancelationHandle.WasCanceled() {
+			return []protocol.TypeHierarchyItem{}, cancelationHandle.Error()
+		}
+
+		if satisfiesInterface(typeDecl, otherType) {
+			supertypes = append(supertypes, otherType)
+		}
+	}
+
+	return dt.typeHierarchyItems(uri, supertypes), nil
+}
+
+// subtypes returns the types deriving from the type represented by the given type hierarchy item: the nominal
+// types based on the type, the types composing the type if it is an agent and the types which structurally
+// satisfy the type if it is an implicit interface.
+func (dt *documentTracker) subtypes(item protocol.TypeHierarchyItem, cancelationHandle *CancelationHandle) ([]protocol.TypeHierarchyItem, error) {
+	uri, handle, typeDecl, found, err := dt.typeForHierarchyItem(item)
+	if err != nil || !found {
+		return []protocol.TypeHierarchyItem{}, err
+	}
+
+	typeRef := typeDecl.GetTypeReference()
+	subtypes := []typegraph.TGTypeDecl{}
+	for _, otherType := range hierarchyTypes(handle) {
+		if cancelationHandle.WasCanceled() {
+			return []protocol.TypeHierarchyItem{}, cancelationHandle.Error()
+		}
+
+		if satisfiesInterface(otherType, typeDecl) {
+			subtypes = append(subtypes, otherType)
+			continue
+		}
+
+		isSubtype := false
+		for _, parentType := range otherType.ParentTypes() {
+			isSubtype = isSubtype || (parentType.IsNormal() && parentType.ReferredType().GlobalUniqueId() == typeDecl.GlobalUniqueId())
+		}
+
+		if typeDecl.TypeKind() == typegraph.AgentType && typeRef.IsRefToAgent() {
+			isSubtype = isSubtype || otherType.ComposesAgent(typeRef)
+		}
+
+		if isSubtype {
+			subtypes = append(subtypes, otherType)
+		}
+	}
+
+	return dt.typeHierarchyItems(uri, subtypes), nil
+}
+
+// typeForHierarchyItem returns the type represented by the given type hierarchy item, along with the URI of the
+// document from which the hierarchy was requested and the Grok handle to use.
+func (dt *documentTracker) typeForHierarchyItem(item protocol.TypeHierarchyItem) (string, grok.Handle, typegraph.TGTypeDecl, bool, error) {
+	uri, handle, source, err := dt.hierarchyItemHandle(item.URI, item.Data)
+	if err != nil {
+		return "", grok.Handle{}, typegraph.TGTypeDecl{}, false, err
+	}
+
+	column, err := dt.compilerColumn(source, item.SelectionRange.Start)
+	if err != nil {
+		return "", grok.Handle{}, typegraph.TGTypeDecl{}, false, err
+	}
+
+	rangeInfo, err := handle.LookupPosition(source, item.SelectionRange.Start.Line, column)
+	if err != nil {
+		return "", grok.Handle{}, typegraph.TGTypeDecl{}, false, err
+	}
+
+	typeDecl, found := typeForRange(handle, rangeInfo)
+	return uri, handle, typeDecl, found, nil
+}
+
+// typeHierarchyItems returns the type hierarchy items for the given types, skipping duplicates and those
+// without source.
+func (dt *documentTracker) typeHierarchyItems(uri string, typeDecls []typegraph.TGTypeDecl) []protocol.TypeHierarchyItem {
+	encountered := map[string]bool{}
+	items := []protocol.TypeHierarchyItem{}
+	for _, typeDecl := range typeDecls {
+		if encountered[typeDecl.GlobalUniqueId()] {
+			continue
+		}
+
+		encountered[typeDecl.GlobalUniqueId()] = true
+		if item, ok, err := dt.typeHierarchyItem(uri, typeDecl); err == nil && ok {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// typeHierarchyItem returns the type hierarchy item for the given type, recording the URI of the document from
+// which the hierarchy was requested. Returns false if the type has no source.
+func (dt *documentTracker) typeHierarchyItem(uri string, typeDecl typegraph.TGTypeDecl) (protocol.TypeHierarchyItem, bool, error) {
+	sourceRanges := typeDecl.SourceRanges()
+	if len(sourceRanges) == 0 {
+		return protocol.TypeHierarchyItem{}, false, nil
+	}
+
+	source := sourceRanges[0].Source()
+	contents, err := dt.LoadSourceFile(string(source))
+	if err != nil {
+		return protocol.TypeHierarchyItem{}, false, err
+	}
+
+	documentSymbol, ok := dt.declarationDocumentSymbol(typeDecl.Name(), sourceRanges, source, contents)
+	if !ok {
+		return protocol.TypeHierarchyItem{}, false, nil
+	}
+
+	itemURI, ok := dt.sourceToURI(source)
+	if !ok {
+		return protocol.TypeHierarchyItem{}, false, nil
+	}
+
+	return protocol.TypeHierarchyItem{
+		Name:           typeDecl.Name(),
+		Kind:           symbolKindForType(typeDecl),
+		Detail:         typeDetail(typeDecl),
+		URI:            itemURI,
+		Range:          documentSymbol.Range,
+		SelectionRange: documentSymbol.SelectionRange,
+		Data: map[string]interface{}{
+			"uri": uri,
+		},
+	}, true, nil
+}
+
+// typeForRange returns the type referred to by the given range information, if any.
+func typeForRange(handle grok.Handle, rangeInfo grok.RangeInformation) (typegraph.TGTypeDecl, bool) {
+	switch rangeInfo.Kind {
+	case grok.TypeRef:
+		if rangeInfo.TypeReference.IsNormal() {
+			return rangeInfo.TypeReference.ReferredType(), true
+		}
+
+	case grok.NamedReference:
+		if typeDecl, isType := rangeInfo.NamedReference.Type(); isType {
+			return typeDecl, true
+		}
+
+		// A lookup on a declaration refers to its definition in source, so find the type declared there.
+		if len(rangeInfo.SourceRanges) > 0 {
+			startRune, err := rangeInfo.SourceRanges[0].Start().RunePosition()
+			if err == nil {
+				return typeDeclaredAt(handle, rangeInfo.SourceRanges[0].Source(), startRune)
+			}
+		}
+	}
+
+	return typegraph.TGTypeDecl{}, false
+}
+
+// typeDeclaredAt returns the type whose declaration starts at the given rune position in the given source,
+// if any.
+func typeDeclaredAt(handle grok.Handle, source compilercommon.InputSource, startRune int) (typegraph.TGTypeDecl, bool) {
+	module, found, err := moduleForSource(handle, source)
+	if err != nil || !found {
+		return typegraph.TGTypeDecl{}, false
+	}
+
+	for _, typeDecl := range module.Types() {
+		for _, sourceRange := range typeDecl.SourceRanges() {
+			typeStart, err := sourceRange.Start().RunePosition()
+			if err == nil && sourceRange.Source() == source && typeStart == startRune {
+				return typeDecl, true
+			}
+		}
+	}
+
+	return typegraph.TGTypeDecl{}, false
+}
+
+// hierarchyTypes returns all the types known to the given Grok handle which can be found in a type hierarchy.
+// Generics and aliases are not included.
+func hierarchyTypes(handle grok.Handle) []typegraph.TGTypeDecl {
+	symbols, err := handle.FindSymbols("")
+	if err != nil {
+		return []typegraph.TGTypeDecl{}
+	}
+
+	typeDecls := []typegraph.TGTypeDecl{}
+	for _, symbol := range symbols {
+		if symbol.Kind != grok.TypeSymbol {
+			continue
+		}
+
+		switch symbol.Type.TypeKind() {
+		case typegraph.GenericType, typegraph.AliasType:
+			continue
+		}
+
+		typeDecls = append(typeDecls, *symbol.Type)
+	}
+
+	return typeDecls
+}
+
+// satisfiesInterface returns true if the given type structurally satisfies the given implicit interface. As
+// generic interfaces cannot be checked without knowing their type arguments, they are never satisfied.
+func satisfiesInterface(typeDecl typegraph.TGTypeDecl, interfaceDecl typegraph.TGTypeDecl) bool {
+	if interfaceDecl.TypeKind() != typegraph.ImplicitInterfaceType || interfaceDecl.HasGenerics() {
+		return false
+	}
+
+	if typeDecl.GlobalUniqueId() == interfaceDecl.GlobalUniqueId() {
+		return false
+	}
+
+	switch typeDecl.TypeKind() {
+	case typegraph.GenericType, typegraph.AliasType:
+		return false
+	}
+
+	return typeDecl.GetTypeReference().CheckSubTypeOf(interfaceDecl.GetTypeReference()) == nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+const typeHierarchySource = `interface Greeter {
+	function Greet() string
+}
+
+class Person {
+	function Greet() string {
+		return 'hello'
+	}
+}
+
+type Wrapper : Person {}
+
+function DoSomething() {}
+`
+
+// typeHierarchyItem prepares the type hierarchy at the given occurrence of the given text in main.seru, failing
+// the test unless a single item is returned.
+func (ts *testServer) typeHierarchyItem(text string, occurrence int) protocol.TypeHierarchyItem {
+	items := []protocol.TypeHierarchyItem{}
+	ts.call(protocol.PrepareTypeHierarchyRequest, protocol.TypeHierarchyPrepareParams{
+		TextDocumentPositionParams: ts.position("main.seru", text, occurrence, 0),
+	}, &items)
+
+	if len(items) != 1 {
+		ts.t.Fatalf("Expected a type hierarchy item for %s, found %v", text, items)
+	}
+
+	return items[0]
+}
+
+// typeHierarchy returns the sorted names of the types returned by the given type hierarchy method for the given
+// item, skipping those outside of the workspace, such as the types of the core library.
+func (ts *testServer) typeHierarchy(method string, item protocol.TypeHierarchyItem) []string {
+	// The parameters of the supertypes and subtypes requests have the same shape.
+	items := []protocol.TypeHierarchyItem{}
+	ts.call(method, protocol.TypeHierarchySupertypesParams{Item: item}, &items)
+
+	names := []string{}
+	for _, found := range items {
+		if found.URI.String() == ts.uri("main.seru") {
+			names = append(names, found.Name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func TestTypeHierarchy(t *testing.T) {
+	ts := newTestServer(t, map[string]string{"main.seru": typeHierarchySource})
+	defer ts.close()
+	ts.open("main.seru")
+
+	person := ts.typeHierarchyItem("Person", 0)
+	if person.Name != "Person" || person.Kind != protocol.SymbolClass {
+		t.Errorf("Expected a class item for Person, found %v", person)
+	}
+
+	if described := ts.describeLocation(person.URI, person.SelectionRange); described != "main.seru:4:6" {
+		t.Errorf("Expected the item to select the name of Person, found %s", described)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		item     protocol.TypeHierarchyItem
+		expected []string
+	}{
+		{"supertypes of class", protocol.TypeHierarchySupertypesRequest, person, []string{"Greeter"}},
+		{"subtypes of class", protocol.TypeHierarchySubtypesRequest, person, []string{"Wrapper"}},
+		{"supertypes of nominal type", protocol.TypeHierarchySupertypesRequest, ts.typeHierarchyItem("Wrapper", 0), []string{"Person"}},
+		{"subtypes of interface", protocol.TypeHierarchySubtypesRequest, ts.typeHierarchyItem("Greeter", 0), []string{"Person"}},
+	}
+
+	for _, test := range tests {
+		if found := ts.typeHierarchy(test.method, test.item); !reflect.DeepEqual(found, test.expected) {
+			t.Errorf("%s: expected %v, found %v", test.name, test.expected, found)
+		}
+	}
+}
+
+func TestPrepareTypeHierarchyNotType(t *testing.T) {
+	ts := newTestServer(t, map[string]string{"main.seru": typeHierarchySource})
+	defer ts.close()
+	ts.open("main.seru")
+
+	items := []protocol.TypeHierarchyItem{}
+	ts.call(protocol.PrepareTypeHierarchyRequest, protocol.TypeHierarchyPrepareParams{
+		TextDocumentPositionParams: ts.position("main.seru", "DoSomething", 0, 0),
+	}, &items)
+
+	if len(items) != 0 {
+		t.Errorf("Expected no type hierarchy item for a function, found %v", items)
+	}
+}
@@ -5,31 +5,61 @@
 package handler
 
 import (
-	"sync/atomic"
+	"sync"
 	"time"
 )
 
-// debounce performs debouncing of the given function, invoking after the given interval
-// has completed and no additional inputs have occurred during that time.
+// debounce performs debouncing of the given function, invoking after the interval returned by the
+// given function has completed and no additional inputs have occurred during that time. The interval
+// is retrieved on each input, so that it may change over time. Also returns a flush
+// function, which immediately invokes the function with the pending input (if any), rather
+// than waiting for the interval to complete.
 // Inspired by: https://nathanleclaire.com/blog/2014/08/03/write-a-function-similar-to-underscore-dot-jss-debounce-in-golang/
-func debounce(f func(data interface{}), interval time.Duration) func(data interface{}) {
-	var addVersion uint32
+func debounce(f func(data interface{}), interval func() time.Duration) (func(data interface{}), func()) {
+	var lock sync.Mutex
+	var addVersion uint64
+	var pendingData interface{}
+	var hasPending bool
 
-	checkAndWait := func(checkVersion uint32, data interface{}) {
-		currentVersion := atomic.LoadUint32(&addVersion)
-		if currentVersion != checkVersion {
-			return
+	// takePending returns the pending input, if any, and clears it. If checkVersion is non-zero, the
+	// pending input is only returned if no additional inputs have occurred since that version.
+	takePending := func(checkVersion uint64) (interface{}, bool) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if !hasPending || (checkVersion != 0 && checkVersion != addVersion) {
+			return nil, false
 		}
 
-		<-time.After(interval)
-		currentVersion = atomic.LoadUint32(&addVersion)
-		if currentVersion == checkVersion {
+		data := pendingData
+		pendingData = nil
+		hasPending = false
+		return data, true
+	}
+
+	checkAndWait := func(checkVersion uint64, wait time.Duration) {
+		<-time.After(wait)
+		if data, ok := takePending(checkVersion); ok {
 			f(data)
 		}
 	}
 
-	return func(data interface{}) {
-		version := atomic.AddUint32(&addVersion, 1)
-		go checkAndWait(version, data)
+	call := func(data interface{}) {
+		lock.Lock()
+		addVersion++
+		version := addVersion
+		pendingData = data
+		hasPending = true
+		lock.Unlock()
+
+		go checkAndWait(version, interval())
 	}
+
+	flush := func() {
+		if data, ok := takePending(0); ok {
+			f(data)
+		}
+	}
+
+	return call, flush
 }
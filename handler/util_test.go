@@ -0,0 +1,136 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// debounceRecorder records the inputs with which a debounced function is invoked.
+type debounceRecorder struct {
+	lock   sync.Mutex
+	inputs []interface{}
+	called chan struct{}
+}
+
+func newDebounceRecorder() *debounceRecorder {
+	return &debounceRecorder{called: make(chan struct{}, 16)}
+}
+
+func (r *debounceRecorder) record(data interface{}) {
+	r.lock.Lock()
+	r.inputs = append(r.inputs, data)
+	r.lock.Unlock()
+	r.called <- struct{}{}
+}
+
+func (r *debounceRecorder) recorded() []interface{} {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]interface{}{}, r.inputs...)
+}
+
+func fixedInterval(interval time.Duration) func() time.Duration {
+	return func() time.Duration { return interval }
+}
+
+func TestDebounceInvokesWithLatestInput(t *testing.T) {
+	recorder := newDebounceRecorder()
+	call, _ := debounce(recorder.record, fixedInterval(10*time.Millisecond))
+
+	call(1)
+	call(2)
+	call(3)
+
+	select {
+	case <-recorder.called:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected debounced function to be invoked")
+	}
+
+	// Wait for any additional (unexpected) invocations.
+	time.Sleep(50 * time.Millisecond)
+
+	inputs := recorder.recorded()
+	if len(inputs) != 1 || inputs[0] != 3 {
+		t.Errorf("Expected a single invocation with the latest input, found %v", inputs)
+	}
+}
+
+func TestDebounceFlush(t *testing.T) {
+	recorder := newDebounceRecorder()
+	call, flush := debounce(recorder.record, fixedInterval(time.Hour))
+
+	call(1)
+	call(2)
+	flush()
+
+	inputs := recorder.recorded()
+	if len(inputs) != 1 || inputs[0] != 2 {
+		t.Errorf("Expected flush to invoke with the latest input, found %v", inputs)
+	}
+
+	// A second flush has nothing pending.
+	flush()
+	if inputs := recorder.recorded(); len(inputs) != 1 {
+		t.Errorf("Expected flush without pending input to not invoke, found %v", inputs)
+	}
+}
+
+func TestDebounceFlushWithoutInput(t *testing.T) {
+	recorder := newDebounceRecorder()
+	_, flush := debounce(recorder.record, fixedInterval(time.Millisecond))
+
+	flush()
+	if inputs := recorder.recorded(); len(inputs) != 0 {
+		t.Errorf("Expected flush without input to not invoke, found %v", inputs)
+	}
+}
+
+func TestDebounceFlushCancelsPendingInvocation(t *testing.T) {
+	recorder := newDebounceRecorder()
+	call, flush := debounce(recorder.record, fixedInterval(10*time.Millisecond))
+
+	call(1)
+	flush()
+
+	// The invocation scheduled by the call must not occur, as its input was flushed.
+	time.Sleep(50 * time.Millisecond)
+	if inputs := recorder.recorded(); len(inputs) != 1 || inputs[0] != 1 {
+		t.Errorf("Expected a single invocation, found %v", inputs)
+	}
+}
+
+func TestDebounceIntervalRetrievedPerInput(t *testing.T) {
+	recorder := newDebounceRecorder()
+
+	var intervalLock sync.Mutex
+	interval := time.Hour
+	call, _ := debounce(recorder.record, func() time.Duration {
+		intervalLock.Lock()
+		defer intervalLock.Unlock()
+		return interval
+	})
+
+	call(1)
+
+	intervalLock.Lock()
+	interval = 10 * time.Millisecond
+	intervalLock.Unlock()
+
+	call(2)
+
+	select {
+	case <-recorder.called:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected debounced function to be invoked after the changed interval")
+	}
+
+	if inputs := recorder.recorded(); len(inputs) != 1 || inputs[0] != 2 {
+		t.Errorf("Expected a single invocation with the latest input, found %v", inputs)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"context"
+
+	"github.com/serulian/compiler/compilercommon"
+	"github.com/serulian/compiler/grok"
+	"github.com/serulian/compiler/sourceshape"
+
+	"github.com/serulian/serulian-langserver/protocol"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// watchedFilesRegistrationID is the ID under which the file watchers are registered with the client.
+const watchedFilesRegistrationID = "serulian-watched-files"
+
+// watchedFilePatterns are the glob patterns of the files whose changes on disk are watched.
+var watchedFilePatterns = []string{
+	"**/*" + sourceshape.SerulianFileExtension,
+	"**/*" + webIDLFileExtension,
+	"**/" + ProjectConfigFileName,
+}
+
+// watchedFilesRegistration returns the registration asking the client to watch the Serulian and WebIDL files, and
+// project configuration files, found in the workspace, and to notify the server of any changes to them.
+func watchedFilesRegistration() protocol.Registration {
+	watchers := make([]protocol.FileSystemWatcher, 0, len(watchedFilePatterns))
+	for _, pattern := range watchedFilePatterns {
+		watchers = append(watchers, protocol.FileSystemWatcher{GlobPattern: pattern})
+	}
+
+	return protocol.Registration{
+		ID:     watchedFilesRegistrationID,
+		Method: protocol.DidChangeWatchedFilesNotification,
+		RegisterOptions: protocol.DidChangeWatchedFilesRegistrationOptions{
+			Watchers: watchers,
+		},
+	}
+}
+
+// watchedFilesChanged handles the given changes to files on disk. As files that are not open are read from disk,
+// any Groker whose sources include a changed file is replaced, so that its stale handle is discarded, and its
+// diagnostics are recomputed. Open documents are read from the client, so changes to them on disk are ignored.
+// Changes to the project configuration file of a workspace folder reload it.
+func (dt *documentTracker) watchedFilesChanged(ctx context.Context, conn *jsonrpc2.Conn, changes []protocol.FileEvent) {
+	changedSources := []compilercommon.InputSource{}
+	hasCreatedFiles := false
+
+	for _, change := range changes {
+		path, err := dt.uriToPath(change.URI.String())
+		if err != nil || dt.documents.Has(path) {
+			continue
+		}
+
+		if folder, found := dt.projectConfigFolder(path); found {
+			dt.projectConfigChanged(ctx, conn, folder)
+			continue
+		}
+
+		switch change.Type {
+		case protocol.FileCreated:
+			hasCreatedFiles = true
+
+		case protocol.FileDeleted:
+			dt.clearAllDiagnostics(ctx, conn, path)
+		}
+
+		changedSources = append(changedSources, compilercommon.InputSource(path))
+	}
+
+	if len(changedSources) == 0 {
+		return
+	}
+
+	isAffected := func(groker *grok.Groker) bool {
+		// A created file can satisfy any import, so all Grokers are affected.
+		if hasCreatedFiles {
+			return true
+		}
+
+		handle, err := groker.GetHandleWithOption(grok.HandleAllowStale)
+		if err != nil {
+			return true
+		}
+
+		for _, source := range changedSources {
+			if handle.ContainsSource(source) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	dt.replaceGrokers(ctx, conn, isAffected)
+}
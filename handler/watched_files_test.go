@@ -0,0 +1,100 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/serulian/serulian-langserver/protocol"
+)
+
+func TestWatchedFilesRegistration(t *testing.T) {
+	dynamicRegistration := true
+	ts := startTestServer(t, map[string]string{}, protocol.InitializeParams{
+		Capabilities: protocol.ClientCapabilities{
+			Workspace: &protocol.WorkspaceClientCapabilities{
+				DidChangeWatchedFiles: &protocol.DidChangeWatchedFilesClientCapabilities{DynamicRegistration: &dynamicRegistration},
+			},
+		},
+	})
+	defer ts.close()
+
+	var params protocol.RegistrationParams
+	select {
+	case params = <-ts.registrations:
+	case <-time.After(testTimeout):
+		t.Fatalf("Timed out waiting for the server to register capabilities")
+	}
+
+	for _, registration := range params.Registrations {
+		if registration.ID != watchedFilesRegistrationID {
+			continue
+		}
+
+		if registration.Method != protocol.DidChangeWatchedFilesNotification {
+			t.Errorf("Expected the watchers to be registered for %s, found %s", protocol.DidChangeWatchedFilesNotification, registration.Method)
+		}
+
+		// The options are decoded generically, so decode them again into their expected type.
+		encoded, _ := json.Marshal(registration.RegisterOptions)
+		options := protocol.DidChangeWatchedFilesRegistrationOptions{}
+		if err := json.Unmarshal(encoded, &options); err != nil {
+			t.Fatalf("Could not decode registration options: %v", err)
+		}
+
+		patterns := []string{}
+		for _, watcher := range options.Watchers {
+			patterns = append(patterns, watcher.GlobPattern)
+		}
+
+		expected := []string{"**/*.seru", "**/*.webidl", "**/" + ProjectConfigFileName}
+		if !reflect.DeepEqual(patterns, expected) {
+			t.Errorf("Expected watchers for %v, found %v", expected, patterns)
+		}
+		return
+	}
+
+	t.Errorf("Expected the file watchers to be registered, found %v", params.Registrations)
+}
+
+func TestWatchedFileChangeRediagnoses(t *testing.T) {
+	ts := newTestServer(t, map[string]string{
+		"main.seru":  "import other\n\nfunction First() {\n\tother.Second()\n}\n",
+		"other.seru": "function Third() {}\n",
+	})
+	defer ts.close()
+	ts.open("main.seru")
+
+	ts.waitForDiagnostics(ts.uri("main.seru"), hasDiagnostics)
+
+	// Change the unopened imported file on disk, defining the missing function.
+	ts.writeFile("other.seru", "function Second() {}\n")
+	ts.notify(protocol.DidChangeWatchedFilesNotification, protocol.DidChangeWatchedFilesParams{
+		Changes: []protocol.FileEvent{{URI: protocol.DocumentURI(ts.uri("other.seru")), Type: protocol.FileChanged}},
+	})
+
+	ts.waitForDiagnostics(ts.uri("main.seru"), hasClearedDiagnostics)
+}
+
+func TestWatchedFileCreationRediagnoses(t *testing.T) {
+	ts := newTestServer(t, map[string]string{
+		"main.seru": "import other\n\nfunction First() {\n\tother.Second()\n}\n",
+	})
+	defer ts.close()
+	ts.open("main.seru")
+
+	ts.waitForDiagnostics(ts.uri("main.seru"), hasDiagnostics)
+
+	// Create the missing imported file on disk.
+	ts.writeFile("other.seru", "function Second() {}\n")
+	ts.notify(protocol.DidChangeWatchedFilesNotification, protocol.DidChangeWatchedFilesParams{
+		Changes: []protocol.FileEvent{{URI: protocol.DocumentURI(ts.uri("other.seru")), Type: protocol.FileCreated}},
+	})
+
+	ts.waitForDiagnostics(ts.uri("main.seru"), hasClearedDiagnostics)
+}
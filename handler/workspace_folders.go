@@ -0,0 +1,264 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"context"
+	"log"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/serulian/compiler/compilercommon"
+	"github.com/serulian/compiler/grok"
+	"github.com/serulian/compiler/packageloader"
+
+	"github.com/serulian/serulian-langserver/protocol"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// workspaceFolder represents a single root folder of the workspace. Each folder is treated as a separate
+// Serulian project, with its own Groks, VCS package directory, project configuration and diagnostics.
+type workspaceFolder struct {
+	// rootPath is the root path of the folder. May be an entrypoint source file.
+	rootPath string
+
+	// rootDirectory is the directory under which the sources of the folder are found.
+	rootDirectory string
+
+	// config is the last valid project configuration found for the folder, if any.
+	config projectConfig
+
+	// configIssues are the issues found in the project configuration file of the folder when last loaded.
+	configIssues []protocol.Diagnostic
+
+	// settings are the settings of the folder, layered from the command line settings, the settings of its
+	// project configuration and the client settings.
+	settings serverSettings
+
+	// grokers are the Groks over all the sources of the folder, one for each of its entrypoints.
+	grokers []*grok.Groker
+}
+
+// vcsPackageDirectory returns the directory under which the VCS packages of the folder are checked out.
+func (wf workspaceFolder) vcsPackageDirectory() string {
+	return path.Join(wf.rootDirectory, packageloader.SerulianPackageDirectory)
+}
+
+// contains returns true if the given path is found under the folder.
+func (wf workspaceFolder) contains(filePath string) bool {
+	return filePath == wf.rootPath || strings.HasPrefix(filePath, wf.rootDirectory+"/")
+}
+
+// isAffected returns true if isAffected returns true for any of the Grokers of the folder.
+func (wf workspaceFolder) isAffected(isAffected func(groker *grok.Groker) bool) bool {
+	for _, groker := range wf.grokers {
+		if isAffected(groker) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// addWorkspaceFolder starts tracking the workspace folder with the given root path, loading its project
+// configuration file (if any), and diagnoses it. Any issues found in the project configuration file are
+// not reported, as the folder may be added before the server is initialized; reportProjectConfigIssues
+// must be called once it is.
+func (dt *documentTracker) addWorkspaceFolder(ctx context.Context, conn *jsonrpc2.Conn, rootPath string) {
+	rootDirectory := rootPath
+	if dt.IsSourceFile(rootDirectory) {
+		rootDirectory = path.Dir(rootDirectory)
+	}
+
+	folder := workspaceFolder{
+		rootPath:      rootPath,
+		rootDirectory: rootDirectory,
+	}
+
+	// Apply the settings of the project configuration before creating the Grokers, as they are configured by them.
+	folder.config, folder.configIssues, _ = dt.loadProjectConfig(folder)
+	folder.settings = dt.folderSettings(folder.config)
+	dt.workspaceFolders.Set(rootPath, folder)
+
+	folder.grokers = dt.newFolderGrokers(folder)
+	dt.workspaceFolders.Set(rootPath, folder)
+
+	dt.diagnostics.schedule(diagnoseParams{dt, rootPath, -1, true, ctx, conn})
+}
+
+// newFolderGrokers creates a Groker for each of the entrypoints of the given workspace folder.
+func (dt *documentTracker) newFolderGrokers(folder workspaceFolder) []*grok.Groker {
+	entrypointPaths := folder.entrypointPaths()
+	grokers := make([]*grok.Groker, 0, len(entrypointPaths))
+	for _, entrypointPath := range entrypointPaths {
+		grokers = append(grokers, dt.newGroker(entrypointPath, []compilercommon.InputSource{}))
+	}
+	return grokers
+}
+
+// removeWorkspaceFolder stops tracking the workspace folder with the given root path, clearing any diagnostics
+// published by its diagnose.
+func (dt *documentTracker) removeWorkspaceFolder(ctx context.Context, conn *jsonrpc2.Conn, rootPath string) {
+	currentValue, exists := dt.workspaceFolders.Get(rootPath)
+	if !exists {
+		return
+	}
+
+	folder := currentValue.(workspaceFolder)
+	documentPaths := dt.workspaceFolderDocuments(folder)
+	affected := dt.folderGrokers(folder)
+	dt.workspaceFolders.Remove(rootPath)
+	dt.diagnostics.forgetWorkspace(rootPath)
+
+	for _, documentPath := range documentPaths {
+		dt.clearDiagnostics(ctx, conn, documentPath, true)
+	}
+
+	// Clear any errors reported for the project configuration file.
+	dt.clearDiagnostics(ctx, conn, folder.configPath(), true)
+
+	// Replace the Grokers of the documents found in the folder, as they were configured by its settings.
+	dt.replaceGrokers(ctx, conn, func(groker *grok.Groker) bool { return affected[groker] })
+}
+
+// folderGrokers returns the set of the Grokers of the given workspace folder and of the documents found in it,
+// and not in a folder nested under it.
+func (dt *documentTracker) folderGrokers(folder workspaceFolder) map[*grok.Groker]bool {
+	grokers := map[*grok.Groker]bool{}
+	for _, groker := range folder.grokers {
+		grokers[groker] = true
+	}
+
+	for _, value := range dt.documents.Items() {
+		current := value.(document)
+		if current.groker == nil {
+			continue
+		}
+
+		if containingFolder, found := dt.workspaceFolderFor(current.path); found && containingFolder.rootPath == folder.rootPath {
+			grokers[current.groker] = true
+		}
+	}
+
+	return grokers
+}
+
+// getWorkspaceFolders returns all the folders of the workspace, ordered by root path.
+func (dt *documentTracker) getWorkspaceFolders() []workspaceFolder {
+	folders := make([]workspaceFolder, 0, dt.workspaceFolders.Count())
+	for _, value := range dt.workspaceFolders.Items() {
+		folders = append(folders, value.(workspaceFolder))
+	}
+
+	sort.Slice(folders, func(i, j int) bool {
+		return folders[i].rootPath < folders[j].rootPath
+	})
+	return folders
+}
+
+// workspaceFolderFor returns the workspace folder containing the given path, if any. If folders are nested,
+// the innermost folder is returned.
+func (dt *documentTracker) workspaceFolderFor(filePath string) (workspaceFolder, bool) {
+	var found = workspaceFolder{}
+	var hasFound = false
+
+	for _, folder := range dt.getWorkspaceFolders() {
+		if folder.contains(filePath) && (!hasFound || len(folder.rootDirectory) > len(found.rootDirectory)) {
+			found = folder
+			hasFound = true
+		}
+	}
+
+	return found, hasFound
+}
+
+// workspaceFolderDocuments returns the paths of the documents being tracked that are found in the given
+// workspace folder, and not in a folder nested under it, and are included by its project configuration.
+func (dt *documentTracker) workspaceFolderDocuments(folder workspaceFolder) []string {
+	documentPaths := []string{}
+	for _, documentPath := range dt.documents.Keys() {
+		containingFolder, found := dt.workspaceFolderFor(documentPath)
+		if found && containingFolder.rootPath == folder.rootPath && folder.includes(documentPath) {
+			documentPaths = append(documentPaths, documentPath)
+		}
+	}
+	return documentPaths
+}
+
+// workspaceHandle returns a fresh Grok handle of the workspace folder containing the document with the given URI,
+// which contains the given source, if any.
+func (dt *documentTracker) workspaceHandle(uri string, source compilercommon.InputSource) (grok.Handle, bool) {
+	documentPath, err := dt.uriToPath(uri)
+	if err != nil {
+		return grok.Handle{}, false
+	}
+
+	folder, found := dt.workspaceFolderFor(documentPath)
+	if !found {
+		return grok.Handle{}, false
+	}
+
+	// Find the Groker containing the source using the (possibly stale) handles, so that only the handle of
+	// that Groker must be rebuilt.
+	for _, groker := range folder.grokers {
+		staleHandle, err := groker.GetHandleWithOption(grok.HandleAllowStale)
+		if err != nil || !staleHandle.ContainsSource(source) {
+			continue
+		}
+
+		handle, err := groker.GetHandleWithOption(grok.HandleMustBeFresh)
+		if err == nil && handle.ContainsSource(source) {
+			return handle, true
+		}
+	}
+
+	return grok.Handle{}, false
+}
+
+// changeWorkspaceFolders applies the given changes to the folders of the workspace. If an entrypoint source file
+// was specified, it defines the workspace and the changes are ignored.
+func (h *SerulianLangServerHandler) changeWorkspaceFolders(ctx context.Context, conn *jsonrpc2.Conn, event protocol.WorkspaceFoldersChangeEvent) {
+	if h.entrypointSourceFile != "" {
+		log.Printf("Ignoring change to workspace folders, as an entrypoint source file was specified")
+		return
+	}
+
+	dt := h.documentTracker
+	for _, removed := range event.Removed {
+		rootPath, err := dt.uriToPath(removed.URI.String())
+		if err != nil {
+			log.Printf("Error when trying to convert workspace folder URI to a path: %v\n", err)
+			continue
+		}
+
+		if h.fileWatcher != nil {
+			if folder, exists := dt.workspaceFolders.Get(rootPath); exists {
+				h.fileWatcher.unwatchFolder(folder.(workspaceFolder))
+			}
+		}
+
+		dt.removeWorkspaceFolder(ctx, conn, rootPath)
+	}
+
+	for _, added := range event.Added {
+		rootPath, err := dt.uriToPath(added.URI.String())
+		if err != nil {
+			log.Printf("Error when trying to convert workspace folder URI to a path: %v\n", err)
+			continue
+		}
+
+		dt.addWorkspaceFolder(ctx, conn, rootPath)
+
+		folderValue, _ := dt.workspaceFolders.Get(rootPath)
+		folder := folderValue.(workspaceFolder)
+		dt.reportProjectConfigIssues(ctx, conn, folder)
+
+		if h.fileWatcher != nil {
+			h.fileWatcher.watchFolder(folder)
+		}
+	}
+}
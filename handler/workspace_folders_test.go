@@ -0,0 +1,126 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/serulian/serulian-langserver/protocol"
+
+	cmap "github.com/streamrail/concurrent-map"
+)
+
+type workspaceFolderForTest struct {
+	name     string
+	filePath string
+	expected string
+	found    bool
+}
+
+var workspaceFolderForTests = []workspaceFolderForTest{
+	{"file in folder", "/apps/first/main.seru", "/apps/first", true},
+	{"file in nested directory", "/apps/first/lib/lib.seru", "/apps/first", true},
+	{"file in nested folder", "/apps/first/nested/main.seru", "/apps/first/nested", true},
+	{"folder sharing a prefix", "/apps/firstother/main.seru", "", false},
+	{"entrypoint folder", "/apps/second/entrypoint.seru", "/apps/second/entrypoint.seru", true},
+	{"file beside entrypoint", "/apps/second/other.seru", "/apps/second/entrypoint.seru", true},
+	{"file outside folders", "/other/main.seru", "", false},
+}
+
+func TestWorkspaceFolderFor(t *testing.T) {
+	dt := &documentTracker{workspaceFolders: cmap.New()}
+	for _, rootPath := range []string{"/apps/first", "/apps/first/nested"} {
+		dt.workspaceFolders.Set(rootPath, workspaceFolder{rootPath: rootPath, rootDirectory: rootPath})
+	}
+	dt.workspaceFolders.Set("/apps/second/entrypoint.seru", workspaceFolder{
+		rootPath:      "/apps/second/entrypoint.seru",
+		rootDirectory: "/apps/second",
+	})
+
+	for _, test := range workspaceFolderForTests {
+		folder, found := dt.workspaceFolderFor(test.filePath)
+		if found != test.found || folder.rootPath != test.expected {
+			t.Errorf("%s: expected folder %q (found: %v), found %q (found: %v)", test.name, test.expected, test.found, folder.rootPath, found)
+		}
+	}
+}
+
+var workspaceFoldersFiles = map[string]string{
+	"first/main.seru":  "function Broken() {\n\tMissing()\n}\n\nfunction Helper() {}\n",
+	"second/main.seru": "function Helper() {}\n\nfunction Use() {\n\tHelper()\n}\n",
+}
+
+// symbolFiles returns the sorted paths, relative to the root of the workspace, of the files containing the
+// workspace symbols matching the given query.
+func (ts *testServer) symbolFiles(query string) []string {
+	symbols := []protocol.SymbolInformation{}
+	ts.call(protocol.WorkspaceSymbolRequest, protocol.WorkspaceSymbolParams{Query: query}, &symbols)
+
+	files := []string{}
+	for _, symbol := range symbols {
+		if symbol.Name == query {
+			described := ts.describeLocation(symbol.Location.URI, symbol.Location.Range)
+			files = append(files, described[:strings.Index(described, ":")])
+		}
+	}
+
+	sort.Strings(files)
+	return files
+}
+
+func TestWorkspaceFolders(t *testing.T) {
+	ts := startTestServer(t, workspaceFoldersFiles, protocol.InitializeParams{
+		WorkspaceFolders: []protocol.WorkspaceFolder{{URI: "first", Name: "first"}, {URI: "second", Name: "second"}},
+	})
+	defer ts.close()
+	ts.open("first/main.seru")
+	ts.open("second/main.seru")
+
+	// Each folder is its own project, so the definition of a name is found in the folder of the document.
+	locations := []protocol.Location{}
+	ts.call(protocol.DefinitionRequest, protocol.DefinitionParams{
+		TextDocumentPositionParams: ts.position("second/main.seru", "Helper", 1, 0),
+	}, &locations)
+
+	if described := ts.describeLocations(locations); !reflect.DeepEqual(described, []string{"second/main.seru:0:0"}) {
+		t.Errorf("Expected the definition in the second folder, found %v", described)
+	}
+
+	diagnostics := ts.waitForDiagnostics(ts.uri("first/main.seru"), hasDiagnostics)
+	if diagnostics[0].Range.Start.Line != 1 {
+		t.Errorf("Expected an error on line 1 of the first folder, found %v", diagnostics)
+	}
+
+	if files := ts.symbolFiles("Helper"); !reflect.DeepEqual(files, []string{"first/main.seru", "second/main.seru"}) {
+		t.Errorf("Expected symbols from both folders, found %v", files)
+	}
+
+	// Remove the first folder, whose symbols are no longer part of the workspace.
+	ts.notify(protocol.DidChangeWorkspaceFoldersNotification, protocol.DidChangeWorkspaceFoldersParams{
+		Event: protocol.WorkspaceFoldersChangeEvent{
+			Removed: []protocol.WorkspaceFolder{{URI: protocol.DocumentURI(ts.uri("first")), Name: "first"}},
+		},
+	})
+	ts.sync()
+
+	if files := ts.symbolFiles("Helper"); !reflect.DeepEqual(files, []string{"second/main.seru"}) {
+		t.Errorf("Expected symbols from the second folder only, found %v", files)
+	}
+
+	// Add it back.
+	ts.notify(protocol.DidChangeWorkspaceFoldersNotification, protocol.DidChangeWorkspaceFoldersParams{
+		Event: protocol.WorkspaceFoldersChangeEvent{
+			Added: []protocol.WorkspaceFolder{{URI: protocol.DocumentURI(ts.uri("first")), Name: "first"}},
+		},
+	})
+	ts.sync()
+
+	if files := ts.symbolFiles("Helper"); !reflect.DeepEqual(files, []string{"first/main.seru", "second/main.seru"}) {
+		t.Errorf("Expected symbols from both folders, found %v", files)
+	}
+}
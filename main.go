@@ -29,6 +29,8 @@ var (
 	addr                      string
 	entrypointSourceFile      string
 	vcsDevelopmentDirectories []string
+	watchFiles                bool
+	watchIgnorePatterns       []string
 )
 
 func main() {
@@ -50,6 +52,12 @@ func main() {
 	cmdRun.PersistentFlags().StringVar(&entrypointSourceFile, "entrypointSourceFile", "", "The entrypoint source file for the project (optional)")
 	cmdRun.PersistentFlags().StringSliceVar(&vcsDevelopmentDirectories, "vcs-dev-dir", []string{},
 		"If specified, VCS packages without specification will be first checked against this path")
+	cmdRun.PersistentFlags().BoolVar(&watchFiles, "watch-files", false,
+		"If set to true, the language server will watch the workspace for changes to files on disk if the client does not")
+	cmdRun.PersistentFlags().StringSliceVar(&watchIgnorePatterns, "watch-ignore", []string{".git", "node_modules", "build/**", "out/**"},
+		"Glob patterns of the files and directories not watched when watching files. Patterns without a slash match names "+
+			"anywhere (e.g. .git), others match paths relative to the workspace folder (e.g. out/*.seru), and "+
+			"a trailing /** matches a directory and everything under it (e.g. build/**)")
 
 	var cmdVersion = &cobra.Command{
 		Use:   "version",
@@ -97,7 +105,7 @@ func run() error {
 		connOptions = append(connOptions, jsonrpc2.LogMessages(log.New(os.Stderr, "", 0)))
 	}
 
-	handler := handler.NewHandler(entrypointSourceFile, vcsDevelopmentDirectories)
+	handler := handler.NewHandler(entrypointSourceFile, vcsDevelopmentDirectories, watchFiles, watchIgnorePatterns)
 	if mode == stdioMode {
 		log.Printf("Serulian language server running under STDIO mode\n")
 		<-jsonrpc2.NewConn(context.Background(), jsonrpc2.NewBufferedStream(stdrwc{}, jsonrpc2.VSCodeObjectCodec{}), handler, connOptions...).DisconnectNotify()
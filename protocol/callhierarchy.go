@@ -0,0 +1,83 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+// PrepareCallHierarchyRequest defines the name of the prepare call hierarchy method.
+const PrepareCallHierarchyRequest = "textDocument/prepareCallHierarchy"
+
+// CallHierarchyIncomingCallsRequest defines the name of the incoming calls method.
+const CallHierarchyIncomingCallsRequest = "callHierarchy/incomingCalls"
+
+// CallHierarchyOutgoingCallsRequest defines the name of the outgoing calls method.
+const CallHierarchyOutgoingCallsRequest = "callHierarchy/outgoingCalls"
+
+// CallHierarchyPrepareParams defines the parameters for the prepare call hierarchy request.
+type CallHierarchyPrepareParams struct {
+	TextDocumentPositionParams
+}
+
+// CallHierarchyItem represents a callable entity, such as a function or constructor, in the call hierarchy.
+type CallHierarchyItem struct {
+	// Name is the name of the item.
+	Name string `json:"name"`
+
+	// Kind is the kind of the item.
+	Kind SymbolKind `json:"kind"`
+
+	// Detail holds more detail for the item, such as the type containing it.
+	Detail string `json:"detail,omitempty"`
+
+	// URI is the resource identifier of the item.
+	URI DocumentURI `json:"uri"`
+
+	// Range is the range enclosing the item, including its body.
+	Range Range `json:"range"`
+
+	// SelectionRange is the range that should be selected and revealed when the item is being picked,
+	// such as its name. Must be contained by the Range.
+	SelectionRange Range `json:"selectionRange"`
+
+	// Data is data preserved between the prepare call hierarchy and the incoming and outgoing calls requests.
+	Data interface{} `json:"data,omitempty"`
+}
+
+// CallHierarchyIncomingCallsParams defines the parameters for the incoming calls request.
+type CallHierarchyIncomingCallsParams struct {
+	// Item is the item for which to find the incoming calls.
+	Item CallHierarchyItem `json:"item"`
+}
+
+// CallHierarchyIncomingCall represents an item that makes one or more calls to another item.
+type CallHierarchyIncomingCall struct {
+	// From is the item that makes the call(s).
+	From CallHierarchyItem `json:"from"`
+
+	// FromRanges are the ranges of the calls, relative to the caller.
+	FromRanges []Range `json:"fromRanges"`
+}
+
+// CallHierarchyOutgoingCallsParams defines the parameters for the outgoing calls request.
+type CallHierarchyOutgoingCallsParams struct {
+	// Item is the item for which to find the outgoing calls.
+	Item CallHierarchyItem `json:"item"`
+}
+
+// CallHierarchyOutgoingCall represents an item called one or more times by another item.
+type CallHierarchyOutgoingCall struct {
+	// To is the item being called.
+	To CallHierarchyItem `json:"to"`
+
+	// FromRanges are the ranges of the calls, relative to the caller.
+	FromRanges []Range `json:"fromRanges"`
+}
+
+// PrepareCallHierarchyResult defines the result for the prepare call hierarchy request.
+type PrepareCallHierarchyResult []CallHierarchyItem
+
+// CallHierarchyIncomingCallsResult defines the result for the incoming calls request.
+type CallHierarchyIncomingCallsResult []CallHierarchyIncomingCall
+
+// CallHierarchyOutgoingCallsResult defines the result for the outgoing calls request.
+type CallHierarchyOutgoingCallsResult []CallHierarchyOutgoingCall
@@ -0,0 +1,84 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+// ClientCapabilities defines the set of capabilities supported by the client.
+type ClientCapabilities struct {
+	// Workspace defines the workspace-specific capabilities of the client.
+	Workspace *WorkspaceClientCapabilities `json:"workspace,omitempty"`
+
+	// TextDocument defines the text-document-specific capabilities of the client.
+	TextDocument *TextDocumentClientCapabilities `json:"textDocument,omitempty"`
+
+	// General defines the general capabilities of the client.
+	General *GeneralClientCapabilities `json:"general,omitempty"`
+}
+
+// GeneralClientCapabilities defines the general capabilities of the client.
+type GeneralClientCapabilities struct {
+	// PositionEncodings are the position encodings supported by the client. If omitted, only
+	// UTF-16 is supported.
+	PositionEncodings []PositionEncodingKind `json:"positionEncodings,omitempty"`
+}
+
+// WorkspaceClientCapabilities defines the workspace-specific capabilities of the client.
+type WorkspaceClientCapabilities struct {
+	// WorkspaceEdit defines the capabilities of the client for workspace edits.
+	WorkspaceEdit *WorkspaceEditClientCapabilities `json:"workspaceEdit,omitempty"`
+
+	// DidChangeWatchedFiles defines the capabilities of the client for the watched-files-changed notification.
+	DidChangeWatchedFiles *DidChangeWatchedFilesClientCapabilities `json:"didChangeWatchedFiles,omitempty"`
+
+	// DidChangeConfiguration defines the capabilities of the client for the configuration-changed notification.
+	DidChangeConfiguration *DidChangeConfigurationClientCapabilities `json:"didChangeConfiguration,omitempty"`
+
+	// Configuration indicates (if true) that the client supports the configuration request.
+	Configuration *bool `json:"configuration,omitempty"`
+}
+
+// WorkspaceEditClientCapabilities defines the capabilities of the client for workspace edits.
+type WorkspaceEditClientCapabilities struct {
+	// DocumentChanges indicates (if true) that the client supports versioned document changes
+	// in workspace edits.
+	DocumentChanges *bool `json:"documentChanges,omitempty"`
+}
+
+// DidChangeWatchedFilesClientCapabilities defines the capabilities of the client for the watched-files-changed
+// notification.
+type DidChangeWatchedFilesClientCapabilities struct {
+	// DynamicRegistration indicates (if true) that the client supports dynamic registration of file watchers.
+	DynamicRegistration *bool `json:"dynamicRegistration,omitempty"`
+}
+
+// DidChangeConfigurationClientCapabilities defines the capabilities of the client for the configuration-changed
+// notification.
+type DidChangeConfigurationClientCapabilities struct {
+	// DynamicRegistration indicates (if true) that the client supports dynamic registration for configuration
+	// changes.
+	DynamicRegistration *bool `json:"dynamicRegistration,omitempty"`
+}
+
+// TextDocumentClientCapabilities defines the text-document-specific capabilities of the client.
+type TextDocumentClientCapabilities struct {
+	// Rename defines the capabilities of the client for the rename request.
+	Rename *RenameClientCapabilities `json:"rename,omitempty"`
+
+	// DocumentSymbol defines the capabilities of the client for the document symbol request.
+	DocumentSymbol *DocumentSymbolClientCapabilities `json:"documentSymbol,omitempty"`
+}
+
+// RenameClientCapabilities defines the capabilities of the client for the rename request.
+type RenameClientCapabilities struct {
+	// PrepareSupport indicates (if true) that the client supports testing for the validity
+	// of rename operations before execution.
+	PrepareSupport *bool `json:"prepareSupport,omitempty"`
+}
+
+// DocumentSymbolClientCapabilities defines the capabilities of the client for the document symbol request.
+type DocumentSymbolClientCapabilities struct {
+	// HierarchicalDocumentSymbolSupport indicates (if true) that the client supports hierarchical
+	// document symbols.
+	HierarchicalDocumentSymbolSupport *bool `json:"hierarchicalDocumentSymbolSupport,omitempty"`
+}
@@ -0,0 +1,124 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+// ConfigurationRequest defines the name of the request sent from the *server* to the client to
+// retrieve configuration settings.
+const ConfigurationRequest = "workspace/configuration"
+
+// DidChangeConfigurationNotification defines the name of the configuration-changed notification.
+const DidChangeConfigurationNotification = "workspace/didChangeConfiguration"
+
+// SettingsSection defines the section of the client's configuration holding the Serulian settings.
+const SettingsSection = "serulian"
+
+// ConfigurationParams defines the parameters for the configuration request.
+type ConfigurationParams struct {
+	// Items are the configuration sections requested.
+	Items []ConfigurationItem `json:"items"`
+}
+
+// ConfigurationItem defines a single configuration section requested.
+type ConfigurationItem struct {
+	// ScopeURI is the scope for which to retrieve the configuration section, if any.
+	ScopeURI *DocumentURI `json:"scopeUri,omitempty"`
+
+	// Section is the name of the configuration section.
+	Section string `json:"section,omitempty"`
+}
+
+// DidChangeConfigurationParams is the parameters for the DidChangeConfigurationNotification.
+type DidChangeConfigurationParams struct {
+	// Settings are the changed settings, if sent by the client.
+	Settings *ChangedSettings `json:"settings,omitempty"`
+}
+
+// ChangedSettings defines the changed settings sent by the client, by section.
+type ChangedSettings struct {
+	// Serulian are the changed Serulian settings, if any.
+	Serulian *Settings `json:"serulian,omitempty"`
+}
+
+// FormatOnSaveSetting defines when documents are formatted before being saved.
+type FormatOnSaveSetting string
+
+const (
+	// FormatOnSaveOff indicates that documents are never formatted when saved.
+	FormatOnSaveOff FormatOnSaveSetting = "off"
+
+	// FormatOnSaveManual indicates that documents are formatted only when saved manually.
+	FormatOnSaveManual FormatOnSaveSetting = "manual"
+
+	// FormatOnSaveAlways indicates that documents are formatted whenever saved.
+	FormatOnSaveAlways FormatOnSaveSetting = "always"
+)
+
+// LintSeverity defines the severity with which a kind of issue is reported.
+type LintSeverity string
+
+const (
+	// LintSeverityOff indicates that the issues are not reported.
+	LintSeverityOff LintSeverity = "off"
+
+	// LintSeverityHint indicates that the issues are reported as hints.
+	LintSeverityHint LintSeverity = "hint"
+
+	// LintSeverityInformation indicates that the issues are reported as information.
+	LintSeverityInformation LintSeverity = "information"
+
+	// LintSeverityWarning indicates that the issues are reported as warnings.
+	LintSeverityWarning LintSeverity = "warning"
+
+	// LintSeverityError indicates that the issues are reported as errors.
+	LintSeverityError LintSeverity = "error"
+)
+
+// Settings defines the Serulian settings of the server, as found under the SettingsSection of the client's
+// configuration. A setting which is not specified falls back to that found in the project configuration
+// file of the workspace, if any, and then to its default.
+type Settings struct {
+	// BuildTimeout is the maximum duration, in milliseconds, to build a Grok handle before timing out.
+	BuildTimeout *int `json:"buildTimeout,omitempty"`
+
+	// DiagnoseDelay is the delay, in milliseconds, waited after a change before a document is diagnosed.
+	DiagnoseDelay *int `json:"diagnoseDelay,omitempty"`
+
+	// VCSDevelopmentDirectories are the directories against which VCS packages without specification are
+	// first checked.
+	VCSDevelopmentDirectories []string `json:"vcsDevelopmentDirectories,omitempty"`
+
+	// FormatOnSave defines when documents are formatted before being saved.
+	FormatOnSave *FormatOnSaveSetting `json:"formatOnSave,omitempty"`
+
+	// InlayHints defines the settings for the inlay hints shown.
+	InlayHints *InlayHintSettings `json:"inlayHints,omitempty"`
+
+	// Lint defines the severities with which the issues found in sources are reported.
+	Lint *LintSettings `json:"lint,omitempty"`
+
+	// Features defines which optional features of the server are enabled.
+	Features *FeatureSettings `json:"features,omitempty"`
+}
+
+// LintSettings defines the severities with which the issues found in sources are reported.
+type LintSettings struct {
+	// Warnings is the severity with which the warnings produced by the compiler are reported.
+	Warnings *LintSeverity `json:"warnings,omitempty"`
+}
+
+// FeatureSettings defines which optional features of the server are enabled.
+type FeatureSettings struct {
+	// CodeLens indicates whether code lenses are provided.
+	CodeLens *bool `json:"codeLens,omitempty"`
+
+	// DocumentLinks indicates whether links are provided for imports.
+	DocumentLinks *bool `json:"documentLinks,omitempty"`
+
+	// SemanticTokens indicates whether semantic tokens are provided.
+	SemanticTokens *bool `json:"semanticTokens,omitempty"`
+
+	// OnTypeFormatting indicates whether documents are formatted as they are typed.
+	OnTypeFormatting *bool `json:"onTypeFormatting,omitempty"`
+}
@@ -0,0 +1,40 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+// DocumentHighlightRequest defines the name of the document highlight method.
+const DocumentHighlightRequest = "textDocument/documentHighlight"
+
+// DocumentHighlightParams defines the parameters for the document highlight request.
+type DocumentHighlightParams struct {
+	TextDocumentPositionParams
+}
+
+// DocumentHighlightKind is an enumeration of the different kinds of document highlights.
+type DocumentHighlightKind int
+
+const (
+	// DocumentHighlightText indicates a textual occurrence.
+	DocumentHighlightText DocumentHighlightKind = 1
+
+	// DocumentHighlightRead indicates read-access of a symbol, like reading a variable.
+	DocumentHighlightRead = 2
+
+	// DocumentHighlightWrite indicates write-access of a symbol, like writing to a variable.
+	DocumentHighlightWrite = 3
+)
+
+// DocumentHighlight represents a range inside a document which deserves special attention,
+// such as an occurrence of the symbol under the cursor.
+type DocumentHighlight struct {
+	// Range is the range this highlight applies to.
+	Range Range `json:"range"`
+
+	// Kind is the kind of the highlight.
+	Kind DocumentHighlightKind `json:"kind"`
+}
+
+// DocumentHighlightResult defines the result for the document highlight request.
+type DocumentHighlightResult []DocumentHighlight
@@ -0,0 +1,38 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+// DocumentLinkRequest defines the name of the document link method.
+const DocumentLinkRequest = "textDocument/documentLink"
+
+// DocumentLinkResolveRequest defines the name of the document link resolve method.
+const DocumentLinkResolveRequest = "documentLink/resolve"
+
+// DocumentLinkParams defines the parameters for the document link request.
+type DocumentLinkParams struct {
+	// TextDocument is the document for which to provide the links.
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// DocumentLink represents a range in a document which links to another document or directory.
+type DocumentLink struct {
+	// Range is the range covered by the link.
+	Range Range `json:"range"`
+
+	// Target is the URI to which the link points. If missing, a resolve request is sent later.
+	Target *DocumentURI `json:"target,omitempty"`
+
+	// Tooltip is the tooltip text shown when hovering over the link, if any.
+	Tooltip string `json:"tooltip,omitempty"`
+
+	// Data is data preserved between the document link and document link resolve requests.
+	Data interface{} `json:"data,omitempty"`
+}
+
+// DocumentLinkResult defines the result for the document link request.
+type DocumentLinkResult []DocumentLink
+
+// DocumentLinkResolveResult defines the result for the document link resolve request.
+type DocumentLinkResolveResult DocumentLink
@@ -0,0 +1,41 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+// DocumentSymbolRequest defines the name of the document symbol method.
+const DocumentSymbolRequest = "textDocument/documentSymbol"
+
+// DocumentSymbolParams defines the parameters for the document symbol request.
+type DocumentSymbolParams struct {
+	// TextDocument is the document.
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// DocumentSymbol represents a symbol found in a document, along with the symbols it contains.
+type DocumentSymbol struct {
+	// Name is the name of the symbol.
+	Name string `json:"name"`
+
+	// Detail is additional detail for the symbol, such as its type.
+	Detail string `json:"detail,omitempty"`
+
+	// Kind is the kind of the symbol.
+	Kind SymbolKind `json:"kind"`
+
+	// Range is the range enclosing the full definition of the symbol, including its body.
+	Range Range `json:"range"`
+
+	// SelectionRange is the range that should be selected and revealed when the symbol is
+	// picked, typically its name. Must be contained within Range.
+	SelectionRange Range `json:"selectionRange"`
+
+	// Children are the symbols contained within this symbol, if any.
+	Children []DocumentSymbol `json:"children,omitempty"`
+}
+
+// DocumentSymbolResult defines the result for the document symbol request. Contains either
+// a slice of DocumentSymbol or, for clients not supporting hierarchical symbols, a slice
+// of SymbolInformation.
+type DocumentSymbolResult interface{}
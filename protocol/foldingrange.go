@@ -0,0 +1,51 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+// FoldingRangeRequest defines the name of the folding range method.
+const FoldingRangeRequest = "textDocument/foldingRange"
+
+// FoldingRangeParams defines the parameters for the folding range request.
+type FoldingRangeParams struct {
+	// TextDocument is the document.
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// FoldingRangeKind is an enumeration of the well-known kinds of folding ranges.
+type FoldingRangeKind string
+
+const (
+	// FoldingRangeComment indicates a folding range for a comment.
+	FoldingRangeComment FoldingRangeKind = "comment"
+
+	// FoldingRangeImports indicates a folding range for a block of imports.
+	FoldingRangeImports FoldingRangeKind = "imports"
+
+	// FoldingRangeRegion indicates a folding range for a region, such as a block.
+	FoldingRangeRegion FoldingRangeKind = "region"
+)
+
+// FoldingRange represents a range of lines in a document that can be folded.
+type FoldingRange struct {
+	// StartLine is the zero-based line number from where the folded range starts.
+	StartLine int `json:"startLine"`
+
+	// StartCharacter is the character on the start line from where the folded range starts. If not
+	// defined, defaults to the length of the start line.
+	StartCharacter *int `json:"startCharacter,omitempty"`
+
+	// EndLine is the zero-based line number where the folded range ends.
+	EndLine int `json:"endLine"`
+
+	// EndCharacter is the character on the end line where the folded range ends. If not defined,
+	// defaults to the length of the end line.
+	EndCharacter *int `json:"endCharacter,omitempty"`
+
+	// Kind describes the kind of the folding range.
+	Kind FoldingRangeKind `json:"kind,omitempty"`
+}
+
+// FoldingRangeResult defines the result for the folding range request.
+type FoldingRangeResult []FoldingRange
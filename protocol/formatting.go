@@ -15,3 +15,32 @@ type DocumentFormattingParams struct {
 
 // DocumentFormattingResult defines the result of a formatting request.
 type DocumentFormattingResult []TextEdit
+
+// DocumentRangeFormattingRequest defines the name of the range formatting method.
+const DocumentRangeFormattingRequest = "textDocument/rangeFormatting"
+
+// DocumentRangeFormattingParams defines the parameters for the range formatting request.
+type DocumentRangeFormattingParams struct {
+	// TextDocument identifies the document to format.
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+
+	// Range is the range to format.
+	Range Range `json:"range"`
+}
+
+// DocumentRangeFormattingResult defines the result of a range formatting request.
+type DocumentRangeFormattingResult []TextEdit
+
+// DocumentOnTypeFormattingRequest defines the name of the on-type formatting method.
+const DocumentOnTypeFormattingRequest = "textDocument/onTypeFormatting"
+
+// DocumentOnTypeFormattingParams defines the parameters for the on-type formatting request.
+type DocumentOnTypeFormattingParams struct {
+	TextDocumentPositionParams
+
+	// Ch is the character that has been typed.
+	Ch string `json:"ch"`
+}
+
+// DocumentOnTypeFormattingResult defines the result of an on-type formatting request.
+type DocumentOnTypeFormattingResult []TextEdit
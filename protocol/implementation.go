@@ -0,0 +1,16 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+// ImplementationRequest defines the name of the implementation method.
+const ImplementationRequest = "textDocument/implementation"
+
+// ImplementationParams defines the parameters for the implementation request.
+type ImplementationParams struct {
+	TextDocumentPositionParams
+}
+
+// ImplementationResult defines the result for the implementation request.
+type ImplementationResult []Location
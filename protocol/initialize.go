@@ -7,6 +7,9 @@ package protocol
 // InitializeMethod defines the name of the `initialize` method.
 const InitializeMethod = "initialize"
 
+// ShutdownMethod defines the name of the `shutdown` method.
+const ShutdownMethod = "shutdown"
+
 // InitializeParams is the set of parameters sent by the client for the `initialize` call.
 type InitializeParams struct {
 	/**
@@ -22,10 +25,35 @@ type InitializeParams struct {
 	 */
 	RootURI DocumentURI `json:"rootUri,omitempty"`
 
+	/**
+	 * The workspace folders configured in the client when the server starts.
+	 * Is null if the client does not support workspace folders, and empty if
+	 * no folders are configured.
+	 */
+	WorkspaceFolders []WorkspaceFolder `json:"workspaceFolders,omitempty"`
+
+	/**
+	 * The capabilities provided by the client (editor or tool).
+	 */
+	Capabilities ClientCapabilities `json:"capabilities"`
+
 	/**
 	 * The initial trace setting. If omitted trace is disabled ('off').
 	 */
 	Trace *TraceOption `json:"trace,omitempty"`
+
+	/**
+	 * User provided initialization options.
+	 */
+	InitializationOptions *InitializationOptions `json:"initializationOptions,omitempty"`
+}
+
+// InitializationOptions defines the Serulian-specific options that can be provided by the client
+// on initialization.
+type InitializationOptions struct {
+	// Settings are the initial settings of the server, in the same form as those found under the
+	// SettingsSection of the client's configuration.
+	Settings
 }
 
 // InitializeResult is the server result for an `initialize`.
@@ -0,0 +1,78 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+// InlayHintRequest defines the name of the inlay hint method.
+const InlayHintRequest = "textDocument/inlayHint"
+
+// InlayHintResolveRequest defines the name of the inlay hint resolve method.
+const InlayHintResolveRequest = "inlayHint/resolve"
+
+// InlayHintParams defines the parameters for the inlay hint request.
+type InlayHintParams struct {
+	// TextDocument is the document.
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+
+	// Range is the visible range for which inlay hints should be computed.
+	Range Range `json:"range"`
+}
+
+// InlayHintKind is an enumeration of the different kinds of inlay hints.
+type InlayHintKind int
+
+const (
+	// InlayHintType indicates a hint for a type annotation.
+	InlayHintType InlayHintKind = 1
+
+	// InlayHintParameter indicates a hint for a parameter name.
+	InlayHintParameter = 2
+)
+
+// InlayHint represents a hint rendered inline in the document, such as an inferred type.
+type InlayHint struct {
+	// Position is the position of the hint.
+	Position Position `json:"position"`
+
+	// Label is the label of the hint.
+	Label string `json:"label"`
+
+	// Kind is the kind of the hint.
+	Kind InlayHintKind `json:"kind,omitempty"`
+
+	// Tooltip is the tooltip text shown when hovering over the hint, if any.
+	Tooltip *MarkupContent `json:"tooltip,omitempty"`
+
+	// PaddingLeft indicates whether to render padding before the hint.
+	PaddingLeft bool `json:"paddingLeft,omitempty"`
+
+	// PaddingRight indicates whether to render padding after the hint.
+	PaddingRight bool `json:"paddingRight,omitempty"`
+
+	// Data is data preserved between the inlay hint and inlay hint resolve requests.
+	Data interface{} `json:"data,omitempty"`
+}
+
+// InlayHintOptions defines the options for the inlay hint feature offered by the server.
+type InlayHintOptions struct {
+	// ResolveProvider, if true, indicates that the server provides support for resolving
+	// additional information for an inlay hint.
+	ResolveProvider *bool `json:"resolveProvider,omitempty"`
+}
+
+// InlayHintSettings defines the settings for the inlay hints shown by the server, by kind. A setting
+// which is not specified keeps its current value.
+type InlayHintSettings struct {
+	// VariableTypes indicates whether to show the inferred types of variables.
+	VariableTypes *bool `json:"variableTypes,omitempty"`
+
+	// ParameterNames indicates whether to show the names of parameters for literal arguments.
+	ParameterNames *bool `json:"parameterNames,omitempty"`
+}
+
+// InlayHintResult defines the result for the inlay hint request.
+type InlayHintResult []InlayHint
+
+// InlayHintResolveResult defines the result for the inlay hint resolve request.
+type InlayHintResolveResult InlayHint
@@ -0,0 +1,20 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+// PositionEncodingKind defines the encoding used to count the characters (columns) of positions.
+type PositionEncodingKind string
+
+const (
+	// PositionEncodingUTF8 indicates that columns count UTF-8 code units (bytes).
+	PositionEncodingUTF8 PositionEncodingKind = "utf-8"
+
+	// PositionEncodingUTF16 indicates that columns count UTF-16 code units. This is the default
+	// encoding and must always be supported by servers.
+	PositionEncodingUTF16 PositionEncodingKind = "utf-16"
+
+	// PositionEncodingUTF32 indicates that columns count UTF-32 code units (Unicode code points).
+	PositionEncodingUTF32 PositionEncodingKind = "utf-32"
+)
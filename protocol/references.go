@@ -0,0 +1,25 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+// ReferencesRequest defines the name of the find references method.
+const ReferencesRequest = "textDocument/references"
+
+// ReferenceContext defines additional information for the references request.
+type ReferenceContext struct {
+	// IncludeDeclaration indicates whether to include the declaration of the current symbol.
+	IncludeDeclaration bool `json:"includeDeclaration"`
+}
+
+// ReferenceParams defines the parameters for the references request.
+type ReferenceParams struct {
+	TextDocumentPositionParams
+
+	// Context is the context of the request.
+	Context ReferenceContext `json:"context"`
+}
+
+// ReferencesResult defines the result for the references request.
+type ReferencesResult []Location
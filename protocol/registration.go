@@ -0,0 +1,27 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+// RegisterCapabilityRequest defines the name of the request sent from the *server* to the client to
+// register for a new capability.
+const RegisterCapabilityRequest = "client/registerCapability"
+
+// RegistrationParams defines the parameters for the register capability request.
+type RegistrationParams struct {
+	// Registrations are the capabilities to register.
+	Registrations []Registration `json:"registrations"`
+}
+
+// Registration defines the registration of a single capability.
+type Registration struct {
+	// ID is the ID used to register the request, which can be used to unregister it later.
+	ID string `json:"id"`
+
+	// Method is the method for which the capability is registered.
+	Method string `json:"method"`
+
+	// RegisterOptions are the options necessary for the registration, if any.
+	RegisterOptions interface{} `json:"registerOptions,omitempty"`
+}
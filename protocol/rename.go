@@ -0,0 +1,44 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+// RenameRequest defines the name of the rename method.
+const RenameRequest = "textDocument/rename"
+
+// PrepareRenameRequest defines the name of the prepare rename method.
+const PrepareRenameRequest = "textDocument/prepareRename"
+
+// RenameOptions defines the options for the rename feature offered by the server.
+type RenameOptions struct {
+	// PrepareProvider, if true, indicates that the server supports testing the validity
+	// of rename operations before execution.
+	PrepareProvider *bool `json:"prepareProvider,omitempty"`
+}
+
+// RenameParams defines the parameters for the rename request.
+type RenameParams struct {
+	TextDocumentPositionParams
+
+	// NewName is the new name of the symbol. If the given name is not valid, the
+	// request must return an error.
+	NewName string `json:"newName"`
+}
+
+// RenameResult defines the result for the rename request.
+type RenameResult WorkspaceEdit
+
+// PrepareRenameParams defines the parameters for the prepare rename request.
+type PrepareRenameParams struct {
+	TextDocumentPositionParams
+}
+
+// PrepareRenameResult defines the result for the prepare rename request.
+type PrepareRenameResult struct {
+	// Range is the range of the symbol to be renamed.
+	Range Range `json:"range"`
+
+	// Placeholder is the text to display in the rename input box.
+	Placeholder string `json:"placeholder"`
+}
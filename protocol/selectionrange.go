@@ -0,0 +1,30 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+// SelectionRangeRequest defines the name of the selection range method.
+const SelectionRangeRequest = "textDocument/selectionRange"
+
+// SelectionRangeParams defines the parameters for the selection range request.
+type SelectionRangeParams struct {
+	// TextDocument is the document.
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+
+	// Positions are the positions inside the text document.
+	Positions []Position `json:"positions"`
+}
+
+// SelectionRange represents a selection range, along with the parent range which contains it.
+type SelectionRange struct {
+	// Range is the range of this selection range.
+	Range Range `json:"range"`
+
+	// Parent is the parent selection range containing this range, if any.
+	Parent *SelectionRange `json:"parent,omitempty"`
+}
+
+// SelectionRangeResult defines the result for the selection range request. Contains one selection
+// range for each of the requested positions, in order.
+type SelectionRangeResult []SelectionRange
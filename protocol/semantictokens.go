@@ -0,0 +1,106 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+// SemanticTokensFullRequest defines the name of the method for the semantic tokens of a full document.
+const SemanticTokensFullRequest = "textDocument/semanticTokens/full"
+
+// SemanticTokensFullDeltaRequest defines the name of the method for the semantic tokens of a full document,
+// as a delta against a previous result.
+const SemanticTokensFullDeltaRequest = "textDocument/semanticTokens/full/delta"
+
+// SemanticTokensRangeRequest defines the name of the method for the semantic tokens of a range of a document.
+const SemanticTokensRangeRequest = "textDocument/semanticTokens/range"
+
+// SemanticTokensLegend defines the legend of the token types and modifiers used by the server. Tokens
+// refer to their type by index and to their modifiers by bit flags, in the order given here.
+type SemanticTokensLegend struct {
+	// TokenTypes are the token types used by the server.
+	TokenTypes []string `json:"tokenTypes"`
+
+	// TokenModifiers are the token modifiers used by the server.
+	TokenModifiers []string `json:"tokenModifiers"`
+}
+
+// SemanticTokensFullOptions defines the options for the semantic tokens of a full document.
+type SemanticTokensFullOptions struct {
+	// Delta, if true, indicates that the server supports deltas for full documents.
+	Delta *bool `json:"delta,omitempty"`
+}
+
+// SemanticTokensOptions defines the options for the semantic tokens feature offered by the server.
+type SemanticTokensOptions struct {
+	// Legend is the legend used by the server.
+	Legend SemanticTokensLegend `json:"legend"`
+
+	// Range, if true, indicates that the server supports the semantic tokens of a range of a document.
+	Range *bool `json:"range,omitempty"`
+
+	// Full, if set, indicates that the server supports the semantic tokens of a full document.
+	Full *SemanticTokensFullOptions `json:"full,omitempty"`
+}
+
+// SemanticTokensParams defines the parameters for the semantic tokens request for a full document.
+type SemanticTokensParams struct {
+	// TextDocument is the document.
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// SemanticTokensDeltaParams defines the parameters for the semantic tokens delta request.
+type SemanticTokensDeltaParams struct {
+	// TextDocument is the document.
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+
+	// PreviousResultID is the ID of the previous result, against which the delta is computed.
+	PreviousResultID string `json:"previousResultId"`
+}
+
+// SemanticTokensRangeParams defines the parameters for the semantic tokens request for a range of a document.
+type SemanticTokensRangeParams struct {
+	// TextDocument is the document.
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+
+	// Range is the range for which the tokens are requested.
+	Range Range `json:"range"`
+}
+
+// SemanticTokens represents the semantic tokens of a document or range.
+type SemanticTokens struct {
+	// ResultID is the ID of the result, if any. Used by the client for a later delta request.
+	ResultID string `json:"resultId,omitempty"`
+
+	// Data contains the encoded tokens, five integers per token: the line (relative to the previous
+	// token), the start character (relative to the previous token, if on the same line), the length,
+	// the index of the token type and the bit flags of the token modifiers.
+	Data []int `json:"data"`
+}
+
+// SemanticTokensEdit represents an edit to the data of a previous semantic tokens result.
+type SemanticTokensEdit struct {
+	// Start is the index in the previous data at which the edit starts.
+	Start int `json:"start"`
+
+	// DeleteCount is the number of elements to remove.
+	DeleteCount int `json:"deleteCount"`
+
+	// Data contains the elements to insert, if any.
+	Data []int `json:"data,omitempty"`
+}
+
+// SemanticTokensDelta represents the changes to the semantic tokens of a document since a previous result.
+type SemanticTokensDelta struct {
+	// ResultID is the ID of this result.
+	ResultID string `json:"resultId,omitempty"`
+
+	// Edits are the edits to apply to the previous result's data.
+	Edits []SemanticTokensEdit `json:"edits"`
+}
+
+// SemanticTokensResult defines the result for the semantic tokens requests for a full document or range.
+type SemanticTokensResult SemanticTokens
+
+// SemanticTokensDeltaResult defines the result for the semantic tokens delta request. Can be either
+// a SemanticTokensDelta or, if the previous result is unknown, SemanticTokens.
+type SemanticTokensDeltaResult interface{}
@@ -71,7 +71,7 @@ type CodeLensOptions struct {
 // DocumentOnTypeFormattingOptions defines the options for the on-type formatting feature offered by the server.
 type DocumentOnTypeFormattingOptions struct {
 	// FirstTriggerCharacter defines a character on which formatting should be triggered, like `}`.
-	FirstTriggerCharacter rune `json:"firstTriggerCharacter"`
+	FirstTriggerCharacter string `json:"firstTriggerCharacter"`
 
 	// MoreTriggerCharacter defines additional trigger characters for formatting.
 	MoreTriggerCharacter *[]string `json:"moreTriggerCharacter,omitempty"`
@@ -93,6 +93,10 @@ type ExecuteCommandOptions struct {
 
 // ServerCapabilities defines the set of capabilities for the language server.
 type ServerCapabilities struct {
+	// PositionEncoding defines the position encoding the server picked from the encodings offered
+	// by the client. If omitted, defaults to UTF-16.
+	PositionEncoding PositionEncodingKind `json:"positionEncoding,omitempty"`
+
 	// TextDocumentSync defines how text documents are synced.
 	TextDocumentSync *TextDocumentSyncOptions `json:"textDocumentSync,omitempty"`
 
@@ -110,6 +114,12 @@ type ServerCapabilities struct {
 	// DefinitionProvider indicates (if true), that this server supports goto definitions.
 	DefinitionProvider *bool `json:"definitionProvider,omitempty"`
 
+	// TypeDefinitionProvider indicates (if true), that this server supports goto type definition.
+	TypeDefinitionProvider *bool `json:"typeDefinitionProvider,omitempty"`
+
+	// ImplementationProvider indicates (if true), that this server supports goto implementation.
+	ImplementationProvider *bool `json:"implementationProvider,omitempty"`
+
 	// ReferencesProvider indicates (if true), that this server provides find references support.
 	ReferencesProvider *bool `json:"referencesProvider,omitempty"`
 
@@ -137,8 +147,26 @@ type ServerCapabilities struct {
 	// DocumentOnTypeFormattingProvider indicates (if set), that this server provides document on-type formatting support with the given options.
 	DocumentOnTypeFormattingProvider *DocumentOnTypeFormattingOptions `json:"documentOnTypeFormattingProvider,omitempty"`
 
-	// RenameProvider indicates (if true), that this server provides rename support.
-	RenameProvider *bool `json:"renameProvider,omitempty"`
+	// RenameProvider indicates (if set), that this server provides rename support. Can be a bool or RenameOptions.
+	RenameProvider interface{} `json:"renameProvider,omitempty"`
+
+	// FoldingRangeProvider indicates (if true), that this server provides folding range support.
+	FoldingRangeProvider *bool `json:"foldingRangeProvider,omitempty"`
+
+	// SelectionRangeProvider indicates (if true), that this server provides selection range support.
+	SelectionRangeProvider *bool `json:"selectionRangeProvider,omitempty"`
+
+	// CallHierarchyProvider indicates (if true), that this server provides call hierarchy support.
+	CallHierarchyProvider *bool `json:"callHierarchyProvider,omitempty"`
+
+	// TypeHierarchyProvider indicates (if true), that this server provides type hierarchy support.
+	TypeHierarchyProvider *bool `json:"typeHierarchyProvider,omitempty"`
+
+	// SemanticTokensProvider indicates (if set), that this server provides semantic tokens with the given options.
+	SemanticTokensProvider *SemanticTokensOptions `json:"semanticTokensProvider,omitempty"`
+
+	// InlayHintProvider indicates (if set), that this server provides inlay hints with the given options.
+	InlayHintProvider *InlayHintOptions `json:"inlayHintProvider,omitempty"`
 
 	// DocumentLinkProvider indicates (if set), that this server provides document link support with the given options.
 	DocumentLinkProvider *DocumentLinkOptions `json:"documentLinkProvider,omitempty"`
@@ -146,6 +174,9 @@ type ServerCapabilities struct {
 	// ExecuteCommandProvider indicates (if set), that this server provides execute command with the given options.
 	ExecuteCommandProvider *ExecuteCommandOptions `json:"executeCommandProvider,omitempty"`
 
+	// Workspace defines the workspace-specific capabilities of this server.
+	Workspace *WorkspaceServerCapabilities `json:"workspace,omitempty"`
+
 	// Experimental defines all experimental features supported by this server.
 	Experimental interface{} `json:"experimental,omitempty"`
 }
@@ -97,9 +97,17 @@ type DidChangeTextDocumentParams struct {
 	ContentChanges []TextDocumentContentChangeEvent `json:"contentChanges"`
 }
 
-// TextDocumentContentChangeEvent defines a single change event on a document.
+// TextDocumentContentChangeEvent defines a single change event on a document. If the range is
+// not specified, the text is the full content of the changed document.
 type TextDocumentContentChangeEvent struct {
-	// Text is the contents of the changed document.
+	// Range is the range of the document that changed, if any.
+	Range *Range `json:"range,omitempty"`
+
+	// RangeLength is the length of the range that got replaced. Deprecated in favor of Range.
+	RangeLength *int `json:"rangeLength,omitempty"`
+
+	// Text is the new text of the range, or the contents of the changed document if
+	// no range was specified.
 	Text string `json:"text"`
 }
 
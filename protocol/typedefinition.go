@@ -0,0 +1,16 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+// TypeDefinitionRequest defines the name of the type definition method.
+const TypeDefinitionRequest = "textDocument/typeDefinition"
+
+// TypeDefinitionParams defines the parameters for the type definition request.
+type TypeDefinitionParams struct {
+	TextDocumentPositionParams
+}
+
+// TypeDefinitionResult defines the result for the type definition request.
+type TypeDefinitionResult []Location
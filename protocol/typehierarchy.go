@@ -0,0 +1,62 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+// PrepareTypeHierarchyRequest defines the name of the prepare type hierarchy method.
+const PrepareTypeHierarchyRequest = "textDocument/prepareTypeHierarchy"
+
+// TypeHierarchySupertypesRequest defines the name of the supertypes method.
+const TypeHierarchySupertypesRequest = "typeHierarchy/supertypes"
+
+// TypeHierarchySubtypesRequest defines the name of the subtypes method.
+const TypeHierarchySubtypesRequest = "typeHierarchy/subtypes"
+
+// TypeHierarchyPrepareParams defines the parameters for the prepare type hierarchy request.
+type TypeHierarchyPrepareParams struct {
+	TextDocumentPositionParams
+}
+
+// TypeHierarchyItem represents a type in the type hierarchy.
+type TypeHierarchyItem struct {
+	// Name is the name of the item.
+	Name string `json:"name"`
+
+	// Kind is the kind of the item.
+	Kind SymbolKind `json:"kind"`
+
+	// Detail holds more detail for the item, such as the types it references.
+	Detail string `json:"detail,omitempty"`
+
+	// URI is the resource identifier of the item.
+	URI DocumentURI `json:"uri"`
+
+	// Range is the range enclosing the item, including its body.
+	Range Range `json:"range"`
+
+	// SelectionRange is the range that should be selected and revealed when the item is being picked,
+	// such as its name. Must be contained by the Range.
+	SelectionRange Range `json:"selectionRange"`
+
+	// Data is data preserved between the prepare type hierarchy and the supertypes and subtypes requests.
+	Data interface{} `json:"data,omitempty"`
+}
+
+// TypeHierarchySupertypesParams defines the parameters for the supertypes request.
+type TypeHierarchySupertypesParams struct {
+	// Item is the item for which to find the supertypes.
+	Item TypeHierarchyItem `json:"item"`
+}
+
+// TypeHierarchySubtypesParams defines the parameters for the subtypes request.
+type TypeHierarchySubtypesParams struct {
+	// Item is the item for which to find the subtypes.
+	Item TypeHierarchyItem `json:"item"`
+}
+
+// PrepareTypeHierarchyResult defines the result for the prepare type hierarchy request.
+type PrepareTypeHierarchyResult []TypeHierarchyItem
+
+// TypeHierarchyResult defines the result for the supertypes and subtypes requests.
+type TypeHierarchyResult []TypeHierarchyItem
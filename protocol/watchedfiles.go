@@ -0,0 +1,49 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+// DidChangeWatchedFilesNotification defines the name of the watched-files-changed notification.
+const DidChangeWatchedFilesNotification = "workspace/didChangeWatchedFiles"
+
+// FileChangeType defines the type of a file change.
+type FileChangeType int
+
+const (
+	// FileCreated indicates that the file was created.
+	FileCreated FileChangeType = 1
+
+	// FileChanged indicates that the file was changed.
+	FileChanged = 2
+
+	// FileDeleted indicates that the file was deleted.
+	FileDeleted = 3
+)
+
+// DidChangeWatchedFilesParams is the parameters for the DidChangeWatchedFilesNotification.
+type DidChangeWatchedFilesParams struct {
+	// Changes are the actual file events.
+	Changes []FileEvent `json:"changes"`
+}
+
+// FileEvent describes a single change to a watched file.
+type FileEvent struct {
+	// URI is the URI of the file that changed.
+	URI DocumentURI `json:"uri"`
+
+	// Type is the type of the change.
+	Type FileChangeType `json:"type"`
+}
+
+// DidChangeWatchedFilesRegistrationOptions defines the options for registering for watched file changes.
+type DidChangeWatchedFilesRegistrationOptions struct {
+	// Watchers are the watchers to register.
+	Watchers []FileSystemWatcher `json:"watchers"`
+}
+
+// FileSystemWatcher defines a watcher over the files matching a glob pattern.
+type FileSystemWatcher struct {
+	// GlobPattern is the glob pattern of the files to watch.
+	GlobPattern string `json:"globPattern"`
+}
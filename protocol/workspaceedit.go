@@ -0,0 +1,34 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+// WorkspaceEdit represents changes to many resources managed in the workspace. Only one of
+// Changes or DocumentChanges should be specified, based on the capabilities of the client.
+type WorkspaceEdit struct {
+	// Changes holds the changes to existing documents, by document URI.
+	Changes map[DocumentURI][]TextEdit `json:"changes,omitempty"`
+
+	// DocumentChanges holds the changes to specific versions of existing documents.
+	DocumentChanges []TextDocumentEdit `json:"documentChanges,omitempty"`
+}
+
+// TextDocumentEdit defines a set of edits to a specific version of a text document.
+type TextDocumentEdit struct {
+	// TextDocument is the text document to change.
+	TextDocument OptionalVersionedTextDocumentIdentifier `json:"textDocument"`
+
+	// Edits are the edits to be applied.
+	Edits []TextEdit `json:"edits"`
+}
+
+// OptionalVersionedTextDocumentIdentifier defines a reference to a document in the client, with
+// an optional version.
+type OptionalVersionedTextDocumentIdentifier struct {
+	TextDocumentIdentifier
+
+	// Version is the version number of the document. If nil, the document is not open in the client
+	// and its contents on disk are the source of truth.
+	Version *int `json:"version"`
+}
@@ -0,0 +1,48 @@
+// Copyright 2018 The Serulian Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+// DidChangeWorkspaceFoldersNotification defines the name of the workspace-folders-changed notification.
+const DidChangeWorkspaceFoldersNotification = "workspace/didChangeWorkspaceFolders"
+
+// WorkspaceFolder defines a single root folder of the workspace.
+type WorkspaceFolder struct {
+	// URI is the URI of the folder.
+	URI DocumentURI `json:"uri"`
+
+	// Name is the name of the folder, as shown in the client.
+	Name string `json:"name"`
+}
+
+// DidChangeWorkspaceFoldersParams is the parameters for the DidChangeWorkspaceFoldersNotification.
+type DidChangeWorkspaceFoldersParams struct {
+	// Event describes the changes to the workspace folders.
+	Event WorkspaceFoldersChangeEvent `json:"event"`
+}
+
+// WorkspaceFoldersChangeEvent describes the changes to the workspace folders.
+type WorkspaceFoldersChangeEvent struct {
+	// Added are the folders added to the workspace.
+	Added []WorkspaceFolder `json:"added"`
+
+	// Removed are the folders removed from the workspace.
+	Removed []WorkspaceFolder `json:"removed"`
+}
+
+// WorkspaceServerCapabilities defines the workspace-specific capabilities of the server.
+type WorkspaceServerCapabilities struct {
+	// WorkspaceFolders defines the capabilities of the server for workspace folders.
+	WorkspaceFolders *WorkspaceFoldersServerCapabilities `json:"workspaceFolders,omitempty"`
+}
+
+// WorkspaceFoldersServerCapabilities defines the capabilities of the server for workspace folders.
+type WorkspaceFoldersServerCapabilities struct {
+	// Supported indicates (if true), that this server supports multiple workspace folders.
+	Supported *bool `json:"supported,omitempty"`
+
+	// ChangeNotifications indicates (if true), that this server wants to be notified of changes to
+	// the workspace folders.
+	ChangeNotifications *bool `json:"changeNotifications,omitempty"`
+}